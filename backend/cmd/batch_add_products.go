@@ -6,11 +6,14 @@ import (
 	"fmt"
 	"hd_psi/backend/config"
 	"hd_psi/backend/models"
+	"hd_psi/backend/services"
+	"hd_psi/backend/skucode"
 	"io"
 	"log"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -18,11 +21,13 @@ import (
 
 var (
 	csvFile string
+	year    int
 )
 
 func main() {
 	// 解析命令行参数
 	flag.StringVar(&csvFile, "file", "", "CSV文件路径 (必填)")
+	flag.IntVar(&year, "year", time.Now().Year(), "SKU为空且未填写year列时，生成SKU使用的年份")
 	flag.Parse()
 
 	// 验证必填参数
@@ -46,6 +51,12 @@ func main() {
 		log.Fatalf("数据库连接失败: %v", err)
 	}
 
+	// 加载SKU编码字典
+	codec, err := skucode.Load(db)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
 	// 读取CSV文件
 	reader := csv.NewReader(file)
 	reader.Comma = ',' // 设置分隔符
@@ -58,7 +69,7 @@ func main() {
 	}
 
 	// 验证CSV格式
-	requiredColumns := []string{"sku", "name"}
+	requiredColumns := []string{"name"}
 	for _, col := range requiredColumns {
 		found := false
 		for _, h := range header {
@@ -100,23 +111,15 @@ func main() {
 		name := getColumnValue(record, colIndex, "name")
 
 		// 验证必填字段
-		if sku == "" || name == "" {
-			fmt.Printf("警告: 第%d行缺少必填字段 (SKU或名称)\n", lineNum)
-			errorCount++
-			continue
-		}
-
-		// 检查SKU是否已存在
-		var existingProduct models.Product
-		if err := db.Where("sku = ?", sku).First(&existingProduct).Error; err == nil {
-			fmt.Printf("警告: 第%d行的SKU '%s' 已存在\n", lineNum, sku)
+		if name == "" {
+			fmt.Printf("警告: 第%d行缺少必填字段 (名称)\n", lineNum)
 			errorCount++
 			continue
 		}
 
 		// 创建商品
 		product := models.Product{
-			SKU:      sku,
+			SKU:      strings.ToUpper(sku),
 			Name:     name,
 			Color:    getColumnValue(record, colIndex, "color"),
 			Size:     getColumnValue(record, colIndex, "size"),
@@ -125,6 +128,51 @@ func main() {
 			Image:    getColumnValue(record, colIndex, "image"),
 		}
 
+		// 确定类别，类别树中不存在的类别自动新建
+		if err := services.AssignProductCategory(db, &product, true); err != nil {
+			fmt.Printf("警告: 第%d行的类别无效: %v\n", lineNum, err)
+			errorCount++
+			continue
+		}
+
+		// 按SKU编码规则校验或生成SKU
+		if product.SKU == "" {
+			rowYear := year
+			if yearStr := getColumnValue(record, colIndex, "year"); yearStr != "" {
+				if rowYear, err = strconv.Atoi(yearStr); err != nil {
+					fmt.Printf("警告: 第%d行的年份格式无效: %s\n", lineNum, yearStr)
+					errorCount++
+					continue
+				}
+			}
+			if product.SKU, err = codec.Encode(skucode.FromProduct(&product, rowYear)); err != nil {
+				fmt.Printf("警告: 第%d行生成SKU失败: %v\n", lineNum, err)
+				errorCount++
+				continue
+			}
+		} else {
+			attr, err := codec.Check(product.SKU, skucode.FromProduct(&product, 0))
+			if err != nil {
+				fmt.Printf("警告: 第%d行的SKU '%s' 不符合编码规则: %v\n", lineNum, product.SKU, err)
+				errorCount++
+				continue
+			}
+			skucode.Fill(&product, attr)
+			if err := services.AssignProductCategory(db, &product, true); err != nil {
+				fmt.Printf("警告: 第%d行的类别无效: %v\n", lineNum, err)
+				errorCount++
+				continue
+			}
+		}
+
+		// 检查SKU是否已存在
+		var existingProduct models.Product
+		if err := db.Where("sku = ?", product.SKU).First(&existingProduct).Error; err == nil {
+			fmt.Printf("警告: 第%d行的SKU '%s' 已存在\n", lineNum, product.SKU)
+			errorCount++
+			continue
+		}
+
 		// 处理价格
 		if costStr := getColumnValue(record, colIndex, "cost"); costStr != "" {
 			if cost, err := strconv.ParseFloat(costStr, 64); err == nil {
@@ -168,10 +216,11 @@ func getColumnValue(record []string, colIndex map[string]int, colName string) st
 // 打印使用说明
 func printUsage() {
 	fmt.Println("\n使用方法:")
-	fmt.Println("  batch_add_products -file <csv文件路径>")
+	fmt.Println("  batch_add_products -file <csv文件路径> [-year 年份]")
 	fmt.Println("\nCSV文件格式:")
-	fmt.Println("  必须包含标题行，且必须包含'sku'和'name'列")
-	fmt.Println("  支持的列: sku, name, color, size, season, category, image, cost, retail")
+	fmt.Println("  必须包含标题行，且必须包含'name'列")
+	fmt.Println("  支持的列: sku, name, color, size, season, category, image, cost, retail, year")
+	fmt.Println("  sku须符合编码规则 品类(2)+年份(2)+季节(1)+颜色(3)+尺码(2)，为空时按year列或-year参数生成")
 	fmt.Println("\n示例:")
 	fmt.Println("  batch_add_products -file products.csv")
 }
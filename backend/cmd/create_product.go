@@ -5,8 +5,12 @@ import (
 	"fmt"
 	"hd_psi/backend/config"
 	"hd_psi/backend/models"
+	"hd_psi/backend/services"
+	"hd_psi/backend/skucode"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -23,11 +27,12 @@ var (
 	image       string
 	costPrice   float64
 	retailPrice float64
+	year        int
 )
 
 func main() {
 	// 解析命令行参数
-	flag.StringVar(&sku, "sku", "", "商品SKU编码 (必填)")
+	flag.StringVar(&sku, "sku", "", "商品SKU编码，不填写时按编码规则生成")
 	flag.StringVar(&name, "name", "", "商品名称 (必填)")
 	flag.StringVar(&color, "color", "", "商品颜色")
 	flag.StringVar(&size, "size", "", "商品尺码")
@@ -36,12 +41,13 @@ func main() {
 	flag.StringVar(&image, "image", "", "商品图片URL")
 	flag.Float64Var(&costPrice, "cost", 0, "成本价")
 	flag.Float64Var(&retailPrice, "retail", 0, "零售价")
+	flag.IntVar(&year, "year", time.Now().Year(), "生成SKU使用的年份")
 
 	flag.Parse()
 
 	// 验证必填参数
-	if sku == "" || name == "" {
-		fmt.Println("错误: SKU和商品名称为必填项")
+	if name == "" {
+		fmt.Println("错误: 商品名称为必填项")
 		printUsage()
 		os.Exit(1)
 	}
@@ -53,16 +59,9 @@ func main() {
 		log.Fatalf("数据库连接失败: %v", err)
 	}
 
-	// 检查SKU是否已存在
-	var existingProduct models.Product
-	if err := db.Where("sku = ?", sku).First(&existingProduct).Error; err == nil {
-		fmt.Printf("错误: SKU '%s' 已存在\n", sku)
-		os.Exit(1)
-	}
-
 	// 创建商品
 	product := models.Product{
-		SKU:         sku,
+		SKU:         strings.ToUpper(strings.TrimSpace(sku)),
 		Name:        name,
 		Color:       color,
 		Size:        size,
@@ -73,6 +72,43 @@ func main() {
 		RetailPrice: retailPrice,
 	}
 
+	// 确定类别，类别须已在类别树中存在
+	if err := services.AssignProductCategory(db, &product, false); err != nil {
+		fmt.Printf("错误: %v\n", err)
+		os.Exit(1)
+	}
+
+	// 按SKU编码规则校验或生成SKU
+	codec, err := skucode.Load(db)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if product.SKU == "" {
+		if product.SKU, err = codec.Encode(skucode.FromProduct(&product, year)); err != nil {
+			fmt.Printf("错误: 生成SKU失败: %v\n", err)
+			os.Exit(1)
+		}
+	} else {
+		attr, err := codec.Check(product.SKU, skucode.FromProduct(&product, 0))
+		if err != nil {
+			fmt.Printf("错误: %v\n", err)
+			os.Exit(1)
+		}
+		skucode.Fill(&product, attr)
+		if err := services.AssignProductCategory(db, &product, false); err != nil {
+			fmt.Printf("错误: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	sku = product.SKU
+
+	// 检查SKU是否已存在
+	var existingProduct models.Product
+	if err := db.Where("sku = ?", sku).First(&existingProduct).Error; err == nil {
+		fmt.Printf("错误: SKU '%s' 已存在\n", sku)
+		os.Exit(1)
+	}
+
 	if err := db.Create(&product).Error; err != nil {
 		log.Fatalf("创建商品失败: %v", err)
 	}
@@ -85,7 +121,7 @@ func printUsage() {
 	fmt.Println("\n使用方法:")
 	fmt.Println("  create_product [选项]")
 	fmt.Println("\n选项:")
-	fmt.Println("  -sku string      商品SKU编码 (必填)")
+	fmt.Println("  -sku string      商品SKU编码，不填写时按编码规则生成")
 	fmt.Println("  -name string     商品名称 (必填)")
 	fmt.Println("  -color string    商品颜色")
 	fmt.Println("  -size string     商品尺码")
@@ -94,6 +130,7 @@ func printUsage() {
 	fmt.Println("  -image string    商品图片URL")
 	fmt.Println("  -cost float      成本价")
 	fmt.Println("  -retail float    零售价")
+	fmt.Println("  -year int        生成SKU使用的年份，默认当前年份")
 	fmt.Println("\n示例:")
-	fmt.Printf("  %s -sku \"MS001\" -name \"男士休闲衬衫\" -color \"蓝色\" -size \"XL\" -category \"衬衫\" -cost 80 -retail 199\n", os.Args[0])
+	fmt.Printf("  %s -sku \"CS241BLUXL\" -name \"男士休闲衬衫\" -color \"蓝色\" -size \"XL\" -season \"春季\" -category \"衬衫\" -cost 80 -retail 199\n", os.Args[0])
 }
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"hd_psi/backend/config"
+	"hd_psi/backend/models"
+	"hd_psi/backend/services"
+	"log"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func main() {
+	// 解析命令行参数
+	dropLegacy := flag.Bool("drop-legacy", false, "删除库存阈值和预警表中原有的类别文本字段，迁移结果核对无误后使用")
+	flag.Parse()
+
+	// 连接数据库
+	dsn := config.GetDBConfig()
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("数据库连接失败: %v", err)
+	}
+
+	if err := db.AutoMigrate(&models.Category{}, &models.Product{}, &models.InventoryThreshold{}, &models.InventoryAlert{}); err != nil {
+		log.Fatalf("数据库迁移失败: %v", err)
+	}
+
+	if *dropLegacy {
+		if err := services.DropLegacyCategoryColumns(db); err != nil {
+			log.Fatalf("%v", err)
+		}
+		fmt.Println("已删除原类别文本字段")
+		return
+	}
+
+	migrated, err := services.MigrateCategories(db)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	fmt.Printf("已为 %d 个商品设置类别\n", migrated)
+}
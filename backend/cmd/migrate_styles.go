@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"hd_psi/backend/config"
+	"hd_psi/backend/models"
+	"hd_psi/backend/services"
+	"log"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func main() {
+	// 连接数据库
+	dsn := config.GetDBConfig()
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("数据库连接失败: %v", err)
+	}
+
+	if err := db.AutoMigrate(&models.Style{}, &models.Product{}); err != nil {
+		log.Fatalf("数据库迁移失败: %v", err)
+	}
+
+	migrated, err := services.MigrateProductsToStyles(db)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	fmt.Printf("已将 %d 个商品归并为款式\n", migrated)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"hd_psi/backend/config"
+	"hd_psi/backend/models"
+	"hd_psi/backend/services"
+	"log"
+	"os"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func main() {
+	// 解析命令行参数
+	storeID := flag.Uint("store", 0, "店铺ID，不指定时检查所有店铺")
+	productID := flag.Uint("product", 0, "商品ID，不指定时检查所有商品")
+	repair := flag.Bool("repair", false, "为不一致的记录补记台账修正交易")
+	reason := flag.String("reason", "", "台账修正原因 (使用 -repair 时必填)")
+	operatorID := flag.Uint("operator", 0, "操作人用户ID")
+	flag.Parse()
+
+	if *repair && *reason == "" {
+		fmt.Println("错误: 使用 -repair 时必须填写 -reason")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	// 连接数据库
+	dsn := config.GetDBConfig()
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("数据库连接失败: %v", err)
+	}
+
+	drifts, err := services.FindInventoryDrift(db, *storeID, *productID)
+	if err != nil {
+		log.Fatalf("库存对账失败: %v", err)
+	}
+
+	if len(drifts) == 0 {
+		fmt.Println("库存余额与台账一致")
+		return
+	}
+
+	fmt.Printf("%-8s %-8s %-10s %10s %10s %10s\n", "店铺", "商品", "分区", "余额", "台账", "差异")
+	for _, d := range drifts {
+		bucket := "可售"
+		if d.Bucket == models.BucketQuarantine {
+			bucket = "隔离"
+		}
+		fmt.Printf("%-8d %-8d %-10s %10d %10d %10d\n", d.StoreID, d.ProductID, bucket, d.Balance, d.Ledger, d.Difference)
+	}
+	fmt.Printf("共 %d 条记录不一致\n", len(drifts))
+
+	if !*repair {
+		return
+	}
+
+	repaired := 0
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, d := range drifts {
+			transaction, err := services.RepairDrift(tx, d.StoreID, d.ProductID, d.Bucket, *operatorID, *reason)
+			if err != nil {
+				return err
+			}
+			if transaction != nil {
+				repaired++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("台账修正失败: %v", err)
+	}
+	fmt.Printf("已补记 %d 条台账修正交易\n", repaired)
+}
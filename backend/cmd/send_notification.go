@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"hd_psi/backend/config"
+	"hd_psi/backend/models"
+	"hd_psi/backend/notify"
+	"log"
+	"os"
+)
+
+func main() {
+	// 解析命令行参数
+	channel := flag.String("channel", "", "通知渠道: email、webhook 或 sms")
+	to := flag.String("to", "", "接收地址：邮箱、回调URL或手机号，webhook 不指定时使用 NOTIFY_WEBHOOK_URL")
+	subject := flag.String("subject", "【测试】通知渠道测试", "通知标题")
+	body := flag.String("body", "这是一条测试通知，收到说明通知渠道配置正确。", "通知正文")
+	flag.Parse()
+
+	var driver notify.Driver
+	switch models.NotificationChannel(*channel) {
+	case models.ChannelEmail:
+		smtpConfig := config.GetSMTPConfig()
+		if smtpConfig.Host == "" {
+			log.Fatal("未配置 SMTP_HOST")
+		}
+		driver = notify.NewSMTPDriver(smtpConfig.Host, smtpConfig.Port, smtpConfig.Username, smtpConfig.Password, smtpConfig.From)
+	case models.ChannelWebhook:
+		// 命令行指定的地址由运维人员提供，按默认地址发送
+		url, secret := config.GetNotifyWebhook()
+		if *to != "" {
+			url, *to = *to, ""
+		}
+		driver = notify.NewWebhookDriver(url, secret)
+	case models.ChannelSMS:
+		url, apiKey := config.GetSMSConfig()
+		if url == "" {
+			log.Fatal("未配置 SMS_API_URL")
+		}
+		driver = notify.NewSMSDriver(notify.NewHTTPSMSProvider(url, apiKey))
+	default:
+		fmt.Println("错误: -channel 必须为 email、webhook 或 sms")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	err := driver.Send(notify.Recipient{Target: *to}, notify.Message{
+		Event:   "test",
+		Subject: *subject,
+		Body:    *body,
+		Data:    map[string]interface{}{},
+	})
+	if err != nil {
+		log.Fatalf("发送失败: %v", err)
+	}
+	fmt.Println("发送成功")
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"hd_psi/backend/config"
+	"hd_psi/backend/skucode"
+	"log"
+	"os"
+	"strconv"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func main() {
+	// 解析命令行参数
+	output := flag.String("out", "", "导出CSV文件路径，不指定时输出到终端")
+	flag.Parse()
+
+	// 连接数据库
+	dsn := config.GetDBConfig()
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	if err != nil {
+		log.Fatalf("数据库连接失败: %v", err)
+	}
+
+	violations, err := skucode.NonconformingProducts(db)
+	if err != nil {
+		log.Fatalf("检查SKU失败: %v", err)
+	}
+
+	if len(violations) == 0 {
+		fmt.Println("所有商品SKU均符合编码规则")
+		return
+	}
+
+	if *output == "" {
+		fmt.Printf("%-8s %-20s %-14s %-6s %s\n", "商品ID", "SKU", "问题", "可生成", "原因")
+		for _, v := range violations {
+			fmt.Printf("%-8d %-20s %-14s %-6t %s\n", v.ProductID, v.SKU, v.Problem, v.Encodable, v.Reason)
+		}
+		fmt.Printf("共 %d 个商品SKU不符合编码规则\n", len(violations))
+		return
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		log.Fatalf("创建文件失败: %v", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"product_id", "sku", "name", "category", "season", "color", "size", "problem", "encodable", "reason"})
+	for _, v := range violations {
+		writer.Write([]string{
+			strconv.FormatUint(uint64(v.ProductID), 10), v.SKU, v.Name, v.Category, v.Season,
+			v.Color, v.Size, v.Problem, strconv.FormatBool(v.Encodable), v.Reason,
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		log.Fatalf("写入文件失败: %v", err)
+	}
+	fmt.Printf("共 %d 个商品SKU不符合编码规则，已导出到 %s\n", len(violations), *output)
+}
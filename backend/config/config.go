@@ -1,6 +1,10 @@
 package config
 
-import "os"
+import (
+	"os"
+	"strconv"
+	"time"
+)
 
 // GetDBConfig 获取数据库连接配置
 func GetDBConfig() string {
@@ -27,3 +31,113 @@ func GetDBConfig() string {
 
 	return user + ":" + password + "@tcp(" + host + ":" + port + ")/" + dbname + "?charset=utf8mb4&parseTime=True&loc=Local"
 }
+
+// AllowNegativeStock 是否允许出库后库存为负数，默认不允许
+func AllowNegativeStock() bool {
+	return os.Getenv("ALLOW_NEGATIVE_STOCK") == "true"
+}
+
+// GetReservationTTL 获取线上订单库存预留时长，默认30分钟
+func GetReservationTTL() time.Duration {
+	if minutes, err := strconv.Atoi(os.Getenv("RESERVATION_TTL_MINUTES")); err == nil && minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return 30 * time.Minute
+}
+
+// GetUploadDir 获取上传文件保存目录，默认为 uploads
+func GetUploadDir() string {
+	if dir := os.Getenv("UPLOAD_DIR"); dir != "" {
+		return dir
+	}
+	return "uploads"
+}
+
+// StorageConfig 上传文件存储配置
+type StorageConfig struct {
+	Driver      string // local 或 s3，默认 local
+	S3Endpoint  string // S3兼容服务地址，如 http://127.0.0.1:9000
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+	S3PublicURL string // 文件公开访问地址前缀，为空时使用 S3Endpoint/S3Bucket
+}
+
+// GetStorageConfig 获取上传文件存储配置
+func GetStorageConfig() StorageConfig {
+	driver := os.Getenv("STORAGE_DRIVER")
+	if driver == "" {
+		driver = "local"
+	}
+	region := os.Getenv("S3_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+	return StorageConfig{
+		Driver:      driver,
+		S3Endpoint:  os.Getenv("S3_ENDPOINT"),
+		S3Region:    region,
+		S3Bucket:    os.Getenv("S3_BUCKET"),
+		S3AccessKey: os.Getenv("S3_ACCESS_KEY"),
+		S3SecretKey: os.Getenv("S3_SECRET_KEY"),
+		S3PublicURL: os.Getenv("S3_PUBLIC_URL"),
+	}
+}
+
+// SMTPConfig 邮件发送配置
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// GetSMTPConfig 获取邮件发送配置，SMTP_HOST 为空表示未启用邮件通知
+func GetSMTPConfig() SMTPConfig {
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "25"
+	}
+	return SMTPConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     port,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+	}
+}
+
+// GetNotifyWebhook 获取通知Webhook默认地址和签名密钥
+func GetNotifyWebhook() (url, secret string) {
+	return os.Getenv("NOTIFY_WEBHOOK_URL"), os.Getenv("NOTIFY_WEBHOOK_SECRET")
+}
+
+// GetSMSConfig 获取短信网关地址和密钥，SMS_API_URL 为空表示未启用短信通知
+func GetSMSConfig() (url, apiKey string) {
+	return os.Getenv("SMS_API_URL"), os.Getenv("SMS_API_KEY")
+}
+
+// GetQRSecretKey 获取商品二维码校验密钥
+func GetQRSecretKey() string {
+	if key := os.Getenv("QR_SECRET_KEY"); key != "" {
+		return key
+	}
+	return "your-secret-key"
+}
+
+// GetMinSaleRate 获取销售成交价相对售价的最低比例（如0.7表示最低七折），默认不限制
+func GetMinSaleRate() float64 {
+	if rate, err := strconv.ParseFloat(os.Getenv("SALE_MIN_PRICE_RATE"), 64); err == nil && rate > 0 && rate <= 1 {
+		return rate
+	}
+	return 0
+}
+
+// GetVarianceAutoApprove 获取盘点差异自动审批阈值：差异数量和成本金额（绝对值）都不超过阈值时自动审批，默认不自动审批
+func GetVarianceAutoApprove() (maxQty int, maxValue float64) {
+	maxQty, _ = strconv.Atoi(os.Getenv("VARIANCE_AUTO_APPROVE_QTY"))
+	maxValue, _ = strconv.ParseFloat(os.Getenv("VARIANCE_AUTO_APPROVE_VALUE"), 64)
+	return maxQty, maxValue
+}
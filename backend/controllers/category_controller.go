@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"errors"
+	"hd_psi/backend/models"
+	"hd_psi/backend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CategoryController struct {
+	db *gorm.DB
+}
+
+func NewCategoryController(db *gorm.DB) *CategoryController {
+	return &CategoryController{db: db}
+}
+
+// ListCategories 获取类别树
+func (cc *CategoryController) ListCategories(c *gin.Context) {
+	tree, err := services.CategoryTree(cc.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, tree)
+}
+
+// GetCategory 获取类别及其上级类别路径
+func (cc *CategoryController) GetCategory(c *gin.Context) {
+	var category models.Category
+	if err := cc.db.First(&category, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	var ancestors []models.Category
+	if err := cc.db.Where("id IN ?", services.CategoryAncestorIDs(&category)).
+		Order("depth").Find(&ancestors).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"category":  category,
+		"ancestors": ancestors, // 从根类别到本类别
+	})
+}
+
+// 类别请求参数
+type CategoryRequest struct {
+	ParentID  *uint  `json:"parent_id"` // 为空表示根类别
+	Name      string `json:"name" binding:"required"`
+	SortOrder int    `json:"sort_order"`
+}
+
+// CreateCategory 新建类别
+func (cc *CategoryController) CreateCategory(c *gin.Context) {
+	var request CategoryRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var category *models.Category
+	err := cc.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		category, err = services.CreateCategory(tx, request.ParentID, request.Name, request.SortOrder)
+		return err
+	})
+	if err != nil {
+		respondCategoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, category)
+}
+
+// UpdateCategory 修改类别名称、排序或移动到其他上级类别
+func (cc *CategoryController) UpdateCategory(c *gin.Context) {
+	var request CategoryRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var category models.Category
+	if err := cc.db.First(&category, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	err := cc.db.Transaction(func(tx *gorm.DB) error {
+		return services.UpdateCategory(tx, &category, request.Name, request.ParentID, request.SortOrder)
+	})
+	if err != nil {
+		respondCategoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, category)
+}
+
+// DeleteCategory 删除类别，类别下仍有子类别、商品或阈值设置时不能删除
+func (cc *CategoryController) DeleteCategory(c *gin.Context) {
+	var category models.Category
+	if err := cc.db.First(&category, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	err := cc.db.Transaction(func(tx *gorm.DB) error {
+		return services.DeleteCategory(tx, &category)
+	})
+	if err != nil {
+		respondCategoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Category deleted"})
+}
+
+// GetCategoryStock 按类别树逐级汇总库存数量和价值
+func (cc *CategoryController) GetCategoryStock(c *gin.Context) {
+	storeID, err := parseOptionalID(c.Query("store_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的店铺ID"})
+		return
+	}
+
+	categories, uncategorized, err := services.CategoryStockRollup(cc.db, storeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"store_id":      storeID,
+		"categories":    categories,
+		"uncategorized": uncategorized,
+	})
+}
+
+// respondCategoryError 将类别相关错误转换为HTTP响应
+func respondCategoryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrCategoryNotFound), errors.Is(err, services.ErrInvalidCategory):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCategoryExists), errors.Is(err, services.ErrCategoryInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"errors"
+	"hd_psi/backend/config"
+	"hd_psi/backend/models"
+	"hd_psi/backend/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CountSessionController struct {
+	db *gorm.DB
+}
+
+func NewCountSessionController(db *gorm.DB) *CountSessionController {
+	return &CountSessionController{db: db}
+}
+
+// 扫码会话详情响应
+type CountSessionResponse struct {
+	Session models.CountSession     `json:"session"`
+	Tally   []services.ProductTally `json:"tally"`   // 各商品有效扫码数量
+	Flagged []models.CountScan      `json:"flagged"` // 未计数的异常扫码
+}
+
+// OpenSession 为当前用户开启扫码盘点会话
+func (sc *CountSessionController) OpenSession(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return
+	}
+
+	var check models.InventoryCheck
+	if err := sc.db.First(&check, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "盘点单不存在"})
+		return
+	}
+
+	session, err := services.OpenCountSession(sc.db, &check, userID.(uint))
+	if err != nil {
+		respondSessionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, session)
+}
+
+// GetSession 获取扫码会话的扫码汇总和异常扫码
+func (sc *CountSessionController) GetSession(c *gin.Context) {
+	var session models.CountSession
+	if err := sc.db.Where("id = ? AND check_id = ?", c.Param("sessionId"), c.Param("id")).
+		First(&session).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "扫码会话不存在"})
+		return
+	}
+
+	tally, err := services.SessionTally(sc.db, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var flagged []models.CountScan
+	if err := sc.db.Where("session_id = ? AND result <> ? AND undone = ?", session.ID, models.ScanAccepted, false).
+		Order("id").Find(&flagged).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, CountSessionResponse{
+		Session: session,
+		Tally:   tally,
+		Flagged: flagged,
+	})
+}
+
+// 扫码请求
+type ScanRequest struct {
+	Payloads []string `json:"payloads" binding:"required,min=1,max=500,dive,max=512"` // 按扫码顺序排列的二维码数据
+}
+
+// Scan 记录一批扫码，返回每次扫码的识别结果
+func (sc *CountSessionController) Scan(c *gin.Context) {
+	check, sessionID, ok := sc.ownSession(c)
+	if !ok {
+		return
+	}
+
+	var request ScanRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var scans []models.CountScan
+	err := sc.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		scans, err = services.RecordScans(tx, check, sessionID, request.Payloads, config.GetQRSecretKey())
+		return err
+	})
+	if err != nil {
+		respondSessionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, scans)
+}
+
+// UndoLastScan 撤销最后一次扫码
+func (sc *CountSessionController) UndoLastScan(c *gin.Context) {
+	check, sessionID, ok := sc.ownSession(c)
+	if !ok {
+		return
+	}
+
+	var scan *models.CountScan
+	err := sc.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		scan, err = services.UndoLastScan(tx, check, sessionID)
+		return err
+	})
+	if err != nil {
+		respondSessionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, scan)
+}
+
+// CloseSession 结束扫码会话，将扫码数量计入盘点明细
+func (sc *CountSessionController) CloseSession(c *gin.Context) {
+	check, sessionID, ok := sc.ownSession(c)
+	if !ok {
+		return
+	}
+
+	var results []services.SessionCountResult
+	err := sc.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		results, err = services.CloseCountSession(tx, check, sessionID)
+		return err
+	})
+	if err != nil {
+		respondSessionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+// ownSession 读取盘点单并校验扫码会话属于当前用户
+func (sc *CountSessionController) ownSession(c *gin.Context) (*models.InventoryCheck, uint, bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return nil, 0, false
+	}
+
+	var check models.InventoryCheck
+	if err := sc.db.First(&check, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "盘点单不存在"})
+		return nil, 0, false
+	}
+
+	sessionID, err := strconv.ParseUint(c.Param("sessionId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的会话ID"})
+		return nil, 0, false
+	}
+
+	var session models.CountSession
+	if err := sc.db.Where("id = ? AND check_id = ?", sessionID, check.ID).First(&session).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "扫码会话不存在"})
+		return nil, 0, false
+	}
+	if session.CounterID != userID.(uint) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只能操作自己的扫码会话"})
+		return nil, 0, false
+	}
+
+	return &check, session.ID, true
+}
+
+// respondSessionError 将扫码会话错误转换为HTTP响应
+func respondSessionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrSessionClosed), errors.Is(err, services.ErrNothingToUndo):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPayloadTooLong):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		respondCountError(c, err)
+	}
+}
@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"errors"
+	"hd_psi/backend/models"
+	"hd_psi/backend/services"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CycleCountController struct {
+	db *gorm.DB
+}
+
+func NewCycleCountController(db *gorm.DB) *CycleCountController {
+	return &CycleCountController{db: db}
+}
+
+// 商品ABC分类列表请求参数
+type ListProductClassesQuery struct {
+	StoreID  uint   `form:"store_id"`
+	Class    string `form:"class"`
+	Page     int    `form:"page,default=1"`
+	PageSize int    `form:"page_size,default=50"`
+}
+
+// 商品ABC分类列表响应
+type ProductClassesResponse struct {
+	Total int                   `json:"total"`
+	Items []models.ProductClass `json:"items"`
+}
+
+// ListProductClasses 获取店铺商品的ABC分类
+func (cc *CycleCountController) ListProductClasses(c *gin.Context) {
+	var query ListProductClassesQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := cc.db.Model(&models.ProductClass{})
+	if query.StoreID != 0 {
+		db = db.Where("store_id = ?", query.StoreID)
+	}
+	if query.Class != "" {
+		db = db.Where("class = ?", query.Class)
+	}
+
+	var total int64
+	db.Count(&total)
+
+	offset := (query.Page - 1) * query.PageSize
+	var classes []models.ProductClass
+	if err := db.Offset(offset).Limit(query.PageSize).
+		Order("store_id, `rank`").
+		Find(&classes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductClassesResponse{
+		Total: int(total),
+		Items: classes,
+	})
+}
+
+// ABC分类请求
+type ClassifyProductsRequest struct {
+	StoreID uint `json:"store_id"` // 为0时分类所有店铺
+	Days    int  `json:"days"`     // 销售额统计天数，默认90天
+}
+
+// ClassifyProducts 重新计算商品ABC分类，请求体可省略
+func (cc *CycleCountController) ClassifyProducts(c *gin.Context) {
+	var request ClassifyProductsRequest
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	count, err := services.ClassifyProducts(cc.db, request.StoreID, request.Days)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"classified": count})
+}
+
+// 循环盘点计划请求
+type PlanCycleCountsRequest struct {
+	StoreID uint   `json:"store_id"` // 为0时为所有已分类的店铺生成
+	Period  string `json:"period"`   // 盘点月份（YYYY-MM），默认当月
+}
+
+// PlanCycleCounts 生成循环盘点抽盘单，请求体可省略
+func (cc *CycleCountController) PlanCycleCounts(c *gin.Context) {
+	var request PlanCycleCountsRequest
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	period, ok := parsePeriod(c, request.Period)
+	if !ok {
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return
+	}
+
+	plans, err := services.PlanCycleCounts(cc.db, period, request.StoreID, userID.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, plans)
+}
+
+// GetCycleCoverage 获取循环盘点覆盖率
+func (cc *CycleCountController) GetCycleCoverage(c *gin.Context) {
+	period, ok := parsePeriod(c, c.Query("period"))
+	if !ok {
+		return
+	}
+
+	storeID, err := parseOptionalID(c.Query("store_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的店铺ID"})
+		return
+	}
+
+	coverage, err := services.CycleCountCoverage(cc.db, period, storeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, coverage)
+}
+
+// parsePeriod 解析YYYY-MM格式的月份，为空时返回当前时间
+func parsePeriod(c *gin.Context, value string) (time.Time, bool) {
+	if value == "" {
+		return time.Now(), true
+	}
+	period, err := time.ParseInLocation("2006-01", value, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "月份格式错误，应为YYYY-MM"})
+		return time.Time{}, false
+	}
+	return period, true
+}
@@ -0,0 +1,312 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"hd_psi/backend/media"
+	"hd_psi/backend/models"
+	"hd_psi/backend/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type DamageController struct {
+	db     *gorm.DB
+	ledger *services.InventoryLedger
+	store  media.Storage
+}
+
+func NewDamageController(db *gorm.DB, store media.Storage) *DamageController {
+	return &DamageController{db: db, ledger: services.NewInventoryLedger(db), store: store}
+}
+
+// 报损单列表请求参数
+type ListDamageReportsQuery struct {
+	StoreID    uint   `form:"store_id"`
+	ProductID  uint   `form:"product_id"`
+	Status     string `form:"status"`
+	ReasonCode string `form:"reason_code"`
+	StartDate  string `form:"start_date"`
+	EndDate    string `form:"end_date"`
+	Page       int    `form:"page,default=1"`
+	PageSize   int    `form:"page_size,default=10"`
+}
+
+// 报损单列表响应
+type DamageReportsResponse struct {
+	Total int                   `json:"total"`
+	Items []models.DamageReport `json:"items"`
+}
+
+// ListDamageReports 获取报损单列表
+func (dc *DamageController) ListDamageReports(c *gin.Context) {
+	var query ListDamageReportsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 构建查询
+	db := dc.db.Model(&models.DamageReport{})
+
+	// 应用过滤条件
+	if query.StoreID != 0 {
+		db = db.Where("store_id = ?", query.StoreID)
+	}
+	if query.ProductID != 0 {
+		db = db.Where("product_id = ?", query.ProductID)
+	}
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+	if query.ReasonCode != "" {
+		db = db.Where("reason_code = ?", query.ReasonCode)
+	}
+	if query.StartDate != "" {
+		db = db.Where("created_at >= ?", query.StartDate)
+	}
+	if query.EndDate != "" {
+		db = db.Where("created_at <= ?", query.EndDate+" 23:59:59")
+	}
+
+	// 计算总数
+	var total int64
+	db.Count(&total)
+
+	// 分页
+	offset := (query.Page - 1) * query.PageSize
+	var reports []models.DamageReport
+
+	if err := db.Preload("Product").Preload("Store").
+		Offset(offset).Limit(query.PageSize).
+		Order("created_at DESC").
+		Find(&reports).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, DamageReportsResponse{
+		Total: int(total),
+		Items: reports,
+	})
+}
+
+// GetDamageReport 获取报损单详情
+func (dc *DamageController) GetDamageReport(c *gin.Context) {
+	id := c.Param("id")
+	var report models.DamageReport
+	if err := dc.db.Preload("Product").Preload("Store").First(&report, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "报损单不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// 创建报损单请求
+type CreateDamageReportRequest struct {
+	StoreID     uint   `json:"store_id" binding:"required"`
+	ProductID   uint   `json:"product_id" binding:"required"`
+	Quantity    int    `json:"quantity" binding:"required,min=1"`
+	BatchNumber string `json:"batch_number"`
+	ReasonCode  string `json:"reason_code" binding:"required"`
+	Description string `json:"description"`
+}
+
+// CreateDamageReport 创建报损单，审批通过后才扣减库存
+func (dc *DamageController) CreateDamageReport(c *gin.Context) {
+	var request CreateDamageReportRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reason := models.DamageReason(request.ReasonCode)
+	if !reason.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的报损原因"})
+		return
+	}
+
+	// 获取当前用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return
+	}
+
+	// 生成报损单号
+	reportNumber := fmt.Sprintf("DR%s%04d", time.Now().Format("20060102"), 1)
+
+	// 查询当天最后一个报损单号
+	var lastReport models.DamageReport
+	dc.db.Where("report_number LIKE ?", "DR"+time.Now().Format("20060102")+"%").
+		Order("report_number DESC").
+		Limit(1).
+		Find(&lastReport)
+
+	if lastReport.ID != 0 {
+		// 提取序号并加1
+		seq, _ := strconv.Atoi(lastReport.ReportNumber[10:])
+		reportNumber = fmt.Sprintf("DR%s%04d", time.Now().Format("20060102"), seq+1)
+	}
+
+	report := models.DamageReport{
+		ReportNumber: reportNumber,
+		StoreID:      request.StoreID,
+		ProductID:    request.ProductID,
+		Quantity:     request.Quantity,
+		BatchNumber:  request.BatchNumber,
+		ReasonCode:   reason,
+		Description:  request.Description,
+		Status:       models.DamagePending,
+		ReporterID:   userID.(uint),
+	}
+
+	if err := dc.db.Create(&report).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建报损单失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, report)
+}
+
+// UploadDamagePhoto 上传报损照片，表单字段为 photo
+func (dc *DamageController) UploadDamagePhoto(c *gin.Context) {
+	id := c.Param("id")
+	var report models.DamageReport
+	if err := dc.db.First(&report, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "报损单不存在"})
+		return
+	}
+
+	if report.Status != models.DamagePending {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "只有待审批的报损单可以上传照片"})
+		return
+	}
+
+	file, err := c.FormFile("photo")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请选择要上传的照片"})
+		return
+	}
+
+	photo, err := media.SaveImage(dc.store, file, "damage", report.ReportNumber)
+	if err != nil {
+		if errors.Is(err, media.ErrInvalidImage) || errors.Is(err, media.ErrImageTooLarge) || errors.Is(err, media.ErrImageDimensions) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	report.PhotoURL = photo.URL
+	if err := dc.db.Save(&report).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新报损单失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// 报损审批请求
+type DamageApprovalRequest struct {
+	Note string `json:"note"`
+}
+
+// ApproveDamageReport 审批报损单，按当前平均成本记报损出库
+func (dc *DamageController) ApproveDamageReport(c *gin.Context) {
+	dc.reviewDamageReport(c, true)
+}
+
+// RejectDamageReport 驳回报损单
+func (dc *DamageController) RejectDamageReport(c *gin.Context) {
+	dc.reviewDamageReport(c, false)
+}
+
+// reviewDamageReport 审批或驳回报损单
+func (dc *DamageController) reviewDamageReport(c *gin.Context, approve bool) {
+	id := c.Param("id")
+
+	var request DamageApprovalRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 获取当前用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return
+	}
+
+	// 开始事务
+	tx := dc.db.Begin()
+
+	// 锁定报损单，避免重复审批
+	var report models.DamageReport
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&report, id).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "报损单不存在"})
+		return
+	}
+
+	if report.Status != models.DamagePending {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "只有待审批的报损单可以审批"})
+		return
+	}
+
+	if approve {
+		transaction := models.InventoryTransaction{
+			TransactionType: models.DamageOut,
+			ProductID:       report.ProductID,
+			StoreID:         report.StoreID,
+			Quantity:        -report.Quantity, // 负数表示出库
+			BatchNumber:     report.BatchNumber,
+			ReferenceID:     &report.ID,
+			ReferenceType:   "damage_report",
+			OperatorID:      userID.(uint),
+			Note:            fmt.Sprintf("报损出库: %s", report.ReportNumber),
+		}
+
+		if _, err := dc.ledger.Post(tx, &transaction); err != nil {
+			tx.Rollback()
+			respondLedgerError(c, err)
+			return
+		}
+
+		report.Status = models.DamageApproved
+		report.UnitCost = transaction.UnitCost
+		report.TotalCost = transaction.UnitCost * float64(report.Quantity)
+		report.TransactionID = &transaction.ID
+	} else {
+		report.Status = models.DamageRejected
+	}
+
+	now := time.Now()
+	approverID := userID.(uint)
+	report.ApproverID = &approverID
+	report.ApprovalTime = &now
+	report.ApprovalNote = request.Note
+
+	if err := tx.Save(&report).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新报损单失败: " + err.Error()})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "提交事务失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
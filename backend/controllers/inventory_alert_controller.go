@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"hd_psi/backend/models"
+	"hd_psi/backend/services"
 	"net/http"
 	"time"
 
@@ -25,6 +26,11 @@ func (iac *InventoryAlertController) ListAlerts(c *gin.Context) {
 	status := c.Query("status")
 	storeID := c.Query("store_id")
 	alertType := c.Query("alert_type")
+	categoryID, err := parseOptionalID(c.Query("category_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的类别ID"})
+		return
+	}
 	
 	// 构建查询
 	query := iac.db.Model(&models.InventoryAlert{})
@@ -41,6 +47,10 @@ func (iac *InventoryAlertController) ListAlerts(c *gin.Context) {
 		query = query.Where("alert_type = ?", alertType)
 	}
 	
+	if categoryID != 0 {
+		query = services.ApplyCategoryFilter(query, "category_id", categoryID)
+	}
+	
 	// 执行查询
 	if err := query.Find(&alerts).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -98,126 +108,24 @@ func (iac *InventoryAlertController) UpdateAlertStatus(c *gin.Context) {
 }
 
 // CheckInventoryLevels 检查库存水平并生成预警
-// 这个方法可以通过定时任务调用，或者在库存变动后调用
+// 库存变动时会自动评估预警，这个方法用于修改阈值后全量重新评估，可按 store_id 限定店铺
 func (iac *InventoryAlertController) CheckInventoryLevels(c *gin.Context) {
-	// 获取所有库存记录
-	var inventories []struct {
-		StoreID   uint
-		ProductID uint
-		Category  string
-		Quantity  int
+	storeID, err := parseOptionalID(c.Query("store_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的店铺ID"})
+		return
 	}
-	
-	// 联合查询获取库存和商品类别
-	if err := iac.db.Table("inventories").
-		Select("inventories.store_id, inventories.product_id, products.category, inventories.quantity").
-		Joins("JOIN products ON inventories.product_id = products.id").
-		Scan(&inventories).Error; err != nil {
+
+	changes, err := services.EvaluateInventoryAlerts(iac.db, storeID)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	
-	// 获取所有预警阈值设置
-	var thresholds []struct {
-		StoreID   uint
-		Category  string
-		LowLevel  int
-		HighLevel int
-	}
-	
-	// 这里假设有一个阈值设置表，实际中需要创建这个表
-	// 如果没有这个表，可以使用默认值或配置文件中的设置
-	if err := iac.db.Table("inventory_thresholds").Find(&thresholds).Error; err != nil {
-		// 如果表不存在，使用默认值
-		thresholds = []struct {
-			StoreID   uint
-			Category  string
-			LowLevel  int
-			HighLevel int
-		}{
-			{StoreID: 0, Category: "", LowLevel: 10, HighLevel: 100}, // 默认阈值
-		}
-	}
-	
-	// 检查每个库存记录
-	var newAlerts []models.InventoryAlert
-	for _, inv := range inventories {
-		// 查找适用的阈值
-		var threshold struct {
-			LowLevel  int
-			HighLevel int
-		}
-		
-		// 首先尝试找到特定店铺和类别的阈值
-		found := false
-		for _, t := range thresholds {
-			if (t.StoreID == inv.StoreID || t.StoreID == 0) && 
-			   (t.Category == inv.Category || t.Category == "") {
-				threshold.LowLevel = t.LowLevel
-				threshold.HighLevel = t.HighLevel
-				found = true
-				break
-			}
-		}
-		
-		if !found {
-			// 使用默认阈值
-			threshold.LowLevel = 10
-			threshold.HighLevel = 100
-		}
-		
-		// 检查是否低于最低阈值
-		if inv.Quantity <= threshold.LowLevel {
-			// 检查是否已存在活跃的低库存预警
-			var existingAlert models.InventoryAlert
-			result := iac.db.Where("store_id = ? AND product_id = ? AND alert_type = ? AND status = ?", 
-				inv.StoreID, inv.ProductID, models.LowStock, models.Active).First(&existingAlert)
-			
-			if result.Error == gorm.ErrRecordNotFound {
-				// 创建新预警
-				newAlerts = append(newAlerts, models.InventoryAlert{
-					StoreID:     inv.StoreID,
-					ProductID:   inv.ProductID,
-					Category:    inv.Category,
-					AlertType:   models.LowStock,
-					Threshold:   threshold.LowLevel,
-					CurrentQty:  inv.Quantity,
-					Status:      models.Active,
-					Description: "库存低于最低阈值",
-				})
-			}
-		} else if inv.Quantity >= threshold.HighLevel {
-			// 检查是否已存在活跃的高库存预警
-			var existingAlert models.InventoryAlert
-			result := iac.db.Where("store_id = ? AND product_id = ? AND alert_type = ? AND status = ?", 
-				inv.StoreID, inv.ProductID, models.Overstock, models.Active).First(&existingAlert)
-			
-			if result.Error == gorm.ErrRecordNotFound {
-				// 创建新预警
-				newAlerts = append(newAlerts, models.InventoryAlert{
-					StoreID:     inv.StoreID,
-					ProductID:   inv.ProductID,
-					Category:    inv.Category,
-					AlertType:   models.Overstock,
-					Threshold:   threshold.HighLevel,
-					CurrentQty:  inv.Quantity,
-					Status:      models.Active,
-					Description: "库存超过最高阈值",
-				})
-			}
-		}
-	}
-	
-	// 批量创建新预警
-	if len(newAlerts) > 0 {
-		if err := iac.db.Create(&newAlerts).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-	}
-	
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "库存检查完成",
-		"new_alerts": len(newAlerts),
+		"message":         "库存检查完成",
+		"new_alerts":      changes.Created,
+		"updated_alerts":  changes.Updated,
+		"resolved_alerts": changes.Resolved,
 	})
 }
@@ -1,9 +1,13 @@
 package controllers
 
 import (
-	"fmt"
+	"errors"
+	"hd_psi/backend/config"
 	"hd_psi/backend/models"
+	"hd_psi/backend/notify"
+	"hd_psi/backend/services"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -11,11 +15,12 @@ import (
 )
 
 type InventoryCheckController struct {
-	db *gorm.DB
+	db     *gorm.DB
+	ledger *services.InventoryLedger
 }
 
 func NewInventoryCheckController(db *gorm.DB) *InventoryCheckController {
-	return &InventoryCheckController{db: db}
+	return &InventoryCheckController{db: db, ledger: services.NewInventoryLedger(db)}
 }
 
 // ListChecks 获取盘点单列表
@@ -67,12 +72,50 @@ func (icc *InventoryCheckController) GetCheck(c *gin.Context) {
 		return
 	}
 	
+	// 盲盘完成前不显示系统库存
+	if isBlindCounting(&check) {
+		c.JSON(http.StatusOK, gin.H{
+			"check": check,
+			"items": blindCheckItems(items),
+		})
+		return
+	}
+	
 	c.JSON(http.StatusOK, gin.H{
 		"check": check,
 		"items": items,
 	})
 }
 
+// 盲盘明细，不包含系统库存和计数结果
+type BlindCheckItem struct {
+	ID        uint   `json:"ID"`
+	CheckID   uint   `json:"CheckID"`
+	ProductID uint   `json:"ProductID"`
+	Status    string `json:"Status"`
+	Note      string `json:"Note"`
+}
+
+// isBlindCounting 盘点单是否为未完成的盲盘
+func isBlindCounting(check *models.InventoryCheck) bool {
+	return check.BlindCount && check.Status != models.Completed && check.Status != models.Cancelled
+}
+
+// blindCheckItems 隐藏盘点明细中的系统库存和计数结果
+func blindCheckItems(items []models.InventoryCheckItem) []BlindCheckItem {
+	blind := make([]BlindCheckItem, 0, len(items))
+	for _, item := range items {
+		blind = append(blind, BlindCheckItem{
+			ID:        item.ID,
+			CheckID:   item.CheckID,
+			ProductID: item.ProductID,
+			Status:    item.Status,
+			Note:      item.Note,
+		})
+	}
+	return blind
+}
+
 // CreateCheck 创建盘点单
 func (icc *InventoryCheckController) CreateCheck(c *gin.Context) {
 	var input struct {
@@ -80,6 +123,8 @@ func (icc *InventoryCheckController) CreateCheck(c *gin.Context) {
 		CheckType   string    `json:"check_type" binding:"required"`
 		PlanDate    time.Time `json:"plan_date" binding:"required"`
 		OperatorID  uint      `json:"operator_id" binding:"required"`
+		BlindCount  bool      `json:"blind_count"` // 盲盘
+		FreezeStock bool      `json:"freeze_stock"` // 全盘期间冻结调拨和采购入库
 		Description string    `json:"description"`
 		ProductIDs  []uint    `json:"product_ids"` // 抽盘时指定的商品ID列表
 	}
@@ -89,99 +134,36 @@ func (icc *InventoryCheckController) CreateCheck(c *gin.Context) {
 		return
 	}
 	
-	// 开始事务
-	tx := icc.db.Begin()
-	
-	// 生成盘点单号 (格式: IC + 年月日 + 4位序号)
-	now := time.Now()
-	var count int64
-	tx.Model(&models.InventoryCheck{}).Where("DATE(created_at) = DATE(?)", now).Count(&count)
-	checkCode := fmt.Sprintf("IC%s%04d", now.Format("20060102"), count+1)
+	if input.FreezeStock && models.CheckType(input.CheckType) != models.FullCheck {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only full checks can freeze stock"})
+		return
+	}
 	
-	// 创建盘点单
+	// 创建盘点单和盘点明细
 	check := models.InventoryCheck{
 		StoreID:     input.StoreID,
-		CheckCode:   checkCode,
 		CheckType:   models.CheckType(input.CheckType),
-		Status:      models.Planned,
 		PlanDate:    input.PlanDate,
 		OperatorID:  input.OperatorID,
+		BlindCount:  input.BlindCount,
+		FreezeStock: input.FreezeStock,
 		Description: input.Description,
 	}
 	
-	if err := tx.Create(&check).Error; err != nil {
-		tx.Rollback()
+	var itemsCount int
+	err := icc.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		itemsCount, err = services.CreateInventoryCheck(tx, &check, input.ProductIDs)
+		return err
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create inventory check: " + err.Error()})
 		return
 	}
 	
-	// 创建盘点明细
-	var items []models.InventoryCheckItem
-	
-	// 根据盘点类型获取商品
-	if check.CheckType == models.FullCheck {
-		// 全盘：获取该店铺所有库存商品
-		var inventories []struct {
-			ProductID uint
-			Quantity  int
-		}
-		
-		if err := tx.Table("inventories").
-			Select("product_id, quantity").
-			Where("store_id = ? AND quantity > 0", check.StoreID).
-			Scan(&inventories).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get inventory data: " + err.Error()})
-			return
-		}
-		
-		for _, inv := range inventories {
-			items = append(items, models.InventoryCheckItem{
-				CheckID:        check.ID,
-				ProductID:      inv.ProductID,
-				SystemQuantity: inv.Quantity,
-				Status:         "pending",
-			})
-		}
-	} else if check.CheckType == models.SpotCheck && len(input.ProductIDs) > 0 {
-		// 抽盘：获取指定商品的库存
-		for _, productID := range input.ProductIDs {
-			var inventory models.Inventory
-			result := tx.Where("store_id = ? AND product_id = ?", check.StoreID, productID).First(&inventory)
-			
-			if result.Error == nil {
-				items = append(items, models.InventoryCheckItem{
-					CheckID:        check.ID,
-					ProductID:      productID,
-					SystemQuantity: inventory.Quantity,
-					Status:         "pending",
-				})
-			} else if result.Error != gorm.ErrRecordNotFound {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get inventory data: " + result.Error.Error()})
-				return
-			}
-		}
-	}
-	
-	// 批量创建盘点明细
-	if len(items) > 0 {
-		if err := tx.Create(&items).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create inventory check items: " + err.Error()})
-			return
-		}
-	}
-	
-	// 提交事务
-	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction: " + err.Error()})
-		return
-	}
-	
 	c.JSON(http.StatusCreated, gin.H{
 		"check": check,
-		"items_count": len(items),
+		"items_count": itemsCount,
 	})
 }
 
@@ -200,12 +182,18 @@ func (icc *InventoryCheckController) StartCheck(c *gin.Context) {
 		return
 	}
 	
-	// 更新状态
+	// 更新状态并记录截止快照，之后的库存变动计入截止后变动
 	now := time.Now()
 	check.Status = models.InProcess
 	check.StartTime = &now
 	
-	if err := icc.db.Save(&check).Error; err != nil {
+	err := icc.db.Transaction(func(tx *gorm.DB) error {
+		if err := services.CaptureCutoff(tx, &check); err != nil {
+			return err
+		}
+		return tx.Save(&check).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -231,6 +219,12 @@ func (icc *InventoryCheckController) UpdateCheckItem(c *gin.Context) {
 		return
 	}
 	
+	// 盲盘需要两人独立计数
+	if check.BlindCount {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Blind count items must be submitted as counts"})
+		return
+	}
+	
 	// 验证盘点明细存在
 	var item models.InventoryCheckItem
 	if err := icc.db.Where("id = ? AND check_id = ?", itemID, checkID).First(&item).Error; err != nil {
@@ -249,11 +243,12 @@ func (icc *InventoryCheckController) UpdateCheckItem(c *gin.Context) {
 		return
 	}
 	
-	// 更新盘点明细
-	item.ActualQuantity = input.ActualQuantity
-	item.DifferenceQty = input.ActualQuantity - item.SystemQuantity
+	// 更新盘点明细，差异扣除截止后的库存变动
+	if err := services.ApplyCount(icc.db, &check, &item, input.ActualQuantity); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	item.Note = input.Note
-	item.Status = "checked"
 	
 	if err := icc.db.Save(&item).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -263,6 +258,175 @@ func (icc *InventoryCheckController) UpdateCheckItem(c *gin.Context) {
 	c.JSON(http.StatusOK, item)
 }
 
+// ListCutoffMovements 获取盘点截止后盘点商品的库存变动
+func (icc *InventoryCheckController) ListCutoffMovements(c *gin.Context) {
+	var check models.InventoryCheck
+	if err := icc.db.First(&check, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Inventory check not found"})
+		return
+	}
+	
+	// 盲盘完成前不提供截止后变动，避免计数人据此推算系统库存
+	if isBlindCounting(&check) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cutoff movements are not available until the blind count is completed"})
+		return
+	}
+	
+	if check.CutoffAt == nil {
+		c.JSON(http.StatusOK, []models.InventoryTransaction{})
+		return
+	}
+	
+	query := icc.db.Where("store_id = ? AND id > ? AND bucket = ? AND transaction_type <> ?",
+		check.StoreID, check.CutoffTransactionID, models.BucketSellable, models.LedgerCorrection).
+		Where("product_id IN (?)", icc.db.Model(&models.InventoryCheckItem{}).Select("product_id").Where("check_id = ?", check.ID))
+	if productID := c.Query("product_id"); productID != "" {
+		query = query.Where("product_id = ?", productID)
+	}
+	
+	var transactions []models.InventoryTransaction
+	if err := query.Order("id").Find(&transactions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	
+	c.JSON(http.StatusOK, transactions)
+}
+
+// SubmitCount 提交盲盘计数，每个商品需要两人独立计数
+func (icc *InventoryCheckController) SubmitCount(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return
+	}
+	
+	var check models.InventoryCheck
+	if err := icc.db.First(&check, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Inventory check not found"})
+		return
+	}
+	
+	itemID, err := strconv.ParseUint(c.Param("itemId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid inventory check item ID"})
+		return
+	}
+	
+	var input struct {
+		Quantity *int   `json:"quantity" binding:"required"`
+		Note     string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	
+	var result *services.CountResult
+	err = icc.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		result, err = services.SubmitBlindCount(tx, &check, uint(itemID), userID.(uint), *input.Quantity, input.Note)
+		return err
+	})
+	if err != nil {
+		respondCountError(c, err)
+		return
+	}
+	
+	c.JSON(http.StatusCreated, result)
+}
+
+// ListRecountTasks 获取盘点单的复盘任务，盲盘完成前不显示两人的计数
+func (icc *InventoryCheckController) ListRecountTasks(c *gin.Context) {
+	var check models.InventoryCheck
+	if err := icc.db.First(&check, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Inventory check not found"})
+		return
+	}
+	
+	query := icc.db.Where("check_id = ?", check.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	
+	var tasks []models.InventoryRecountTask
+	if err := query.Order("id").Find(&tasks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	
+	if isBlindCounting(&check) {
+		for i := range tasks {
+			tasks[i].FirstCount = 0
+			tasks[i].SecondCount = 0
+		}
+	}
+	
+	c.JSON(http.StatusOK, tasks)
+}
+
+// CompleteRecountTask 提交复盘数量，复盘数量作为最终实盘数量
+func (icc *InventoryCheckController) CompleteRecountTask(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return
+	}
+	
+	var check models.InventoryCheck
+	if err := icc.db.First(&check, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Inventory check not found"})
+		return
+	}
+	
+	taskID, err := strconv.ParseUint(c.Param("taskId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recount task ID"})
+		return
+	}
+	
+	var input struct {
+		Quantity *int   `json:"quantity" binding:"required"`
+		Note     string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	
+	var task *models.InventoryRecountTask
+	err = icc.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		task, err = services.CompleteRecount(tx, &check, uint(taskID), userID.(uint), *input.Quantity, input.Note)
+		return err
+	})
+	if err != nil {
+		respondCountError(c, err)
+		return
+	}
+	
+	c.JSON(http.StatusOK, task)
+}
+
+// respondCountError 将盲盘计数错误转换为HTTP响应
+func respondCountError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Inventory check item or recount task not found"})
+	case errors.Is(err, services.ErrNotBlindCount),
+		errors.Is(err, services.ErrCheckNotInProcess),
+		errors.Is(err, services.ErrInvalidQuantity),
+		errors.Is(err, services.ErrItemChecked):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSameCounter),
+		errors.Is(err, services.ErrAwaitingRecount),
+		errors.Is(err, services.ErrRecountCompleted):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
 // CompleteCheck 完成盘点
 func (icc *InventoryCheckController) CompleteCheck(c *gin.Context) {
 	id := c.Param("id")
@@ -278,10 +442,10 @@ func (icc *InventoryCheckController) CompleteCheck(c *gin.Context) {
 		return
 	}
 	
-	// 检查是否所有明细都已盘点
+	// 检查是否所有明细都已盘点（盲盘包括计数中和待复盘的明细）
 	var pendingCount int64
 	if err := icc.db.Model(&models.InventoryCheckItem{}).
-		Where("check_id = ? AND status = 'pending'", check.ID).
+		Where("check_id = ? AND status <> ?", check.ID, models.CheckItemChecked).
 		Count(&pendingCount).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -384,10 +548,25 @@ func (icc *InventoryCheckController) CreateAdjustment(c *gin.Context) {
 		ProductID:      item.ProductID,
 		AdjustQuantity: input.AdjustQuantity,
 		Reason:         input.Reason,
-		ApprovalStatus: "pending",
+		ApprovalStatus: models.AdjustmentPending,
 	}
 	
-	if err := icc.db.Create(&adjustment).Error; err != nil {
+	// 创建调整记录并通知审批人
+	err := icc.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&adjustment).Error; err != nil {
+			return err
+		}
+		return notify.Enqueue(tx, models.EventCheckAdjustmentPending, &check.StoreID, map[string]interface{}{
+			"adjustment_id":   adjustment.ID,
+			"check_id":        check.ID,
+			"check_code":      check.CheckCode,
+			"store_id":        check.StoreID,
+			"product_id":      adjustment.ProductID,
+			"adjust_quantity": adjustment.AdjustQuantity,
+			"reason":          adjustment.Reason,
+		})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -397,21 +576,12 @@ func (icc *InventoryCheckController) CreateAdjustment(c *gin.Context) {
 
 // ApproveAdjustment 审批库存调整
 func (icc *InventoryCheckController) ApproveAdjustment(c *gin.Context) {
-	adjustmentID := c.Param("adjustmentId")
-	
-	// 验证调整记录存在
-	var adjustment models.InventoryCheckAdjustment
-	if err := icc.db.First(&adjustment, adjustmentID).Error; err != nil {
+	adjustmentID, err := strconv.ParseUint(c.Param("adjustmentId"), 10, 32)
+	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Adjustment not found"})
 		return
 	}
 	
-	// 检查状态
-	if adjustment.ApprovalStatus != "pending" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Only pending adjustments can be approved"})
-		return
-	}
-	
 	// 绑定请求数据
 	var input struct {
 		ApproverID     uint   `json:"approver_id" binding:"required"`
@@ -424,100 +594,175 @@ func (icc *InventoryCheckController) ApproveAdjustment(c *gin.Context) {
 		return
 	}
 	
-	// 开始事务
-	tx := icc.db.Begin()
+	// 审批调整记录，审批通过时更新库存；调整记录在事务中加锁读取并校验状态
+	adjustment := models.InventoryCheckAdjustment{ID: uint(adjustmentID)}
+	err = icc.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("check_id").First(&adjustment, adjustment.ID).Error; err != nil {
+			return err
+		}
+		var check models.InventoryCheck
+		if err := tx.First(&check, adjustment.CheckID).Error; err != nil {
+			return err
+		}
+		return services.ReviewAdjustment(tx, icc.ledger, &check, &adjustment, input.ApproverID, input.ApprovalStatus, input.ApprovalNote)
+	})
+	if err != nil {
+		respondAdjustmentError(c, err)
+		return
+	}
 	
-	// 更新调整记录
-	now := time.Now()
-	adjustment.ApproverID = input.ApproverID
-	adjustment.ApprovalStatus = input.ApprovalStatus
-	adjustment.ApprovalNote = input.ApprovalNote
-	adjustment.ApprovalTime = &now
+	c.JSON(http.StatusOK, adjustment)
+}
+
+// GetVariance 获取盘点差异报告，差异按成本和零售价计价
+func (icc *InventoryCheckController) GetVariance(c *gin.Context) {
+	var check models.InventoryCheck
+	if err := icc.db.First(&check, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Inventory check not found"})
+		return
+	}
 	
-	if err := tx.Save(&adjustment).Error; err != nil {
-		tx.Rollback()
+	// 盲盘完成前不显示差异
+	if isBlindCounting(&check) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Variance is not available until the blind count is completed"})
+		return
+	}
+	
+	report, err := services.CheckVariance(icc.db, &check)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	
-	// 如果审批通过，更新库存
-	if input.ApprovalStatus == "approved" {
-		// 获取盘点单信息
-		var check models.InventoryCheck
-		if err := tx.First(&check, adjustment.CheckID).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get inventory check: " + err.Error()})
-			return
+	c.JSON(http.StatusOK, report)
+}
+
+// 生成盘点调整请求，阈值为空时使用系统配置
+type GenerateAdjustmentsRequest struct {
+	AutoApproveQty   *int     `json:"auto_approve_qty"`   // 自动审批的最大差异数量，0表示不自动审批
+	AutoApproveValue *float64 `json:"auto_approve_value"` // 自动审批的最大差异成本金额
+}
+
+// GenerateAdjustments 为盘点差异批量创建调整，阈值内的差异自动审批并过账
+func (icc *InventoryCheckController) GenerateAdjustments(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return
+	}
+	
+	var check models.InventoryCheck
+	if err := icc.db.First(&check, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Inventory check not found"})
+		return
+	}
+	
+	var input GenerateAdjustmentsRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	
+	var rule services.AutoApproveRule
+	rule.MaxQty, rule.MaxValue = config.GetVarianceAutoApprove()
+	if input.AutoApproveQty != nil {
+		rule.MaxQty = *input.AutoApproveQty
+	}
+	if input.AutoApproveValue != nil {
+		rule.MaxValue = *input.AutoApproveValue
+	}
+	
+	var adjustments []models.InventoryCheckAdjustment
+	err := icc.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		adjustments, err = services.GenerateAdjustments(tx, icc.ledger, &check, rule, userID.(uint))
+		if err != nil {
+			return err
 		}
 		
-		// 更新库存
-		var inventory models.Inventory
-		result := tx.Where("store_id = ? AND product_id = ?", check.StoreID, adjustment.ProductID).First(&inventory)
-		
-		if result.Error != nil {
-			if result.Error == gorm.ErrRecordNotFound {
-				// 如果库存记录不存在且是增加库存，则创建新记录
-				if adjustment.AdjustQuantity > 0 {
-					newInventory := models.Inventory{
-						StoreID:   check.StoreID,
-						ProductID: adjustment.ProductID,
-						Quantity:  adjustment.AdjustQuantity,
-					}
-					if err := tx.Create(&newInventory).Error; err != nil {
-						tx.Rollback()
-						c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create inventory: " + err.Error()})
-						return
-					}
-				} else {
-					tx.Rollback()
-					c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot adjust non-existent inventory"})
-					return
-				}
-			} else {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get inventory: " + result.Error.Error()})
-				return
-			}
-		} else {
-			// 更新现有库存
-			inventory.Quantity += adjustment.AdjustQuantity
-			if inventory.Quantity < 0 {
-				tx.Rollback()
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Adjustment would result in negative inventory"})
-				return
+		// 通知审批人处理未自动审批的调整
+		for _, adjustment := range adjustments {
+			if adjustment.ApprovalStatus != models.AdjustmentPending {
+				continue
 			}
-			
-			if err := tx.Save(&inventory).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update inventory: " + err.Error()})
-				return
+			if err := notify.Enqueue(tx, models.EventCheckAdjustmentPending, &check.StoreID, map[string]interface{}{
+				"adjustment_id":   adjustment.ID,
+				"check_id":        check.ID,
+				"check_code":      check.CheckCode,
+				"store_id":        check.StoreID,
+				"product_id":      adjustment.ProductID,
+				"adjust_quantity": adjustment.AdjustQuantity,
+				"reason":          adjustment.Reason,
+			}); err != nil {
+				return err
 			}
 		}
-		
-		// 创建库存交易记录
-		transaction := models.InventoryTransaction{
-			TransactionType: models.TransactionType("inventory_check"),
-			ProductID:       adjustment.ProductID,
-			StoreID:         check.StoreID,
-			Quantity:        adjustment.AdjustQuantity,
-			ReferenceID:     &adjustment.ID,
-			ReferenceType:   "inventory_check_adjustment",
-			OperatorID:      input.ApproverID,
-			Note:            "盘点调整: " + adjustment.Reason,
-		}
-		
-		if err := tx.Create(&transaction).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transaction: " + err.Error()})
-			return
+		return nil
+	})
+	if err != nil {
+		respondAdjustmentError(c, err)
+		return
+	}
+	
+	autoApproved := 0
+	for _, adjustment := range adjustments {
+		if adjustment.AutoApproved {
+			autoApproved++
 		}
 	}
 	
-	// 提交事务
-	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction: " + err.Error()})
+	c.JSON(http.StatusCreated, gin.H{
+		"created":       len(adjustments),
+		"auto_approved": autoApproved,
+		"adjustments":   adjustments,
+	})
+}
+
+// 批量审批盘点调整请求
+type BulkReviewAdjustmentsRequest struct {
+	AdjustmentIDs  []uint `json:"adjustment_ids" binding:"required,min=1"`
+	ApprovalStatus string `json:"approval_status" binding:"required,oneof=approved rejected"`
+	ApprovalNote   string `json:"approval_note"`
+}
+
+// BulkReviewAdjustments 批量审批盘点调整，所有调整在同一事务中审批和过账，任一失败则全部不生效
+func (icc *InventoryCheckController) BulkReviewAdjustments(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
 		return
 	}
 	
-	c.JSON(http.StatusOK, adjustment)
+	var input BulkReviewAdjustmentsRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	
+	var adjustments []models.InventoryCheckAdjustment
+	err := icc.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		adjustments, err = services.ReviewAdjustments(tx, icc.ledger, input.AdjustmentIDs, userID.(uint), input.ApprovalStatus, input.ApprovalNote)
+		return err
+	})
+	if err != nil {
+		respondAdjustmentError(c, err)
+		return
+	}
+	
+	c.JSON(http.StatusOK, adjustments)
+}
+
+// respondAdjustmentError 将盘点调整错误转换为HTTP响应
+func respondAdjustmentError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Adjustment not found"})
+	case errors.Is(err, services.ErrCheckNotCompleted),
+		errors.Is(err, services.ErrAdjustmentNotPending),
+		errors.Is(err, services.ErrInvalidApprovalStatus):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		respondLedgerError(c, err)
+	}
 }
@@ -1,8 +1,12 @@
 package controllers
 
 import (
+	"errors"
 	"hd_psi/backend/models"
+	"hd_psi/backend/services"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -42,6 +46,12 @@ func (ic *InventoryController) CreateInventory(c *gin.Context) {
 		return
 	}
 
+	// 库存数量只能通过库存交易变更，这里只允许建立零库存记录
+	if inventory.Quantity != 0 || inventory.Reserved != 0 || inventory.QuarantineQty != 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "库存数量只能通过入库、出库或盘点调整变更"})
+		return
+	}
+
 	if err := ic.db.Create(&inventory).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -57,11 +67,20 @@ func (ic *InventoryController) UpdateInventory(c *gin.Context) {
 		return
 	}
 
+	original := inventory
 	if err := c.ShouldBindJSON(&inventory); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	// 直接修改余额或平均成本会绕过库存台账，导致余额、库存估值与交易记录不一致
+	if inventory.ID != original.ID || inventory.StoreID != original.StoreID || inventory.ProductID != original.ProductID ||
+		inventory.Quantity != original.Quantity || inventory.Reserved != original.Reserved || inventory.QuarantineQty != original.QuarantineQty ||
+		inventory.AvgCost != original.AvgCost {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "库存数量和平均成本只能通过入库、出库或盘点调整变更"})
+		return
+	}
+
 	if err := ic.db.Save(&inventory).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -71,9 +90,321 @@ func (ic *InventoryController) UpdateInventory(c *gin.Context) {
 
 func (ic *InventoryController) DeleteInventory(c *gin.Context) {
 	id := c.Param("id")
-	if err := ic.db.Delete(&models.Inventory{}, id).Error; err != nil {
+	var inventory models.Inventory
+	if err := ic.db.First(&inventory, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Inventory not found"})
+		return
+	}
+
+	// 有库存、预留或隔离库存的记录删除后余额与台账不再一致
+	if inventory.Quantity != 0 || inventory.Reserved != 0 || inventory.QuarantineQty != 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "只能删除零库存记录"})
+		return
+	}
+
+	if err := ic.db.Delete(&inventory).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Inventory deleted"})
 }
+
+// 批次库存查询结果，包含批次来源的采购入库单和供应商
+type InventoryBatchView struct {
+	models.InventoryBatch
+	ReceivingNumber string `json:"receiving_number"`
+	SupplierID      *uint  `json:"supplier_id"`
+	SupplierName    string `json:"supplier_name"`
+}
+
+// ListBatches 查询批次库存，可按批次号或供应商追溯仍在架的批次
+func (ic *InventoryController) ListBatches(c *gin.Context) {
+	var batches []InventoryBatchView
+
+	query := ic.db.Table("inventory_batches").
+		Select("inventory_batches.*, purchase_receivings.receiving_number, purchase_orders.supplier_id, suppliers.name AS supplier_name").
+		Joins("LEFT JOIN purchase_receivings ON purchase_receivings.id = inventory_batches.receiving_id").
+		Joins("LEFT JOIN purchase_orders ON purchase_orders.id = purchase_receivings.purchase_order_id").
+		Joins("LEFT JOIN suppliers ON suppliers.id = purchase_orders.supplier_id")
+
+	if storeID := c.Query("store_id"); storeID != "" {
+		query = query.Where("inventory_batches.store_id = ?", storeID)
+	}
+	if productID := c.Query("product_id"); productID != "" {
+		query = query.Where("inventory_batches.product_id = ?", productID)
+	}
+	if batchNumber := c.Query("batch_number"); batchNumber != "" {
+		query = query.Where("inventory_batches.batch_number = ?", batchNumber)
+	}
+	if supplierID := c.Query("supplier_id"); supplierID != "" {
+		query = query.Where("purchase_orders.supplier_id = ?", supplierID)
+	}
+	if c.Query("in_stock") == "true" {
+		query = query.Where("inventory_batches.quantity > 0")
+	}
+
+	if err := query.Order("inventory_batches.received_at").Scan(&batches).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, batches)
+}
+
+// 可售库存
+type InventoryAvailability struct {
+	StoreID    uint `json:"store_id"`
+	ProductID  uint `json:"product_id"`
+	OnHand     int  `json:"on_hand"`
+	Reserved   int  `json:"reserved"`
+	Available  int  `json:"available"`
+	Quarantine int  `json:"quarantine"` // 隔离库存，不可销售
+}
+
+// GetAvailability 查询可售库存（在库数量减去线上订单预留数量）
+func (ic *InventoryController) GetAvailability(c *gin.Context) {
+	var availability []InventoryAvailability
+
+	query := ic.db.Model(&models.Inventory{}).
+		Select("store_id, product_id, quantity AS on_hand, reserved, quantity - reserved AS available, quarantine_qty AS quarantine")
+
+	if storeID := c.Query("store_id"); storeID != "" {
+		query = query.Where("store_id = ?", storeID)
+	}
+	if productID := c.Query("product_id"); productID != "" {
+		query = query.Where("product_id = ?", productID)
+	}
+
+	if err := query.Scan(&availability).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, availability)
+}
+
+// 历史库存品类汇总
+type CategoryStockAsOf struct {
+	CategoryID *uint  `json:"category_id"`
+	Category   string `json:"category"`
+	Quantity   int    `json:"quantity"`
+}
+
+// GetInventoryAsOf 按交易记录回放某一时刻的库存，用于审计查询
+// at 支持 2006-01-02（当天结束时）、2006-01-02 15:04:05 和 RFC3339 格式
+func (ic *InventoryController) GetInventoryAsOf(c *gin.Context) {
+	at, err := parseAsOfTime(c.Query("at"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var filter services.AsOfFilter
+	if filter.StoreID, err = parseOptionalID(c.Query("store_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的店铺ID"})
+		return
+	}
+	if filter.ProductID, err = parseOptionalID(c.Query("product_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的商品ID"})
+		return
+	}
+	if filter.CategoryID, err = parseOptionalID(c.Query("category_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的类别ID"})
+		return
+	}
+
+	items, snapshotDate, err := services.InventoryAsOf(ic.db, at, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 按类别汇总，未分类的商品归为一组
+	index := make(map[uint]int)
+	var categories []CategoryStockAsOf
+	for _, item := range items {
+		var key uint
+		if item.CategoryID != nil {
+			key = *item.CategoryID
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(categories)
+			index[key] = i
+			categories = append(categories, CategoryStockAsOf{CategoryID: item.CategoryID, Category: item.Category})
+		}
+		categories[i].Quantity += item.Quantity
+	}
+
+	response := gin.H{
+		"at":         at,
+		"items":      items,
+		"categories": categories,
+	}
+	if snapshotDate != nil {
+		response["snapshot_date"] = snapshotDate.Format("2006-01-02")
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// 生成库存快照请求
+type SnapshotRequest struct {
+	Date     string `json:"date"`      // 单日，格式 2006-01-02
+	FromDate string `json:"from_date"` // 补录区间开始日期
+	ToDate   string `json:"to_date"`   // 补录区间结束日期
+}
+
+// CreateSnapshots 生成指定日期或日期区间的库存快照，用于补录历史快照
+func (ic *InventoryController) CreateSnapshots(c *gin.Context) {
+	var req SnapshotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Date != "" {
+		req.FromDate, req.ToDate = req.Date, req.Date
+	}
+	from, err := time.ParseInLocation("2006-01-02", req.FromDate, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的开始日期"})
+		return
+	}
+	to, err := time.ParseInLocation("2006-01-02", req.ToDate, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的结束日期"})
+		return
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "结束日期不能早于开始日期"})
+		return
+	}
+
+	// 只能生成已经结束的日期
+	today := time.Now()
+	if !to.Before(time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.Local)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "只能生成今天之前的库存快照"})
+		return
+	}
+
+	// 按日期顺序生成，后一天可以复用前一天的快照
+	created := 0
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		count, err := services.BuildDailySnapshot(ic.db, day)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		created += count
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from_date": from.Format("2006-01-02"),
+		"to_date":   to.Format("2006-01-02"),
+		"rows":      created,
+	})
+}
+
+// parseAsOfTime 解析历史库存查询时间，只有日期时取当天结束时
+func parseAsOfTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, errors.New("请指定查询时间")
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t.AddDate(0, 0, 1), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", value, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, errors.New("无效的查询时间")
+}
+
+// GetReconciliation 比对库存余额与库存交易合计，返回不一致的店铺商品
+func (ic *InventoryController) GetReconciliation(c *gin.Context) {
+	storeID, err := parseOptionalID(c.Query("store_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的店铺ID"})
+		return
+	}
+	productID, err := parseOptionalID(c.Query("product_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的商品ID"})
+		return
+	}
+
+	drifts, err := services.FindInventoryDrift(ic.db, storeID, productID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total": len(drifts),
+		"items": drifts,
+	})
+}
+
+// 台账修正请求
+type RepairLedgerRequest struct {
+	StoreID   uint   `json:"store_id"`   // 为0时修正所有店铺
+	ProductID uint   `json:"product_id"` // 为0时修正所有商品
+	Reason    string `json:"reason" binding:"required"`
+}
+
+// RepairLedger 为余额与台账不一致的店铺商品补记台账修正交易
+func (ic *InventoryController) RepairLedger(c *gin.Context) {
+	var req RepairLedgerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return
+	}
+
+	drifts, err := services.FindInventoryDrift(ic.db, req.StoreID, req.ProductID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx := ic.db.Begin()
+	var corrections []models.InventoryTransaction
+	for _, drift := range drifts {
+		transaction, err := services.RepairDrift(tx, drift.StoreID, drift.ProductID, drift.Bucket, userID.(uint), req.Reason)
+		if err != nil {
+			tx.Rollback()
+			respondLedgerError(c, err)
+			return
+		}
+		if transaction != nil {
+			corrections = append(corrections, *transaction)
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total": len(corrections),
+		"items": corrections,
+	})
+}
+
+// parseOptionalID 解析可选的ID查询参数，为空时返回0
+func parseOptionalID(value string) (uint, error) {
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return uint(id), nil
+}
@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	"errors"
+	"hd_psi/backend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondLedgerError 将库存台账服务返回的错误转换为HTTP响应
+func respondLedgerError(c *gin.Context, err error) {
+	var insufficient *services.InsufficientStockError
+	switch {
+	case errors.As(err, &insufficient):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":      err.Error(),
+			"store_id":   insufficient.StoreID,
+			"product_id": insufficient.ProductID,
+			"available":  insufficient.Available,
+			"requested":  insufficient.Requested,
+		})
+	case errors.Is(err, services.ErrInvalidQuantity), errors.Is(err, services.ErrReservationInactive),
+		errors.Is(err, services.ErrReasonRequired), errors.Is(err, services.ErrStoreFrozen),
+		errors.Is(err, services.ErrInvalidTransactionType), errors.Is(err, services.ErrQuantityDirection):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -1,7 +1,9 @@
 package controllers
 
 import (
+	"errors"
 	"hd_psi/backend/models"
+	"hd_psi/backend/services"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -22,7 +24,8 @@ func (itc *InventoryThresholdController) ListThresholds(c *gin.Context) {
 	
 	// 获取查询参数
 	storeID := c.Query("store_id")
-	category := c.Query("category")
+	productID := c.Query("product_id")
+	categoryID := c.Query("category_id")
 	
 	// 构建查询
 	query := itc.db.Model(&models.InventoryThreshold{})
@@ -31,8 +34,18 @@ func (itc *InventoryThresholdController) ListThresholds(c *gin.Context) {
 		query = query.Where("store_id = ? OR store_id = 0", storeID)
 	}
 	
-	if category != "" {
-		query = query.Where("category = ? OR category = ''", category)
+	if productID != "" {
+		query = query.Where("product_id = ? OR product_id = 0", productID)
+	}
+	
+	if categoryID != "" {
+		// 上级类别的设置同样适用于子类别
+		var category models.Category
+		if err := itc.db.First(&category, categoryID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "类别不存在"})
+			return
+		}
+		query = query.Where("category_id = 0 OR category_id IN ?", services.CategoryAncestorIDs(&category))
 	}
 	
 	// 执行查询
@@ -69,6 +82,10 @@ func (itc *InventoryThresholdController) CreateThreshold(c *gin.Context) {
 		return
 	}
 	
+	if !itc.checkCategory(c, threshold.CategoryID) {
+		return
+	}
+	
 	if err := itc.db.Create(&threshold).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -98,6 +115,10 @@ func (itc *InventoryThresholdController) UpdateThreshold(c *gin.Context) {
 		return
 	}
 	
+	if !itc.checkCategory(c, threshold.CategoryID) {
+		return
+	}
+	
 	if err := itc.db.Save(&threshold).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -116,3 +137,66 @@ func (itc *InventoryThresholdController) DeleteThreshold(c *gin.Context) {
 	
 	c.JSON(http.StatusOK, gin.H{"message": "Threshold deleted"})
 }
+
+// ExplainThreshold 说明店铺商品适用哪条阈值设置，以及其他设置未被采用的原因
+func (itc *InventoryThresholdController) ExplainThreshold(c *gin.Context) {
+	storeID, err := parseOptionalID(c.Query("store_id"))
+	if err != nil || storeID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请指定有效的店铺ID"})
+		return
+	}
+	productID, err := parseOptionalID(c.Query("product_id"))
+	if err != nil || productID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请指定有效的商品ID"})
+		return
+	}
+
+	var product models.Product
+	if err := itc.db.First(&product, productID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "商品不存在"})
+		return
+	}
+
+	thresholds, err := services.LoadThresholds(itc.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	target := services.ThresholdTarget{
+		StoreID:   storeID,
+		ProductID: product.ID,
+		Size:      product.Size,
+	}
+	if product.CategoryID != nil {
+		target.CategoryID = *product.CategoryID
+	}
+	applied := thresholds.Match(target)
+
+	c.JSON(http.StatusOK, gin.H{
+		"store_id":    storeID,
+		"product_id":  product.ID,
+		"category_id": product.CategoryID,
+		"category":    product.Category,
+		"size":        product.Size,
+		"applied":     applied,
+		"is_default":  applied.ID == 0, // 没有适用的设置，使用默认阈值
+		"candidates":  thresholds.Explain(target),
+	})
+}
+
+// checkCategory 检查阈值设置指定的类别存在，不存在时写入错误响应
+func (itc *InventoryThresholdController) checkCategory(c *gin.Context, categoryID uint) bool {
+	if categoryID == 0 {
+		return true
+	}
+	if _, err := services.FindCategory(itc.db, categoryID); err != nil {
+		if errors.Is(err, services.ErrCategoryNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return false
+	}
+	return true
+}
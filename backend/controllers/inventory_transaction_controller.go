@@ -2,18 +2,31 @@ package controllers
 
 import (
 	"hd_psi/backend/models"
+	"hd_psi/backend/services"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
+// manualTransactionTypes 手工登记允许的交易类型及数量方向（1 入库，-1 出库）
+// 报损、盘点调整、台账修正和隔离库存变动须通过各自的业务流程登记
+var manualTransactionTypes = map[models.TransactionType]int{
+	models.PurchaseIn:  1,
+	models.ReturnIn:    1,
+	models.TransferIn:  1,
+	models.SaleOut:     -1,
+	models.ExchangeOut: -1,
+	models.TransferOut: -1,
+}
+
 type InventoryTransactionController struct {
-	db *gorm.DB
+	db     *gorm.DB
+	ledger *services.InventoryLedger
 }
 
 func NewInventoryTransactionController(db *gorm.DB) *InventoryTransactionController {
-	return &InventoryTransactionController{db: db}
+	return &InventoryTransactionController{db: db, ledger: services.NewInventoryLedger(db)}
 }
 
 func (itc *InventoryTransactionController) ListTransactions(c *gin.Context) {
@@ -42,46 +55,38 @@ func (itc *InventoryTransactionController) CreateTransaction(c *gin.Context) {
 		return
 	}
 
-	// 开始事务
-	tx := itc.db.Begin()
-
-	// 创建库存交易记录
-	if err := tx.Create(&transaction).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// 只接受可售库存的常规出入库，数量方向须与交易类型一致
+	direction, ok := manualTransactionTypes[transaction.TransactionType]
+	if !ok || transaction.Bucket != models.BucketSellable {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Transaction type or bucket cannot be posted manually"})
 		return
 	}
+	if direction*transaction.Quantity <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrQuantityDirection.Error()})
+		return
+	}
+	transaction.ID = 0
 
-	// 更新库存
-	var inventory models.Inventory
-	result := tx.Where("store_id = ? AND product_id = ?", transaction.StoreID, transaction.ProductID).First(&inventory)
-	
-	if result.Error != nil {
-		// 如果库存记录不存在且是入库操作，则创建新的库存记录
-		if result.Error == gorm.ErrRecordNotFound && transaction.Quantity > 0 {
-			newInventory := models.Inventory{
-				StoreID:   transaction.StoreID,
-				ProductID: transaction.ProductID,
-				Quantity:  transaction.Quantity,
-			}
-			if err := tx.Create(&newInventory).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create inventory record: " + err.Error()})
-				return
-			}
-		} else {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find inventory record: " + result.Error.Error()})
-			return
-		}
-	} else {
-		// 更新现有库存
-		inventory.Quantity += transaction.Quantity
-		if err := tx.Save(&inventory).Error; err != nil {
+	// 开始事务
+	tx := itc.db.Begin()
+
+	// 不接受客户端填写的单位成本：入库按关联单据确定，出库按平均成本登记
+	transaction.UnitCost = 0
+	if transaction.Quantity > 0 {
+		cost, err := services.SourceDocumentCost(tx, &transaction)
+		if err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update inventory: " + err.Error()})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		transaction.UnitCost = cost
+	}
+
+	// 通过库存台账记录交易并更新库存
+	if _, err := itc.ledger.Post(tx, &transaction); err != nil {
+		tx.Rollback()
+		respondLedgerError(c, err)
+		return
 	}
 
 	// 提交事务
@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"errors"
+	"hd_psi/backend/media"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type MediaController struct {
+	db    *gorm.DB
+	store media.Storage
+}
+
+func NewMediaController(db *gorm.DB, store media.Storage) *MediaController {
+	return &MediaController{db: db, store: store}
+}
+
+// ListProductImages 获取商品图库
+func (mc *MediaController) ListProductImages(c *gin.Context) {
+	mc.listImages(c, media.ProductOwner)
+}
+
+// UploadProductImages 上传商品图片，表单字段为 images，可多选
+func (mc *MediaController) UploadProductImages(c *gin.Context) {
+	mc.uploadImages(c, media.ProductOwner)
+}
+
+// ReorderProductImages 调整商品图片顺序
+func (mc *MediaController) ReorderProductImages(c *gin.Context) {
+	mc.reorderImages(c, media.ProductOwner)
+}
+
+// DeleteProductImage 删除商品图片
+func (mc *MediaController) DeleteProductImage(c *gin.Context) {
+	mc.deleteImage(c, media.ProductOwner)
+}
+
+// ListStyleImages 获取款式图库
+func (mc *MediaController) ListStyleImages(c *gin.Context) {
+	mc.listImages(c, media.StyleOwner)
+}
+
+// UploadStyleImages 上传款式图片，表单字段为 images，可多选
+func (mc *MediaController) UploadStyleImages(c *gin.Context) {
+	mc.uploadImages(c, media.StyleOwner)
+}
+
+// ReorderStyleImages 调整款式图片顺序
+func (mc *MediaController) ReorderStyleImages(c *gin.Context) {
+	mc.reorderImages(c, media.StyleOwner)
+}
+
+// DeleteStyleImage 删除款式图片
+func (mc *MediaController) DeleteStyleImage(c *gin.Context) {
+	mc.deleteImage(c, media.StyleOwner)
+}
+
+func (mc *MediaController) listImages(c *gin.Context, owner func(uint) media.Owner) {
+	id, ok := parseOwnerID(c)
+	if !ok {
+		return
+	}
+
+	images, err := media.Gallery(mc.db, owner(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, images)
+}
+
+func (mc *MediaController) uploadImages(c *gin.Context, owner func(uint) media.Owner) {
+	id, ok := parseOwnerID(c)
+	if !ok {
+		return
+	}
+
+	// 获取当前用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["images"]) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请选择要上传的图片"})
+		return
+	}
+
+	images, err := media.AddImages(mc.db, mc.store, owner(id), form.File["images"], userID.(uint))
+	if err != nil {
+		respondMediaError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, images)
+}
+
+// 图片排序请求参数
+type ReorderImagesRequest struct {
+	ImageIDs []uint `json:"image_ids" binding:"required,min=1"`
+}
+
+func (mc *MediaController) reorderImages(c *gin.Context, owner func(uint) media.Owner) {
+	id, ok := parseOwnerID(c)
+	if !ok {
+		return
+	}
+
+	var request ReorderImagesRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	images, err := media.ReorderImages(mc.db, owner(id), request.ImageIDs)
+	if err != nil {
+		respondMediaError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, images)
+}
+
+func (mc *MediaController) deleteImage(c *gin.Context, owner func(uint) media.Owner) {
+	id, ok := parseOwnerID(c)
+	if !ok {
+		return
+	}
+	imageID, err := strconv.ParseUint(c.Param("imageId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的图片ID"})
+		return
+	}
+
+	if err := media.DeleteImage(mc.db, mc.store, owner(id), uint(imageID)); err != nil {
+		respondMediaError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "图片已删除"})
+}
+
+// ServeUpload 读取本地存储的上传文件，需登录后访问
+func (mc *MediaController) ServeUpload(c *gin.Context) {
+	data, contentType, err := mc.store.Get(strings.TrimPrefix(c.Param("key"), "/"))
+	switch {
+	case errors.Is(err, media.ErrFileNotFound), errors.Is(err, media.ErrInvalidKey):
+		c.JSON(http.StatusNotFound, gin.H{"error": media.ErrFileNotFound.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	c.Header("Cache-Control", "private, max-age=3600")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// parseOwnerID 解析路径中的商品或款式ID，无效时写入错误响应
+func parseOwnerID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// respondMediaError 将图库相关错误转换为HTTP响应
+func respondMediaError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, media.ErrOwnerNotFound), errors.Is(err, media.ErrImageNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, media.ErrInvalidImage), errors.Is(err, media.ErrImageTooLarge), errors.Is(err, media.ErrImageDimensions),
+		errors.Is(err, media.ErrInvalidOrder):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
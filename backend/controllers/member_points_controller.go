@@ -235,20 +235,7 @@ func (mpc *MemberPointsController) CalculateMemberLevel(c *gin.Context) {
 	}
 	
 	// 根据累计消费金额计算会员等级
-	var newLevel models.MemberLevel
-	
-	switch {
-	case member.TotalSpent >= 50000:
-		newLevel = models.Diamond
-	case member.TotalSpent >= 20000:
-		newLevel = models.Platinum
-	case member.TotalSpent >= 10000:
-		newLevel = models.Gold
-	case member.TotalSpent >= 5000:
-		newLevel = models.Silver
-	default:
-		newLevel = models.Regular
-	}
+	newLevel := member.CalculateLevel()
 	
 	// 如果等级有变化，更新会员等级
 	if member.Level != newLevel {
@@ -0,0 +1,276 @@
+package controllers
+
+import (
+	"hd_psi/backend/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NotificationController struct {
+	db *gorm.DB
+}
+
+func NewNotificationController(db *gorm.DB) *NotificationController {
+	return &NotificationController{db: db}
+}
+
+// 站内信列表请求参数
+type ListNotificationsQuery struct {
+	Unread   bool `form:"unread"`
+	Page     int  `form:"page,default=1"`
+	PageSize int  `form:"page_size,default=20"`
+}
+
+// 站内信列表响应
+type NotificationsResponse struct {
+	Total  int                   `json:"total"`
+	Unread int                   `json:"unread"`
+	Items  []models.Notification `json:"items"`
+}
+
+// ListNotifications 获取当前用户的站内信
+func (nc *NotificationController) ListNotifications(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return
+	}
+
+	var query ListNotificationsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var unread int64
+	nc.db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&unread)
+
+	db := nc.db.Model(&models.Notification{}).Where("user_id = ?", userID)
+	if query.Unread {
+		db = db.Where("read_at IS NULL")
+	}
+
+	var total int64
+	db.Count(&total)
+
+	offset := (query.Page - 1) * query.PageSize
+	var notifications []models.Notification
+	if err := db.Offset(offset).Limit(query.PageSize).
+		Order("created_at DESC").
+		Find(&notifications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, NotificationsResponse{
+		Total:  int(total),
+		Unread: int(unread),
+		Items:  notifications,
+	})
+}
+
+// MarkNotificationRead 将站内信标记为已读
+func (nc *NotificationController) MarkNotificationRead(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return
+	}
+
+	var notification models.Notification
+	if err := nc.db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&notification).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "站内信不存在"})
+		return
+	}
+
+	if notification.ReadAt == nil {
+		now := time.Now()
+		notification.ReadAt = &now
+		if err := nc.db.Model(&notification).Update("read_at", now).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, notification)
+}
+
+// MarkAllNotificationsRead 将当前用户的站内信全部标记为已读
+func (nc *NotificationController) MarkAllNotificationsRead(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return
+	}
+
+	result := nc.db.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": result.RowsAffected})
+}
+
+// ListSubscriptions 获取当前用户的通知订阅
+func (nc *NotificationController) ListSubscriptions(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return
+	}
+
+	var subscriptions []models.NotificationSubscription
+	if err := nc.db.Where("user_id = ?", userID).Order("event, channel").Find(&subscriptions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, subscriptions)
+}
+
+// 通知订阅项
+type SubscriptionInput struct {
+	Event   string `json:"event" binding:"required"`
+	Channel string `json:"channel" binding:"required"`
+	Target  string `json:"target"`  // 接收地址，为空时使用用户邮箱或手机号；Webhook 地址仅管理员可配置
+	Enabled *bool  `json:"enabled"` // 默认启用
+}
+
+// 更新通知订阅请求
+type UpdateSubscriptionsRequest struct {
+	Subscriptions []SubscriptionInput `json:"subscriptions" binding:"dive"`
+}
+
+// UpdateSubscriptions 更新当前用户的通知订阅，未包含的事件渠道组合会被取消订阅
+func (nc *NotificationController) UpdateSubscriptions(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return
+	}
+
+	var request UpdateSubscriptionsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	subscriptions := make([]models.NotificationSubscription, 0, len(request.Subscriptions))
+	for _, input := range request.Subscriptions {
+		event := models.NotificationEvent(input.Event)
+		channel := models.NotificationChannel(input.Channel)
+		if !event.Valid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的通知事件: " + input.Event})
+			return
+		}
+		if !channel.Valid() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的通知渠道: " + input.Channel})
+			return
+		}
+		// 自定义回调地址会由服务器发起请求，只允许管理员配置
+		if channel == models.ChannelWebhook && input.Target != "" && c.GetString("role") != string(models.Admin) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "只有管理员可以配置Webhook回调地址"})
+			return
+		}
+		enabled := true
+		if input.Enabled != nil {
+			enabled = *input.Enabled
+		}
+		subscriptions = append(subscriptions, models.NotificationSubscription{
+			UserID:  userID.(uint),
+			Event:   event,
+			Channel: channel,
+			Target:  input.Target,
+			Enabled: enabled,
+		})
+	}
+
+	// 开始事务
+	tx := nc.db.Begin()
+
+	if err := tx.Where("user_id = ?", userID).Delete(&models.NotificationSubscription{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新通知订阅失败: " + err.Error()})
+		return
+	}
+
+	if len(subscriptions) > 0 {
+		// 同一事件渠道重复提交时以最后一项为准
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&subscriptions).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "更新通知订阅失败: " + err.Error()})
+			return
+		}
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "提交事务失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, subscriptions)
+}
+
+// 通知发送记录列表请求参数
+type ListDeliveriesQuery struct {
+	UserID   uint   `form:"user_id"`
+	Event    string `form:"event"`
+	Channel  string `form:"channel"`
+	Status   string `form:"status"`
+	Page     int    `form:"page,default=1"`
+	PageSize int    `form:"page_size,default=20"`
+}
+
+// 通知发送记录列表响应
+type DeliveriesResponse struct {
+	Total int                           `json:"total"`
+	Items []models.NotificationDelivery `json:"items"`
+}
+
+// ListDeliveries 获取通知发送记录，用于排查发送失败
+func (nc *NotificationController) ListDeliveries(c *gin.Context) {
+	var query ListDeliveriesQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := nc.db.Model(&models.NotificationDelivery{})
+	if query.UserID != 0 {
+		db = db.Where("user_id = ?", query.UserID)
+	}
+	if query.Event != "" {
+		db = db.Where("event = ?", query.Event)
+	}
+	if query.Channel != "" {
+		db = db.Where("channel = ?", query.Channel)
+	}
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+
+	var total int64
+	db.Count(&total)
+
+	offset := (query.Page - 1) * query.PageSize
+	var deliveries []models.NotificationDelivery
+	if err := db.Offset(offset).Limit(query.PageSize).
+		Order("created_at DESC").
+		Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, DeliveriesResponse{
+		Total: int(total),
+		Items: deliveries,
+	})
+}
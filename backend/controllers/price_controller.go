@@ -0,0 +1,256 @@
+package controllers
+
+import (
+	"errors"
+	"hd_psi/backend/models"
+	"hd_psi/backend/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type PriceController struct {
+	db *gorm.DB
+}
+
+func NewPriceController(db *gorm.DB) *PriceController {
+	return &PriceController{db: db}
+}
+
+// 价格表列表请求参数
+type ListPricesQuery struct {
+	ProductID uint   `form:"product_id"`
+	StoreID   uint   `form:"store_id"`
+	Type      string `form:"type"`
+	ActiveAt  string `form:"active_at"` // 只返回该时刻生效的价格，格式 2006-01-02 15:04:05
+	Page      int    `form:"page,default=1"`
+	PageSize  int    `form:"page_size,default=20"`
+}
+
+// 价格表列表响应
+type PricesResponse struct {
+	Total int                   `json:"total"`
+	Items []models.ProductPrice `json:"items"`
+}
+
+// ListPrices 获取价格表
+func (pc *PriceController) ListPrices(c *gin.Context) {
+	var query ListPricesQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := pc.db.Model(&models.ProductPrice{})
+	if query.ProductID != 0 {
+		db = db.Where("product_id = ?", query.ProductID)
+	}
+	if query.StoreID != 0 {
+		db = db.Where("store_id IS NULL OR store_id = ?", query.StoreID)
+	}
+	if query.Type != "" {
+		db = db.Where("type = ?", query.Type)
+	}
+	if query.ActiveAt != "" {
+		at, err := time.ParseInLocation("2006-01-02 15:04:05", query.ActiveAt, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid active_at"})
+			return
+		}
+		db = db.Where("start_at <= ? AND (end_at IS NULL OR end_at > ?)", at, at)
+	}
+
+	var total int64
+	db.Count(&total)
+
+	offset := (query.Page - 1) * query.PageSize
+	var prices []models.ProductPrice
+	if err := db.Offset(offset).Limit(query.PageSize).
+		Order("product_id, start_at DESC, id DESC").
+		Find(&prices).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, PricesResponse{
+		Total: int(total),
+		Items: prices,
+	})
+}
+
+// 价格表条目请求参数
+type PriceRequest struct {
+	ProductID uint             `json:"product_id" binding:"required"`
+	Type      models.PriceType `json:"type" binding:"required"`
+	StoreID   *uint            `json:"store_id"`
+	Price     float64          `json:"price" binding:"required,gt=0"`
+	StartAt   *time.Time       `json:"start_at"` // 为空时立即生效
+	EndAt     *time.Time       `json:"end_at"`
+	Note      string           `json:"note"`
+}
+
+// CreatePrice 新建吊牌价、促销价或门店价
+func (pc *PriceController) CreatePrice(c *gin.Context) {
+	var request PriceRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 获取当前用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return
+	}
+
+	price := models.ProductPrice{
+		ProductID: request.ProductID,
+		Type:      request.Type,
+		StoreID:   request.StoreID,
+		Price:     request.Price,
+		StartAt:   time.Now(),
+		EndAt:     request.EndAt,
+		Note:      request.Note,
+	}
+	if request.StartAt != nil {
+		price.StartAt = *request.StartAt
+	}
+
+	err := pc.db.Transaction(func(tx *gorm.DB) error {
+		return services.CreatePrice(tx, &price, userID.(uint))
+	})
+	if err != nil {
+		respondPriceError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, price)
+}
+
+// 修改价格请求参数，商品、类型和店铺不能修改
+type UpdatePriceRequest struct {
+	Price   float64    `json:"price" binding:"required,gt=0"`
+	StartAt time.Time  `json:"start_at" binding:"required"`
+	EndAt   *time.Time `json:"end_at"`
+	Note    string     `json:"note"`
+}
+
+// UpdatePrice 修改价格或生效区间
+func (pc *PriceController) UpdatePrice(c *gin.Context) {
+	var request UpdatePriceRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 获取当前用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return
+	}
+
+	var price models.ProductPrice
+	if err := pc.db.First(&price, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Price not found"})
+		return
+	}
+
+	err := pc.db.Transaction(func(tx *gorm.DB) error {
+		return services.UpdatePrice(tx, &price, request.Price, request.StartAt, request.EndAt, request.Note, userID.(uint))
+	})
+	if err != nil {
+		respondPriceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, price)
+}
+
+// EndPrice 立即结束价格，如提前结束促销
+func (pc *PriceController) EndPrice(c *gin.Context) {
+	// 获取当前用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return
+	}
+
+	var price models.ProductPrice
+	if err := pc.db.First(&price, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Price not found"})
+		return
+	}
+
+	err := pc.db.Transaction(func(tx *gorm.DB) error {
+		return services.EndPrice(tx, &price, time.Now(), userID.(uint))
+	})
+	if err != nil {
+		respondPriceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, price)
+}
+
+// ListPriceHistory 获取商品价格变更历史
+func (pc *PriceController) ListPriceHistory(c *gin.Context) {
+	productID := c.Query("product_id")
+	if productID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "product_id is required"})
+		return
+	}
+
+	var history []models.ProductPriceHistory
+	db := pc.db.Where("product_id = ?", productID)
+	if priceID := c.Query("price_id"); priceID != "" {
+		db = db.Where("price_id = ?", priceID)
+	}
+	if err := db.Order("created_at DESC, id DESC").Find(&history).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+// 售价查询请求参数
+type ResolvePriceQuery struct {
+	ProductID uint   `form:"product_id" binding:"required"`
+	StoreID   uint   `form:"store_id" binding:"required"`
+	At        string `form:"at"` // 查询时刻，格式 2006-01-02 15:04:05，默认当前时间
+}
+
+// ResolvePrice 查询店铺商品在某一时刻的售价
+func (pc *PriceController) ResolvePrice(c *gin.Context) {
+	var query ResolvePriceQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	at := time.Now()
+	if query.At != "" {
+		var err error
+		if at, err = time.ParseInLocation("2006-01-02 15:04:05", query.At, time.Local); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid at"})
+			return
+		}
+	}
+
+	resolved, err := services.ResolvePrice(pc.db, query.ProductID, query.StoreID, at)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, resolved)
+}
+
+// respondPriceError 将价格表相关错误转换为HTTP响应
+func respondPriceError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidPrice), errors.Is(err, services.ErrPriceEnded):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
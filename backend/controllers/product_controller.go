@@ -1,28 +1,80 @@
 package controllers
 
 import (
+	"errors"
+	"hd_psi/backend/media"
 	"hd_psi/backend/models"
+	"hd_psi/backend/services"
+	"hd_psi/backend/skucode"
+	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 type ProductController struct {
-	db *gorm.DB
+	db    *gorm.DB
+	store media.Storage
 }
 
-func NewProductController(db *gorm.DB) *ProductController {
-	return &ProductController{db: db}
+func NewProductController(db *gorm.DB, store media.Storage) *ProductController {
+	return &ProductController{db: db, store: store}
 }
 
+// 商品搜索请求参数，类别、颜色、尺码、季节可重复传入多个取值
+type ListProductsQuery struct {
+	Keyword    string   `form:"keyword"`
+	CategoryID []uint   `form:"category_id"` // 类别树节点，包含子类别
+	Color      []string `form:"color"`
+	Size       []string `form:"size"`
+	Season     []string `form:"season"`
+	StyleID    uint     `form:"style_id"`
+	StoreID    uint     `form:"store_id"`
+	InStock    bool     `form:"in_stock"`
+	MinPrice   *float64 `form:"min_price"`
+	MaxPrice   *float64 `form:"max_price"`
+	Sort       string   `form:"sort"`   // relevance、sku、name、price_asc、price_desc、newest
+	Cursor     string   `form:"cursor"` // 上一页响应中的 next_cursor
+	Limit      int      `form:"limit,default=50"`
+	Facets     bool     `form:"facets"`
+}
+
+// ListProducts 搜索商品，支持筛选、排序、游标分页和分面统计
 func (pc *ProductController) ListProducts(c *gin.Context) {
-	var products []models.Product
-	if err := pc.db.Find(&products).Error; err != nil {
+	var query ListProductsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := services.SearchProducts(pc.db, services.ProductSearch{
+		Keyword:     query.Keyword,
+		CategoryIDs: query.CategoryID,
+		Colors:      query.Color,
+		Sizes:       query.Size,
+		Seasons:     query.Season,
+		StyleID:     query.StyleID,
+		StoreID:     query.StoreID,
+		InStock:     query.InStock,
+		MinPrice:    query.MinPrice,
+		MaxPrice:    query.MaxPrice,
+		Sort:        query.Sort,
+		Cursor:      query.Cursor,
+		Limit:       query.Limit,
+		Facets:      query.Facets,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidSearch) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, products)
+	c.JSON(http.StatusOK, result)
 }
 
 func (pc *ProductController) GetProduct(c *gin.Context) {
@@ -42,6 +94,46 @@ func (pc *ProductController) CreateProduct(c *gin.Context) {
 		return
 	}
 
+	// 按类别ID或类别名称（可为“女装/外套”形式的路径）确定类别
+	if !pc.assignCategory(c, &product) {
+		return
+	}
+
+	// 按SKU编码规则校验，未填写SKU时以当前年份生成
+	product.SKU = strings.ToUpper(strings.TrimSpace(product.SKU))
+	codec, err := skucode.Load(pc.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if product.SKU == "" {
+		if product.SKU, err = codec.Encode(skucode.FromProduct(&product, time.Now().Year())); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		attr, err := codec.Check(product.SKU, skucode.FromProduct(&product, 0))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		skucode.Fill(&product, attr)
+		// 类别可能由SKU中的品类编码补全
+		if !pc.assignCategory(c, &product) {
+			return
+		}
+	}
+
+	var count int64
+	if err := pc.db.Model(&models.Product{}).Where("sku = ?", product.SKU).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "SKU already exists"})
+		return
+	}
+
 	if err := pc.db.Create(&product).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -57,11 +149,48 @@ func (pc *ProductController) UpdateProduct(c *gin.Context) {
 		return
 	}
 
+	// 绑定时会复用原有的指针，需按值保存原类别
+	var oldCategoryID uint
+	if product.CategoryID != nil {
+		oldCategoryID = *product.CategoryID
+	}
+	oldCategory := product.Category
+	oldSKU := product.SKU
 	if err := c.ShouldBindJSON(&product); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	// 修改后的SKU和属性仍须符合SKU编码规则
+	product.SKU = strings.ToUpper(strings.TrimSpace(product.SKU))
+	codec, err := skucode.Load(pc.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if _, err := codec.Check(product.SKU, skucode.FromProduct(&product, 0)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if product.SKU != oldSKU {
+		var count int64
+		if err := pc.db.Model(&models.Product{}).Where("sku = ? AND id <> ?", product.SKU, product.ID).Count(&count).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "SKU already exists"})
+			return
+		}
+	}
+	// 只修改了类别名称时按名称重新查找类别
+	if product.Category != oldCategory && product.CategoryID != nil && *product.CategoryID == oldCategoryID {
+		product.CategoryID = nil
+	}
+	if !pc.assignCategory(c, &product) {
+		return
+	}
+
 	if err := pc.db.Save(&product).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -75,5 +204,24 @@ func (pc *ProductController) DeleteProduct(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+
+	// 清理商品图库，失败的文件由定时任务重试
+	if _, err := media.CleanupOrphans(pc.db, pc.store); err != nil {
+		log.Printf("清理商品图片失败: %v", err)
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "Product deleted"})
 }
+
+// assignCategory 确定商品所属类别，类别无效时写入错误响应
+func (pc *ProductController) assignCategory(c *gin.Context, product *models.Product) bool {
+	err := services.AssignProductCategory(pc.db, product, false)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, services.ErrCategoryNotFound), errors.Is(err, services.ErrInvalidCategory):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return false
+}
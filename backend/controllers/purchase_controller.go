@@ -1,8 +1,12 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"hd_psi/backend/models"
+	"hd_psi/backend/notify"
+	"hd_psi/backend/services"
+	"io"
 	"net/http"
 	"strconv"
 	"time"
@@ -132,80 +136,24 @@ func (pc *PurchaseController) CreatePurchaseOrder(c *gin.Context) {
 		return
 	}
 
-	// 生成采购单号
-	orderNumber := fmt.Sprintf("PO%s%04d", time.Now().Format("20060102"), 1)
-
-	// 查询当天最后一个采购单号
-	var lastOrder models.PurchaseOrder
-	pc.db.Where("order_number LIKE ?", "PO"+time.Now().Format("20060102")+"%").
-		Order("order_number DESC").
-		Limit(1).
-		Find(&lastOrder)
-
-	if lastOrder.ID != 0 {
-		// 提取序号并加1
-		seq, _ := strconv.Atoi(lastOrder.OrderNumber[10:])
-		orderNumber = fmt.Sprintf("PO%s%04d", time.Now().Format("20060102"), seq+1)
-	}
-
-	// 开始事务
-	tx := pc.db.Begin()
-
-	// 计算总金额并创建采购单明细
-	var totalAmount float64
-	var items []models.PurchaseOrderItem
-
-	for _, item := range request.Items {
-		totalPrice := item.UnitPrice * float64(item.Quantity)
-		totalAmount += totalPrice
-
-		items = append(items, models.PurchaseOrderItem{
-			ProductID:  item.ProductID,
-			Quantity:   item.Quantity,
-			UnitPrice:  item.UnitPrice,
-			TotalPrice: totalPrice,
-			Note:       item.Note,
-		})
-	}
-
 	// 解析预计到货日期
 	var expectedDate *time.Time
 	if request.ExpectedDate != "" {
 		date, err := time.Parse("2006-01-02", request.ExpectedDate)
 		if err != nil {
-			tx.Rollback()
 			c.JSON(http.StatusBadRequest, gin.H{"error": "预计到货日期格式错误，应为YYYY-MM-DD"})
 			return
 		}
 		expectedDate = &date
 	}
 
-	// 创建采购单
-	purchaseOrder := models.PurchaseOrder{
-		OrderNumber:  orderNumber,
-		SupplierID:   request.SupplierID,
-		StoreID:      request.StoreID,
-		Status:       models.PurchaseDraft,
-		TotalAmount:  totalAmount,
-		ExpectedDate: expectedDate,
-		CreatorID:    userID.(uint),
-		Note:         request.Note,
-	}
-
-	if err := tx.Create(&purchaseOrder).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建采购单失败: " + err.Error()})
-		return
-	}
-
-	// 创建采购单明细
-	for i := range items {
-		items[i].PurchaseOrderID = purchaseOrder.ID
-	}
+	// 开始事务
+	tx := pc.db.Begin()
 
-	if err := tx.Create(&items).Error; err != nil {
+	purchaseOrder, err := createDraftPurchaseOrder(tx, request, expectedDate, userID.(uint))
+	if err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建采购单明细失败: " + err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -390,8 +338,23 @@ func (pc *PurchaseController) UpdatePurchaseOrderStatus(c *gin.Context) {
 		purchaseOrder.ActualDate = &now
 	}
 
-	// 保存采购单
-	if err := pc.db.Save(&purchaseOrder).Error; err != nil {
+	// 保存采购单，提交审核时通知审核人
+	err := pc.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&purchaseOrder).Error; err != nil {
+			return err
+		}
+		if purchaseOrder.Status != models.PurchasePending {
+			return nil
+		}
+		return notify.Enqueue(tx, models.EventPurchaseOrderPending, &purchaseOrder.StoreID, map[string]interface{}{
+			"order_id":     purchaseOrder.ID,
+			"order_number": purchaseOrder.OrderNumber,
+			"store_id":     purchaseOrder.StoreID,
+			"supplier_id":  purchaseOrder.SupplierID,
+			"total_amount": fmt.Sprintf("%.2f", purchaseOrder.TotalAmount),
+		})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新采购单状态失败: " + err.Error()})
 		return
 	}
@@ -465,3 +428,178 @@ func (pc *PurchaseController) DeletePurchaseOrder(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "采购单删除成功"})
 }
+
+// 补货建议请求参数
+type ReplenishmentQuery struct {
+	StoreID      uint `form:"store_id" json:"store_id"`
+	SupplierID   uint `form:"supplier_id" json:"supplier_id"`
+	VelocityDays int  `form:"velocity_days" json:"velocity_days"` // 日均销量统计天数，默认28天
+}
+
+// GetReplenishment 计算补货建议，按供应商和店铺分组
+func (pc *PurchaseController) GetReplenishment(c *gin.Context) {
+	var query ReplenishmentQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	proposals, err := services.ProposeReplenishment(pc.db, services.ReplenishmentFilter{
+		StoreID:      query.StoreID,
+		SupplierID:   query.SupplierID,
+		VelocityDays: query.VelocityDays,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, proposals)
+}
+
+// CreateReplenishmentDrafts 按补货建议为每个供应商和店铺生成草稿采购单，供采购员审核
+// 请求体可省略，省略时不限店铺和供应商
+// 没有采购记录、无法确定供应商的商品不生成采购单，在响应中单独列出
+func (pc *PurchaseController) CreateReplenishmentDrafts(c *gin.Context) {
+	var request ReplenishmentQuery
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 获取当前用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return
+	}
+
+	proposals, err := services.ProposeReplenishment(pc.db, services.ReplenishmentFilter{
+		StoreID:      request.StoreID,
+		SupplierID:   request.SupplierID,
+		VelocityDays: request.VelocityDays,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 开始事务
+	tx := pc.db.Begin()
+
+	var orderIDs []uint
+	var unassigned []services.ReplenishmentLine
+	for _, proposal := range proposals {
+		if proposal.SupplierID == 0 {
+			unassigned = append(unassigned, proposal.Lines...)
+			continue
+		}
+
+		order := CreatePurchaseOrderRequest{
+			SupplierID: proposal.SupplierID,
+			StoreID:    proposal.StoreID,
+			Note:       "补货建议自动生成",
+		}
+		for _, line := range proposal.Lines {
+			order.Items = append(order.Items, PurchaseOrderItemRequest{
+				ProductID: line.ProductID,
+				Quantity:  line.ProposedQty,
+				UnitPrice: line.UnitPrice,
+				Note:      fmt.Sprintf("可用%d 在途%d 日均销量%.2f", line.OnHand-line.Reserved, line.OpenOrderQty, line.DailyVelocity),
+			})
+		}
+
+		// 按供货周期预计到货日期
+		expected := time.Now().AddDate(0, 0, proposal.Lines[0].LeadTimeDays)
+		expectedDate := time.Date(expected.Year(), expected.Month(), expected.Day(), 0, 0, 0, 0, time.Local)
+
+		purchaseOrder, err := createDraftPurchaseOrder(tx, order, &expectedDate, userID.(uint))
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		orderIDs = append(orderIDs, purchaseOrder.ID)
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "提交事务失败: " + err.Error()})
+		return
+	}
+
+	var orders []models.PurchaseOrder
+	if len(orderIDs) > 0 {
+		pc.db.Preload("Items.Product").Preload("Supplier").Preload("Store").
+			Find(&orders, orderIDs)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"orders":     orders,
+		"unassigned": unassigned,
+	})
+}
+
+// createDraftPurchaseOrder 在事务tx中生成采购单号并创建草稿采购单及明细
+func createDraftPurchaseOrder(tx *gorm.DB, request CreatePurchaseOrderRequest, expectedDate *time.Time, creatorID uint) (*models.PurchaseOrder, error) {
+	// 生成采购单号
+	orderNumber := fmt.Sprintf("PO%s%04d", time.Now().Format("20060102"), 1)
+
+	// 查询当天最后一个采购单号
+	var lastOrder models.PurchaseOrder
+	tx.Where("order_number LIKE ?", "PO"+time.Now().Format("20060102")+"%").
+		Order("order_number DESC").
+		Limit(1).
+		Find(&lastOrder)
+
+	if lastOrder.ID != 0 {
+		// 提取序号并加1
+		seq, _ := strconv.Atoi(lastOrder.OrderNumber[10:])
+		orderNumber = fmt.Sprintf("PO%s%04d", time.Now().Format("20060102"), seq+1)
+	}
+
+	// 计算总金额并创建采购单明细
+	var totalAmount float64
+	var items []models.PurchaseOrderItem
+
+	for _, item := range request.Items {
+		totalPrice := item.UnitPrice * float64(item.Quantity)
+		totalAmount += totalPrice
+
+		items = append(items, models.PurchaseOrderItem{
+			ProductID:  item.ProductID,
+			Quantity:   item.Quantity,
+			UnitPrice:  item.UnitPrice,
+			TotalPrice: totalPrice,
+			Note:       item.Note,
+		})
+	}
+
+	// 创建采购单
+	purchaseOrder := models.PurchaseOrder{
+		OrderNumber:  orderNumber,
+		SupplierID:   request.SupplierID,
+		StoreID:      request.StoreID,
+		Status:       models.PurchaseDraft,
+		TotalAmount:  totalAmount,
+		ExpectedDate: expectedDate,
+		CreatorID:    creatorID,
+		Note:         request.Note,
+	}
+
+	if err := tx.Create(&purchaseOrder).Error; err != nil {
+		return nil, fmt.Errorf("创建采购单失败: %w", err)
+	}
+
+	// 创建采购单明细
+	for i := range items {
+		items[i].PurchaseOrderID = purchaseOrder.ID
+	}
+
+	if err := tx.Create(&items).Error; err != nil {
+		return nil, fmt.Errorf("创建采购单明细失败: %w", err)
+	}
+
+	purchaseOrder.Items = items
+	return &purchaseOrder, nil
+}
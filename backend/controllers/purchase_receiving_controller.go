@@ -3,6 +3,7 @@ package controllers
 import (
 	"fmt"
 	"hd_psi/backend/models"
+	"hd_psi/backend/services"
 	"net/http"
 	"strconv"
 	"time"
@@ -12,11 +13,12 @@ import (
 )
 
 type PurchaseReceivingController struct {
-	db *gorm.DB
+	db     *gorm.DB
+	ledger *services.InventoryLedger
 }
 
 func NewPurchaseReceivingController(db *gorm.DB) *PurchaseReceivingController {
-	return &PurchaseReceivingController{db: db}
+	return &PurchaseReceivingController{db: db, ledger: services.NewInventoryLedger(db)}
 }
 
 // 采购入库列表请求参数
@@ -219,40 +221,17 @@ func (prc *PurchaseReceivingController) CreatePurchaseReceiving(c *gin.Context)
 
 		totalReceivedQty += item.ActualQuantity
 
-		// 更新库存
-		if item.ActualQuantity > 0 && item.QualityStatus != "defective" {
-			// 查找库存记录
-			var inventory models.Inventory
-			result := tx.Where("product_id = ? AND store_id = ?", item.ProductID, request.StoreID).First(&inventory)
-
-			if result.Error != nil {
-				// 库存记录不存在，创建新记录
-				inventory = models.Inventory{
-					ProductID: item.ProductID,
-					StoreID:   request.StoreID,
-					Quantity:  item.ActualQuantity,
-				}
-				if err := tx.Create(&inventory).Error; err != nil {
-					tx.Rollback()
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "创建库存记录失败: " + err.Error()})
-					return
-				}
-			} else {
-				// 更新现有库存
-				inventory.Quantity += item.ActualQuantity
-				if err := tx.Save(&inventory).Error; err != nil {
-					tx.Rollback()
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "更新库存失败: " + err.Error()})
-					return
-				}
-			}
-
-			// 创建库存交易记录
+		// 更新库存，质检不合格的商品记入隔离库存
+		if item.ActualQuantity > 0 {
+			// 采购入库
 			transaction := models.InventoryTransaction{
 				ProductID:       item.ProductID,
 				StoreID:         request.StoreID,
 				TransactionType: models.PurchaseIn,
 				Quantity:        item.ActualQuantity,
+				Bucket:          receivingBucket(item.QualityStatus),
+				UnitCost:        orderItem.UnitPrice, // 按采购单价更新平均成本
+				BatchNumber:     item.BatchNumber,
 				OperatorID:      userID.(uint),
 				ReferenceType:   "purchase_receiving",
 				Note:            fmt.Sprintf("采购入库: %s", receivingNumber),
@@ -262,9 +241,9 @@ func (prc *PurchaseReceivingController) CreatePurchaseReceiving(c *gin.Context)
 			refID := receiving.ID
 			transaction.ReferenceID = &refID
 
-			if err := tx.Create(&transaction).Error; err != nil {
+			if _, err := prc.ledger.Post(tx, &transaction); err != nil {
 				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "创建库存交易记录失败: " + err.Error()})
+				respondLedgerError(c, err)
 				return
 			}
 		}
@@ -368,33 +347,16 @@ func (prc *PurchaseReceivingController) DeletePurchaseReceiving(c *gin.Context)
 		}
 
 		// 恢复库存
-		if item.ActualQuantity > 0 && item.QualityStatus != "defective" {
-			// 查找库存记录
-			var inventory models.Inventory
-			if err := tx.Where("product_id = ? AND store_id = ?", item.ProductID, receiving.StoreID).First(&inventory).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "查询库存记录失败: " + err.Error()})
-				return
-			}
-
-			// 更新库存
-			inventory.Quantity -= item.ActualQuantity
-			if inventory.Quantity < 0 {
-				inventory.Quantity = 0
-			}
-
-			if err := tx.Save(&inventory).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "更新库存失败: " + err.Error()})
-				return
-			}
-
-			// 创建库存交易记录
+		if item.ActualQuantity > 0 {
+			// 冲减采购入库
 			transaction := models.InventoryTransaction{
 				ProductID:       item.ProductID,
 				StoreID:         receiving.StoreID,
 				TransactionType: models.PurchaseIn, // 使用采购入库类型，但数量为负
 				Quantity:        -item.ActualQuantity,
+				Bucket:          receivingBucket(item.QualityStatus),
+				UnitCost:        orderItem.UnitPrice, // 按采购单价冲回平均成本
+				BatchNumber:     item.BatchNumber,
 				OperatorID:      userID.(uint),
 				ReferenceType:   "purchase_receiving_cancel",
 				Note:            fmt.Sprintf("取消采购入库: %s", receiving.ReceivingNumber),
@@ -404,9 +366,9 @@ func (prc *PurchaseReceivingController) DeletePurchaseReceiving(c *gin.Context)
 			refID := receiving.ID
 			transaction.ReferenceID = &refID
 
-			if err := tx.Create(&transaction).Error; err != nil {
+			if _, err := prc.ledger.Post(tx, &transaction); err != nil {
 				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "创建库存交易记录失败: " + err.Error()})
+				respondLedgerError(c, err)
 				return
 			}
 		}
@@ -460,3 +422,11 @@ func (prc *PurchaseReceivingController) DeletePurchaseReceiving(c *gin.Context)
 
 	c.JSON(http.StatusOK, gin.H{"message": "采购入库单删除成功"})
 }
+
+// receivingBucket 根据质检状态确定入库的库存分区
+func receivingBucket(qualityStatus string) models.StockBucket {
+	if qualityStatus == "defective" {
+		return models.BucketQuarantine
+	}
+	return models.BucketSellable
+}
@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"fmt"
+	"hd_psi/backend/models"
+	"hd_psi/backend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type QuarantineController struct {
+	db     *gorm.DB
+	ledger *services.InventoryLedger
+}
+
+func NewQuarantineController(db *gorm.DB) *QuarantineController {
+	return &QuarantineController{db: db, ledger: services.NewInventoryLedger(db)}
+}
+
+// 隔离库存查询结果
+type QuarantineStockView struct {
+	StoreID       uint   `json:"store_id"`
+	ProductID     uint   `json:"product_id"`
+	SKU           string `json:"sku"`
+	ProductName   string `json:"product_name"`
+	QuarantineQty int    `json:"quarantine_qty"`
+	Quantity      int    `json:"quantity"` // 可售库存
+}
+
+// ListQuarantine 查询有隔离库存的店铺商品
+func (qc *QuarantineController) ListQuarantine(c *gin.Context) {
+	var stocks []QuarantineStockView
+
+	query := qc.db.Table("inventories").
+		Select("inventories.store_id, inventories.product_id, products.sku, products.name AS product_name, inventories.quarantine_qty, inventories.quantity").
+		Joins("LEFT JOIN products ON products.id = inventories.product_id").
+		Where("inventories.quarantine_qty > 0")
+
+	if storeID := c.Query("store_id"); storeID != "" {
+		query = query.Where("inventories.store_id = ?", storeID)
+	}
+	if productID := c.Query("product_id"); productID != "" {
+		query = query.Where("inventories.product_id = ?", productID)
+	}
+
+	if err := query.Order("inventories.store_id, inventories.product_id").Scan(&stocks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, stocks)
+}
+
+// 隔离库存处理请求
+type QuarantineRequest struct {
+	StoreID    uint   `json:"store_id" binding:"required"`
+	ProductID  uint   `json:"product_id" binding:"required"`
+	Quantity   int    `json:"quantity" binding:"required,min=1"`
+	SupplierID *uint  `json:"supplier_id"` // 退回供应商时记录
+	Note       string `json:"note"`
+}
+
+// ReleaseQuarantine 隔离库存复检合格后放行为可售库存
+func (qc *QuarantineController) ReleaseQuarantine(c *gin.Context) {
+	var request QuarantineRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 获取当前用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return
+	}
+
+	note := "隔离库存放行"
+	if request.Note != "" {
+		note += ": " + request.Note
+	}
+
+	tx := qc.db.Begin()
+	inventory, err := qc.ledger.ReleaseQuarantine(tx, request.StoreID, request.ProductID, request.Quantity, userID.(uint), note)
+	if err != nil {
+		tx.Rollback()
+		respondLedgerError(c, err)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "提交事务失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, inventory)
+}
+
+// ReturnQuarantineToSupplier 隔离库存退回供应商
+func (qc *QuarantineController) ReturnQuarantineToSupplier(c *gin.Context) {
+	var request QuarantineRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 获取当前用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return
+	}
+
+	note := "隔离库存退回供应商"
+	if request.SupplierID != nil {
+		var supplier models.Supplier
+		if err := qc.db.First(&supplier, *request.SupplierID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "供应商不存在"})
+			return
+		}
+		note = fmt.Sprintf("隔离库存退回供应商: %s", supplier.Name)
+	}
+	if request.Note != "" {
+		note += ", " + request.Note
+	}
+
+	transaction := models.InventoryTransaction{
+		TransactionType: models.SupplierReturn,
+		ProductID:       request.ProductID,
+		StoreID:         request.StoreID,
+		Quantity:        -request.Quantity, // 负数表示出库
+		Bucket:          models.BucketQuarantine,
+		ReferenceID:     request.SupplierID,
+		ReferenceType:   "supplier",
+		OperatorID:      userID.(uint),
+		Note:            note,
+	}
+
+	tx := qc.db.Begin()
+	inventory, err := qc.ledger.Post(tx, &transaction)
+	if err != nil {
+		tx.Rollback()
+		respondLedgerError(c, err)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "提交事务失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, inventory)
+}
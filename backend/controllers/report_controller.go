@@ -0,0 +1,244 @@
+package controllers
+
+import (
+	"hd_psi/backend/models"
+	"hd_psi/backend/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ReportController struct {
+	db *gorm.DB
+}
+
+func NewReportController(db *gorm.DB) *ReportController {
+	return &ReportController{db: db}
+}
+
+// 库存估值报表查询参数
+type InventoryValuationQuery struct {
+	StoreID    uint `form:"store_id"`
+	CategoryID uint `form:"category_id"` // 包含子类别
+}
+
+// 库存估值明细
+type InventoryValuationItem struct {
+	StoreID     uint    `json:"store_id"`
+	StoreName   string  `json:"store_name"`
+	ProductID   uint    `json:"product_id"`
+	SKU         string  `json:"sku"`
+	ProductName string  `json:"product_name"`
+	CategoryID  *uint   `json:"category_id"`
+	Category    string  `json:"category"`
+	Quantity    int     `json:"quantity"`
+	AvgCost     float64 `json:"avg_cost"`
+	Value       float64 `json:"value"`
+}
+
+// 库存估值汇总
+type InventoryValuationSummary struct {
+	StoreID    uint    `json:"store_id"`
+	CategoryID *uint   `json:"category_id"`
+	Category   string  `json:"category"`
+	Quantity   int     `json:"quantity"`
+	Value      float64 `json:"value"`
+}
+
+// GetInventoryValuation 按移动加权平均成本计算库存价值
+func (rc *ReportController) GetInventoryValuation(c *gin.Context) {
+	var query InventoryValuationQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := rc.db.Table("inventories").
+		Joins("JOIN products ON products.id = inventories.product_id").
+		Joins("LEFT JOIN stores ON stores.id = inventories.store_id").
+		Joins("LEFT JOIN categories ON categories.id = products.category_id").
+		Where("inventories.quantity <> 0")
+	if query.StoreID != 0 {
+		db = db.Where("inventories.store_id = ?", query.StoreID)
+	}
+	if query.CategoryID != 0 {
+		db = services.ApplyCategoryFilter(db, "products.category_id", query.CategoryID)
+	}
+
+	var items []InventoryValuationItem
+	if err := db.Session(&gorm.Session{}).
+		Select("inventories.store_id, stores.name AS store_name, inventories.product_id, products.sku, products.name AS product_name, " +
+			"products.category_id, products.category, inventories.quantity, inventories.avg_cost, inventories.quantity * inventories.avg_cost AS value").
+		Order("inventories.store_id, inventories.product_id").
+		Scan(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var summary []InventoryValuationSummary
+	if err := db.Session(&gorm.Session{}).
+		Select("inventories.store_id, products.category_id, categories.name AS category, SUM(inventories.quantity) AS quantity, SUM(inventories.quantity * inventories.avg_cost) AS value").
+		Group("inventories.store_id, products.category_id, categories.name, categories.path").
+		Order("inventories.store_id, categories.path").
+		Scan(&summary).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var totalValue float64
+	for _, s := range summary {
+		totalValue += s.Value
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"total_value": totalValue,
+		"summary":     summary,
+		"items":       items,
+	})
+}
+
+// 销售成本报表查询参数
+type COGSQuery struct {
+	StoreID    uint   `form:"store_id"`
+	CategoryID uint   `form:"category_id"` // 包含子类别
+	StartDate  string `form:"start_date" binding:"required"`
+	EndDate    string `form:"end_date" binding:"required"`
+}
+
+// 销售成本汇总
+type COGSItem struct {
+	StoreID      uint    `json:"store_id"`
+	CategoryID   *uint   `json:"category_id"`
+	Category     string  `json:"category"`
+	SoldQuantity int     `json:"sold_quantity"` // 销售和换货出库数量，已扣除退货
+	COGS         float64 `json:"cogs"`          // 销售成本，已扣除退货成本
+}
+
+// GetCOGS 按出库时登记的成本汇总期间销售成本，退货入库冲减销售成本
+func (rc *ReportController) GetCOGS(c *gin.Context) {
+	var query COGSQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := rc.db.Table("inventory_transactions").
+		Select("inventory_transactions.store_id, products.category_id, categories.name AS category, "+
+			"-SUM(inventory_transactions.quantity) AS sold_quantity, "+
+			"-SUM(inventory_transactions.quantity * inventory_transactions.unit_cost) AS cogs").
+		Joins("JOIN products ON products.id = inventory_transactions.product_id").
+		Joins("LEFT JOIN categories ON categories.id = products.category_id").
+		Where("inventory_transactions.transaction_type IN ?", []models.TransactionType{models.SaleOut, models.ExchangeOut, models.ReturnIn}).
+		Where("inventory_transactions.created_at >= ? AND inventory_transactions.created_at <= ?", query.StartDate, query.EndDate+" 23:59:59")
+	if query.StoreID != 0 {
+		db = db.Where("inventory_transactions.store_id = ?", query.StoreID)
+	}
+	if query.CategoryID != 0 {
+		db = services.ApplyCategoryFilter(db, "products.category_id", query.CategoryID)
+	}
+
+	var items []COGSItem
+	if err := db.Group("inventory_transactions.store_id, products.category_id, categories.name, categories.path").
+		Order("inventory_transactions.store_id, categories.path").
+		Scan(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var total float64
+	for _, item := range items {
+		total += item.COGS
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"start_date": query.StartDate,
+		"end_date":   query.EndDate,
+		"total_cogs": total,
+		"items":      items,
+	})
+}
+
+// 损耗报表查询参数，月份格式为 2006-01
+type ShrinkageQuery struct {
+	StoreID    uint   `form:"store_id"`
+	CategoryID uint   `form:"category_id"` // 包含子类别
+	StartMonth string `form:"start_month" binding:"required"`
+	EndMonth   string `form:"end_month"`
+}
+
+// 月度损耗汇总
+type ShrinkageItem struct {
+	Month         string  `json:"month"`
+	StoreID       uint    `json:"store_id"`
+	CategoryID    *uint   `json:"category_id"`
+	Category      string  `json:"category"`
+	DamageQty     int     `json:"damage_qty"`      // 报损数量
+	DamageValue   float64 `json:"damage_value"`    // 报损金额
+	CheckLossQty  int     `json:"check_loss_qty"`  // 盘点净亏数量，盘盈为负数
+	CheckLossCost float64 `json:"check_loss_cost"` // 盘点净亏金额
+	TotalValue    float64 `json:"total_value"`     // 损耗合计金额
+}
+
+// GetShrinkage 按月份、店铺和品类汇总报损出库和盘点亏损
+func (rc *ReportController) GetShrinkage(c *gin.Context) {
+	var query ShrinkageQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if query.EndMonth == "" {
+		query.EndMonth = query.StartMonth
+	}
+
+	start, err := time.ParseInLocation("2006-01", query.StartMonth, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "开始月份格式错误，应为YYYY-MM"})
+		return
+	}
+	end, err := time.ParseInLocation("2006-01", query.EndMonth, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "结束月份格式错误，应为YYYY-MM"})
+		return
+	}
+
+	db := rc.db.Table("inventory_transactions").
+		Select("DATE_FORMAT(inventory_transactions.created_at, '%Y-%m') AS month, inventory_transactions.store_id, products.category_id, categories.name AS category, "+
+			"-SUM(CASE WHEN inventory_transactions.transaction_type = ? THEN inventory_transactions.quantity ELSE 0 END) AS damage_qty, "+
+			"-SUM(CASE WHEN inventory_transactions.transaction_type = ? THEN inventory_transactions.quantity * inventory_transactions.unit_cost ELSE 0 END) AS damage_value, "+
+			"-SUM(CASE WHEN inventory_transactions.transaction_type = ? THEN inventory_transactions.quantity ELSE 0 END) AS check_loss_qty, "+
+			"-SUM(CASE WHEN inventory_transactions.transaction_type = ? THEN inventory_transactions.quantity * inventory_transactions.unit_cost ELSE 0 END) AS check_loss_cost",
+			models.DamageOut, models.DamageOut, models.CheckAdjust, models.CheckAdjust).
+		Joins("JOIN products ON products.id = inventory_transactions.product_id").
+		Joins("LEFT JOIN categories ON categories.id = products.category_id").
+		Where("inventory_transactions.transaction_type IN ?", []models.TransactionType{models.DamageOut, models.CheckAdjust}).
+		Where("inventory_transactions.created_at >= ? AND inventory_transactions.created_at < ?", start, end.AddDate(0, 1, 0))
+	if query.StoreID != 0 {
+		db = db.Where("inventory_transactions.store_id = ?", query.StoreID)
+	}
+	if query.CategoryID != 0 {
+		db = services.ApplyCategoryFilter(db, "products.category_id", query.CategoryID)
+	}
+
+	var items []ShrinkageItem
+	if err := db.Group("month, inventory_transactions.store_id, products.category_id, categories.name, categories.path").
+		Order("month, inventory_transactions.store_id, categories.path").
+		Scan(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var total float64
+	for i := range items {
+		items[i].TotalValue = items[i].DamageValue + items[i].CheckLossCost
+		total += items[i].TotalValue
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"start_month": query.StartMonth,
+		"end_month":   query.EndMonth,
+		"total_value": total,
+		"items":       items,
+	})
+}
@@ -1,22 +1,27 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
+	"hd_psi/backend/config"
 	"hd_psi/backend/models"
+	"hd_psi/backend/services"
 	"hd_psi/backend/utils"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type SalesController struct {
-	db *gorm.DB
+	db     *gorm.DB
+	ledger *services.InventoryLedger
 }
 
 func NewSalesController(db *gorm.DB) *SalesController {
-	return &SalesController{db: db}
+	return &SalesController{db: db, ledger: services.NewInventoryLedger(db)}
 }
 
 // ListOrders 获取销售订单列表
@@ -105,14 +110,14 @@ func (sc *SalesController) CreateOrder(c *gin.Context) {
 		Note            string                `json:"note"`
 		Items           []struct {
 			ProductID       uint    `json:"product_id" binding:"required"`
-			Quantity        int     `json:"quantity" binding:"required"`
+			Quantity        int     `json:"quantity" binding:"required,min=1"`
 			RetailPrice     float64 `json:"retail_price" binding:"required"`
 			ActualPrice     float64 `json:"actual_price" binding:"required"`
 			QRCodeData      string  `json:"qr_code_data"`
 			InitialPrice    float64 `json:"initial_price"`
 			NegotiationCount int    `json:"negotiation_count"`
 			NegotiationNote string  `json:"negotiation_note"`
-		} `json:"items" binding:"required"`
+		} `json:"items" binding:"required,dive"`
 	}
 	
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -121,10 +126,31 @@ func (sc *SalesController) CreateOrder(c *gin.Context) {
 	}
 	
 	// 开始事务
+	now := time.Now()
 	tx := sc.db.Begin()
 	
+	// 按价格表校验零售价和成交价，客户端提交的零售价必须与下单时的售价一致
+	minRate := config.GetMinSaleRate()
+	for _, item := range input.Items {
+		resolved, err := services.CheckSalePrice(tx, item.ProductID, input.StoreID, now, item.RetailPrice)
+		if err != nil {
+			tx.Rollback()
+			var mismatch *services.PriceMismatchError
+			if errors.As(err, &mismatch) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "product_id": item.ProductID, "expected_price": resolved.Price})
+			} else {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			}
+			return
+		}
+		if err := services.CheckActualPrice(resolved, item.ActualPrice, minRate); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "product_id": item.ProductID})
+			return
+		}
+	}
+	
 	// 生成订单编号 (格式: SO + 年月日 + 4位序号)
-	now := time.Now()
 	var count int64
 	tx.Model(&models.SalesOrder{}).Where("DATE(created_at) = DATE(?)", now).Count(&count)
 	orderNumber := fmt.Sprintf("SO%s%04d", now.Format("20060102"), count+1)
@@ -180,11 +206,10 @@ func (sc *SalesController) CreateOrder(c *gin.Context) {
 	var negotiations []models.NegotiationRecord
 	
 	for _, item := range input.Items {
-		// 验证QR码数据
+		// 验证QR码数据，扫码商品按二维码中的批次出库
+		var batchNumber string
 		if item.QRCodeData != "" {
-			// 假设有一个密钥用于验证QR码
-			secretKey := "your-secret-key" // 实际应用中应从配置中获取
-			qrData, valid, err := utils.VerifyQRCode(item.QRCodeData, secretKey)
+			qrData, valid, err := utils.VerifyQRCode(item.QRCodeData, config.GetQRSecretKey())
 			if err != nil || !valid {
 				tx.Rollback()
 				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid QR code data"})
@@ -204,6 +229,7 @@ func (sc *SalesController) CreateOrder(c *gin.Context) {
 				c.JSON(http.StatusBadRequest, gin.H{"error": "QR code does not match product"})
 				return
 			}
+			batchNumber = qrData.BatchNumber
 		}
 		
 		// 创建订单明细
@@ -246,45 +272,31 @@ func (sc *SalesController) CreateOrder(c *gin.Context) {
 			negotiations = append(negotiations, negotiation)
 		}
 		
-		// 更新库存
-		var inventory models.Inventory
-		result := tx.Where("store_id = ? AND product_id = ?", input.StoreID, item.ProductID).First(&inventory)
-		
-		if result.Error != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get inventory: " + result.Error.Error()})
-			return
-		}
-		
-		// 检查库存是否足够
-		if inventory.Quantity < item.Quantity {
-			tx.Rollback()
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient inventory for product ID " + fmt.Sprint(item.ProductID)})
-			return
-		}
-		
-		// 减少库存
-		inventory.Quantity -= item.Quantity
-		if err := tx.Save(&inventory).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update inventory: " + err.Error()})
-			return
-		}
-		
-		// 创建库存交易记录
-		transaction := models.InventoryTransaction{
-			TransactionType: models.SaleOut,
-			ProductID:       item.ProductID,
-			StoreID:         input.StoreID,
-			Quantity:        -item.Quantity, // 负数表示出库
-			ReferenceID:     &order.ID,
-			ReferenceType:   "sales_order",
-			OperatorID:      input.SalesPersonID,
+		// 线上订单只预留库存，支付后再转为销售出库
+		if order.Source == models.Online || order.Source == models.WeChat {
+			reservation := models.StockReservation{
+				StoreID:     input.StoreID,
+				ProductID:   item.ProductID,
+				OrderID:     order.ID,
+				OrderItemID: orderItem.ID,
+				Quantity:    item.Quantity,
+				BatchNumber: batchNumber,
+				ExpiresAt:   now.Add(config.GetReservationTTL()),
+			}
+			
+			if _, err := sc.ledger.Reserve(tx, &reservation); err != nil {
+				tx.Rollback()
+				respondLedgerError(c, err)
+				return
+			}
+			continue
 		}
 		
-		if err := tx.Create(&transaction).Error; err != nil {
+		// 销售出库
+		transaction := saleOutTransaction(order, item.ProductID, item.Quantity, batchNumber)
+		if _, err := sc.ledger.Post(tx, &transaction); err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create inventory transaction: " + err.Error()})
+			respondLedgerError(c, err)
 			return
 		}
 	}
@@ -322,13 +334,9 @@ func (sc *SalesController) CreateOrder(c *gin.Context) {
 }
 
 // UpdateOrderStatus 更新订单状态
+// 待支付订单支付时预留库存转为销售出库，取消时释放预留库存
 func (sc *SalesController) UpdateOrderStatus(c *gin.Context) {
 	id := c.Param("id")
-	var order models.SalesOrder
-	if err := sc.db.First(&order, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
-		return
-	}
 	
 	var input struct {
 		Status string `json:"status" binding:"required"`
@@ -339,17 +347,81 @@ func (sc *SalesController) UpdateOrderStatus(c *gin.Context) {
 		return
 	}
 	
+	// 开始事务
+	tx := sc.db.Begin()
+	
+	// 锁定订单，避免与预留过期任务并发
+	var order models.SalesOrder
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, id).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		return
+	}
+	
+	newStatus := models.OrderStatus(input.Status)
+	if !order.Status.CanTransitionTo(newStatus) {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("订单状态不能从 %s 变更为 %s", order.Status, newStatus)})
+		return
+	}
+	
+	// 离开已创建状态时处理预留：取消则释放，其余（支付、发货、完成）转为销售出库
+	if order.Status == models.Created {
+		var reservations []models.StockReservation
+		if err := tx.Where("order_id = ? AND status = ?", order.ID, models.ReservationActive).Find(&reservations).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		
+		for i := range reservations {
+			var err error
+			if newStatus != models.OrderCancelled {
+				transaction := saleOutTransaction(order, reservations[i].ProductID, reservations[i].Quantity, reservations[i].BatchNumber)
+				_, err = sc.ledger.Convert(tx, &reservations[i], &transaction)
+			} else {
+				_, err = sc.ledger.Release(tx, &reservations[i], models.ReservationReleased)
+			}
+			if err != nil {
+				tx.Rollback()
+				respondLedgerError(c, err)
+				return
+			}
+		}
+	}
+	
 	// 更新状态
-	order.Status = models.OrderStatus(input.Status)
+	order.Status = newStatus
 	
-	if err := sc.db.Save(&order).Error; err != nil {
+	if err := tx.Save(&order).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction: " + err.Error()})
+		return
+	}
+	
 	c.JSON(http.StatusOK, order)
 }
 
+// saleOutTransaction 构造销售订单的销售出库交易
+func saleOutTransaction(order models.SalesOrder, productID uint, quantity int, batchNumber string) models.InventoryTransaction {
+	return models.InventoryTransaction{
+		TransactionType: models.SaleOut,
+		ProductID:       productID,
+		StoreID:         order.StoreID,
+		Quantity:        -quantity, // 负数表示出库
+		BatchNumber:     batchNumber,
+		ReferenceID:     &order.ID,
+		ReferenceType:   "sales_order",
+		OperatorID:      order.SalesPersonID,
+	}
+}
+
 // CreateReturnOrder 创建退换货单
 func (sc *SalesController) CreateReturnOrder(c *gin.Context) {
 	var input struct {
@@ -363,12 +435,12 @@ func (sc *SalesController) CreateReturnOrder(c *gin.Context) {
 		Items           []struct {
 			OrderItemID     uint    `json:"order_item_id" binding:"required"`
 			ProductID       uint    `json:"product_id" binding:"required"`
-			Quantity        int     `json:"quantity" binding:"required"`
+			Quantity        int     `json:"quantity" binding:"required,min=1"`
 			ReturnPrice     float64 `json:"return_price" binding:"required"`
 			QRCodeData      string  `json:"qr_code_data"`
 			ExchangeProductID *uint  `json:"exchange_product_id"`
 			ExchangeQuantity  *int   `json:"exchange_quantity"`
-		} `json:"items" binding:"required"`
+		} `json:"items" binding:"required,dive"`
 	}
 	
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -457,82 +529,47 @@ func (sc *SalesController) CreateReturnOrder(c *gin.Context) {
 		
 		returnItems = append(returnItems, returnItem)
 		
-		// 更新库存（退货入库）
-		var inventory models.Inventory
-		result := tx.Where("store_id = ? AND product_id = ?", input.StoreID, item.ProductID).First(&inventory)
-		
-		if result.Error != nil {
-			if result.Error == gorm.ErrRecordNotFound {
-				// 如果库存记录不存在，创建新记录
-				inventory = models.Inventory{
-					StoreID:   input.StoreID,
-					ProductID: item.ProductID,
-					Quantity:  item.Quantity,
-				}
-				if err := tx.Create(&inventory).Error; err != nil {
-					tx.Rollback()
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create inventory: " + err.Error()})
-					return
-				}
-			} else {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get inventory: " + result.Error.Error()})
-				return
-			}
-		} else {
-			// 增加库存
-			inventory.Quantity += item.Quantity
-			if err := tx.Save(&inventory).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update inventory: " + err.Error()})
-				return
+		// 退货入库，有二维码时退回原批次
+		var batchNumber string
+		if item.QRCodeData != "" {
+			if qrData, valid, err := utils.VerifyQRCode(item.QRCodeData, config.GetQRSecretKey()); err == nil && valid {
+				batchNumber = qrData.BatchNumber
 			}
 		}
 		
-		// 创建库存交易记录（退货入库）
+		// 退货按原销售出库成本入库
+		var unitCost float64
+		if err := tx.Model(&models.InventoryTransaction{}).
+			Select("COALESCE(SUM(quantity * unit_cost) / NULLIF(SUM(quantity), 0), 0)").
+			Where("reference_type = ? AND reference_id = ? AND product_id = ? AND transaction_type = ?",
+				"sales_order", input.OrderID, item.ProductID, models.SaleOut).
+			Scan(&unitCost).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query sale cost: " + err.Error()})
+			return
+		}
+		
 		transaction := models.InventoryTransaction{
 			TransactionType: models.ReturnIn,
 			ProductID:       item.ProductID,
 			StoreID:         input.StoreID,
 			Quantity:        item.Quantity, // 正数表示入库
+			UnitCost:        unitCost,
+			BatchNumber:     batchNumber,
 			ReferenceID:     &returnOrder.ID,
 			ReferenceType:   "return_order",
 			OperatorID:      input.ProcessorID,
 		}
 		
-		if err := tx.Create(&transaction).Error; err != nil {
+		if _, err := sc.ledger.Post(tx, &transaction); err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create inventory transaction: " + err.Error()})
+			respondLedgerError(c, err)
 			return
 		}
 		
 		// 如果是换货，处理换货商品出库
 		if input.ReturnType == "exchange" && item.ExchangeProductID != nil && item.ExchangeQuantity != nil {
-			// 验证换货商品库存是否足够
-			var exchangeInventory models.Inventory
-			result := tx.Where("store_id = ? AND product_id = ?", input.StoreID, *item.ExchangeProductID).First(&exchangeInventory)
-			
-			if result.Error != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get exchange product inventory: " + result.Error.Error()})
-				return
-			}
-			
-			if exchangeInventory.Quantity < *item.ExchangeQuantity {
-				tx.Rollback()
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient inventory for exchange product"})
-				return
-			}
-			
-			// 减少换货商品库存
-			exchangeInventory.Quantity -= *item.ExchangeQuantity
-			if err := tx.Save(&exchangeInventory).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update exchange product inventory: " + err.Error()})
-				return
-			}
-			
-			// 创建库存交易记录（换货出库）
+			// 换货出库
 			exchangeTransaction := models.InventoryTransaction{
 				TransactionType: models.ExchangeOut,
 				ProductID:       *item.ExchangeProductID,
@@ -543,9 +580,9 @@ func (sc *SalesController) CreateReturnOrder(c *gin.Context) {
 				OperatorID:      input.ProcessorID,
 			}
 			
-			if err := tx.Create(&exchangeTransaction).Error; err != nil {
+			if _, err := sc.ledger.Post(tx, &exchangeTransaction); err != nil {
 				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create exchange inventory transaction: " + err.Error()})
+				respondLedgerError(c, err)
 				return
 			}
 		}
@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"errors"
+	"hd_psi/backend/models"
+	"hd_psi/backend/scheduler"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type SchedulerController struct {
+	db        *gorm.DB
+	scheduler *scheduler.Scheduler
+}
+
+func NewSchedulerController(db *gorm.DB, s *scheduler.Scheduler) *SchedulerController {
+	return &SchedulerController{db: db, scheduler: s}
+}
+
+// ListJobs 获取定时任务列表
+func (sc *SchedulerController) ListJobs(c *gin.Context) {
+	var jobs []models.ScheduledJob
+	if err := sc.db.Order("name").Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, jobs)
+}
+
+// 任务执行记录列表请求参数
+type ListJobRunsQuery struct {
+	JobID    uint   `form:"job_id"`
+	Status   string `form:"status"`
+	Page     int    `form:"page,default=1"`
+	PageSize int    `form:"page_size,default=20"`
+}
+
+// 任务执行记录列表响应
+type JobRunsResponse struct {
+	Total int             `json:"total"`
+	Items []models.JobRun `json:"items"`
+}
+
+// ListJobRuns 获取任务执行记录
+func (sc *SchedulerController) ListJobRuns(c *gin.Context) {
+	var query ListJobRunsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := sc.db.Model(&models.JobRun{})
+	if query.JobID != 0 {
+		db = db.Where("job_id = ?", query.JobID)
+	}
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+
+	var total int64
+	db.Count(&total)
+
+	offset := (query.Page - 1) * query.PageSize
+	var runs []models.JobRun
+	if err := db.Offset(offset).Limit(query.PageSize).
+		Order("started_at DESC").
+		Find(&runs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, JobRunsResponse{
+		Total: int(total),
+		Items: runs,
+	})
+}
+
+// TriggerJob 立即执行定时任务
+func (sc *SchedulerController) TriggerJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的任务ID"})
+		return
+	}
+
+	run, err := sc.scheduler.Trigger(uint(id))
+	if err != nil {
+		respondSchedulerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, run)
+}
+
+// PauseJob 暂停定时任务
+func (sc *SchedulerController) PauseJob(c *gin.Context) {
+	sc.setPaused(c, true)
+}
+
+// ResumeJob 恢复定时任务
+func (sc *SchedulerController) ResumeJob(c *gin.Context) {
+	sc.setPaused(c, false)
+}
+
+// setPaused 暂停或恢复定时任务
+func (sc *SchedulerController) setPaused(c *gin.Context, paused bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的任务ID"})
+		return
+	}
+
+	job, err := sc.scheduler.SetPaused(uint(id), paused)
+	if err != nil {
+		respondSchedulerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// 修改执行计划请求
+type UpdateJobRequest struct {
+	Cron string `json:"cron" binding:"required"`
+}
+
+// UpdateJob 修改定时任务执行计划
+func (sc *SchedulerController) UpdateJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的任务ID"})
+		return
+	}
+
+	var request UpdateJobRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, err := sc.scheduler.UpdateCron(uint(id), request.Cron)
+	if err != nil {
+		respondSchedulerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// respondSchedulerError 将调度器错误转换为HTTP响应
+func respondSchedulerError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, scheduler.ErrJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, scheduler.ErrJobLocked):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, scheduler.ErrInvalidCron):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,255 @@
+package controllers
+
+import (
+	"errors"
+	"hd_psi/backend/models"
+	"hd_psi/backend/services"
+	"hd_psi/backend/skucode"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type SKUCodeController struct {
+	db *gorm.DB
+}
+
+func NewSKUCodeController(db *gorm.DB) *SKUCodeController {
+	return &SKUCodeController{db: db}
+}
+
+// ListSKUCodes 获取SKU编码字典，可按类型过滤
+func (sc *SKUCodeController) ListSKUCodes(c *gin.Context) {
+	db := sc.db.Model(&models.SKUCode{})
+	if kind := c.Query("kind"); kind != "" {
+		db = db.Where("kind = ?", kind)
+	}
+
+	var codes []models.SKUCode
+	if err := db.Order("kind, code").Find(&codes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, codes)
+}
+
+// SKU编码字典条目请求参数
+type SKUCodeRequest struct {
+	Kind models.SKUCodeKind `json:"kind" binding:"required"`
+	Code string             `json:"code" binding:"required"`
+	Name string             `json:"name" binding:"required"`
+	// 品类对应的类别树节点，为空时在首次按该品类建档时按名称查找或新建类别并关联
+	CategoryID *uint `json:"category_id"`
+}
+
+// CreateSKUCode 新增SKU编码字典条目
+func (sc *SKUCodeController) CreateSKUCode(c *gin.Context) {
+	var request SKUCodeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entry := models.SKUCode{Kind: request.Kind, Code: request.Code, Name: request.Name, CategoryID: request.CategoryID}
+	if err := skucode.Normalize(&entry); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !sc.validCategory(c, &entry) {
+		return
+	}
+	if sc.duplicated(c, &entry) {
+		return
+	}
+
+	if err := sc.db.Create(&entry).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, entry)
+}
+
+// UpdateSKUCode 修改SKU编码字典条目，已被商品使用的条目不能修改编码
+func (sc *SKUCodeController) UpdateSKUCode(c *gin.Context) {
+	id := c.Param("id")
+	var request SKUCodeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var entry models.SKUCode
+	if err := sc.db.First(&entry, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "SKU code not found"})
+		return
+	}
+
+	updated := entry
+	updated.Kind, updated.Code, updated.Name = request.Kind, request.Code, request.Name
+	updated.CategoryID = request.CategoryID
+	if err := skucode.Normalize(&updated); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !sc.validCategory(c, &updated) {
+		return
+	}
+	if updated.Kind != entry.Kind || updated.Code != entry.Code || updated.Name != entry.Name {
+		inUse, err := sc.inUse(&entry)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if inUse {
+			c.JSON(http.StatusConflict, gin.H{"error": "SKU code is used by existing products"})
+			return
+		}
+	}
+	if sc.duplicated(c, &updated) {
+		return
+	}
+
+	if err := sc.db.Save(&updated).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteSKUCode 删除SKU编码字典条目，已被商品使用的条目不能删除
+func (sc *SKUCodeController) DeleteSKUCode(c *gin.Context) {
+	id := c.Param("id")
+	var entry models.SKUCode
+	if err := sc.db.First(&entry, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "SKU code not found"})
+		return
+	}
+
+	inUse, err := sc.inUse(&entry)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if inUse {
+		c.JSON(http.StatusConflict, gin.H{"error": "SKU code is used by existing products"})
+		return
+	}
+
+	if err := sc.db.Delete(&entry).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "SKU code deleted"})
+}
+
+// GenerateSKU 按编码规则由商品属性生成SKU
+func (sc *SKUCodeController) GenerateSKU(c *gin.Context) {
+	var attr skucode.Attributes
+	if err := c.ShouldBindJSON(&attr); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codec, err := skucode.Load(sc.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	sku, err := codec.Encode(attr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var count int64
+	sc.db.Model(&models.Product{}).Where("sku = ?", sku).Count(&count)
+	c.JSON(http.StatusOK, gin.H{
+		"sku":    sku,
+		"exists": count > 0,
+	})
+}
+
+// ParseSKU 将SKU解析为商品属性
+func (sc *SKUCodeController) ParseSKU(c *gin.Context) {
+	codec, err := skucode.Load(sc.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	attr, err := codec.Decode(c.Query("sku"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, attr)
+}
+
+// ListNonconformingSKUs 列出SKU不符合编码规则的商品
+func (sc *SKUCodeController) ListNonconformingSKUs(c *gin.Context) {
+	violations, err := skucode.NonconformingProducts(sc.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"total": len(violations),
+		"items": violations,
+	})
+}
+
+// duplicated 检查同类型下编码或属性值是否已被其他条目使用，已使用时写入冲突响应
+func (sc *SKUCodeController) duplicated(c *gin.Context, entry *models.SKUCode) bool {
+	var count int64
+	if err := sc.db.Model(&models.SKUCode{}).
+		Where("kind = ? AND (code = ? OR name = ?) AND id <> ?", entry.Kind, entry.Code, entry.Name, entry.ID).
+		Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return true
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "SKU code or name already exists"})
+		return true
+	}
+	return false
+}
+
+// validCategory 校验条目关联的类别节点，只有品类条目可以关联类别，类别无效时写入错误响应
+func (sc *SKUCodeController) validCategory(c *gin.Context, entry *models.SKUCode) bool {
+	if entry.CategoryID == nil {
+		return true
+	}
+	if entry.Kind != models.SKUCategory {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only category codes can be linked to a category"})
+		return false
+	}
+	if _, err := services.FindCategory(sc.db, *entry.CategoryID); err != nil {
+		respondCategoryError(c, err)
+		return false
+	}
+	return true
+}
+
+// inUse 判断是否有商品使用该条目的属性值
+func (sc *SKUCodeController) inUse(entry *models.SKUCode) (bool, error) {
+	column := map[models.SKUCodeKind]string{
+		models.SKUCategory: "category",
+		models.SKUSeason:   "season",
+		models.SKUColor:    "color",
+		models.SKUSize:     "size",
+	}[entry.Kind]
+	if column == "" {
+		return false, errors.New("unknown SKU code kind")
+	}
+
+	query := sc.db.Model(&models.Product{}).Where(column+" = ?", entry.Name)
+	// 品类关联了类别节点时，商品的类别名称为节点名称
+	if entry.Kind == models.SKUCategory && entry.CategoryID != nil {
+		query = sc.db.Model(&models.Product{}).Where("category = ? OR category_id = ?", entry.Name, *entry.CategoryID)
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,372 @@
+package controllers
+
+import (
+	"errors"
+	"hd_psi/backend/media"
+	"hd_psi/backend/models"
+	"hd_psi/backend/services"
+	"hd_psi/backend/skucode"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type StyleController struct {
+	db    *gorm.DB
+	store media.Storage
+}
+
+func NewStyleController(db *gorm.DB, store media.Storage) *StyleController {
+	return &StyleController{db: db, store: store}
+}
+
+// 款式列表请求参数
+type ListStylesQuery struct {
+	Keyword  string `form:"keyword"` // 款号或名称
+	Category string `form:"category"`
+	Season   string `form:"season"`
+	Page     int    `form:"page,default=1"`
+	PageSize int    `form:"page_size,default=20"`
+}
+
+// 款式列表响应
+type StylesResponse struct {
+	Total int            `json:"total"`
+	Items []models.Style `json:"items"`
+}
+
+// ListStyles 获取款式列表
+func (sc *StyleController) ListStyles(c *gin.Context) {
+	var query ListStylesQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := sc.db.Model(&models.Style{})
+	if query.Keyword != "" {
+		keyword := "%" + query.Keyword + "%"
+		db = db.Where("code LIKE ? OR name LIKE ?", keyword, keyword)
+	}
+	if query.Category != "" {
+		db = db.Where("category = ?", query.Category)
+	}
+	if query.Season != "" {
+		db = db.Where("season = ?", query.Season)
+	}
+
+	var total int64
+	db.Count(&total)
+
+	offset := (query.Page - 1) * query.PageSize
+	var styles []models.Style
+	if err := db.Offset(offset).Limit(query.PageSize).
+		Order("code").
+		Find(&styles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, StylesResponse{
+		Total: int(total),
+		Items: styles,
+	})
+}
+
+// GetStyle 获取款式详情及其颜色尺码商品
+func (sc *StyleController) GetStyle(c *gin.Context) {
+	id := c.Param("id")
+	var style models.Style
+	if err := sc.db.Preload("Variants", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).First(&style, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Style not found"})
+		return
+	}
+	c.JSON(http.StatusOK, style)
+}
+
+// 款式请求参数
+type StyleRequest struct {
+	Code        string  `json:"code" binding:"required"`
+	Name        string  `json:"name" binding:"required"`
+	Season      string  `json:"season"`
+	Category    string  `json:"category"`
+	Image       string  `json:"image"`
+	CostPrice   float64 `json:"cost_price" binding:"min=0"`
+	RetailPrice float64 `json:"retail_price" binding:"min=0"`
+}
+
+// 创建款式请求参数，颜色和尺码都不为空时同时生成颜色×尺码商品
+type CreateStyleRequest struct {
+	StyleRequest
+	Colors []services.StyleColor `json:"colors" binding:"dive"`
+	Sizes  []string              `json:"sizes"`
+}
+
+// CreateStyle 创建款式，可同时生成整套颜色尺码商品
+func (sc *StyleController) CreateStyle(c *gin.Context) {
+	var request CreateStyleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	style := models.Style{
+		Code:        strings.ToUpper(strings.TrimSpace(request.Code)),
+		Name:        request.Name,
+		Season:      request.Season,
+		Category:    request.Category,
+		Image:       request.Image,
+		CostPrice:   request.CostPrice,
+		RetailPrice: request.RetailPrice,
+	}
+
+	err := sc.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Style{}).Where("code = ?", style.Code).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errStyleCodeExists
+		}
+		if err := tx.Create(&style).Error; err != nil {
+			return err
+		}
+		if len(request.Colors) == 0 && len(request.Sizes) == 0 {
+			return nil
+		}
+		variants, err := services.GenerateVariants(tx, &style, request.Colors, request.Sizes)
+		style.Variants = variants
+		return err
+	})
+	if err != nil {
+		respondStyleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, style)
+}
+
+// UpdateStyle 修改款式，并同步到款式下的商品
+func (sc *StyleController) UpdateStyle(c *gin.Context) {
+	id := c.Param("id")
+	var request StyleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var style models.Style
+	if err := sc.db.First(&style, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Style not found"})
+		return
+	}
+	if code := strings.ToUpper(strings.TrimSpace(request.Code)); code != style.Code {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Style code cannot be changed"})
+		return
+	}
+
+	old := style
+	style.Name = request.Name
+	style.Season = request.Season
+	style.Category = request.Category
+	style.Image = request.Image
+	style.CostPrice = request.CostPrice
+	style.RetailPrice = request.RetailPrice
+
+	err := sc.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Variants").Save(&style).Error; err != nil {
+			return err
+		}
+		return services.SyncVariants(tx, &old, &style)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, style)
+}
+
+// DeleteStyle 删除款式，款式下仍有商品时不允许删除
+func (sc *StyleController) DeleteStyle(c *gin.Context) {
+	id := c.Param("id")
+	var style models.Style
+	if err := sc.db.First(&style, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Style not found"})
+		return
+	}
+
+	var count int64
+	if err := sc.db.Model(&models.Product{}).Where("style_id = ?", style.ID).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Style still has variants"})
+		return
+	}
+
+	if err := sc.db.Delete(&style).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 清理款式图库，失败的文件由定时任务重试
+	if _, err := media.CleanupOrphans(sc.db, sc.store); err != nil {
+		log.Printf("清理款式图片失败: %v", err)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Style deleted"})
+}
+
+// 生成款式商品请求参数
+type GenerateVariantsRequest struct {
+	Colors []services.StyleColor `json:"colors" binding:"required,min=1,dive"`
+	Sizes  []string              `json:"sizes" binding:"required,min=1"`
+}
+
+// GenerateVariants 按颜色×尺码矩阵补充款式商品，已有的组合跳过
+func (sc *StyleController) GenerateVariants(c *gin.Context) {
+	id := c.Param("id")
+	var request GenerateVariantsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var style models.Style
+	if err := sc.db.First(&style, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Style not found"})
+		return
+	}
+
+	var variants []models.Product
+	err := sc.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		variants, err = services.GenerateVariants(tx, &style, request.Colors, request.Sizes)
+		return err
+	})
+	if err != nil {
+		respondStyleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"created": len(variants),
+		"items":   variants,
+	})
+}
+
+// GetStyleStock 获取款式颜色×尺码库存矩阵
+func (sc *StyleController) GetStyleStock(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid style ID"})
+		return
+	}
+	storeID, _ := strconv.ParseUint(c.Query("store_id"), 10, 32)
+
+	var style models.Style
+	if err := sc.db.First(&style, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Style not found"})
+		return
+	}
+
+	matrix, err := services.StyleStock(sc.db, style.ID, uint(storeID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, matrix)
+}
+
+// 款式汇总请求参数
+type StyleSummaryQuery struct {
+	StoreID   uint   `form:"store_id"`
+	Category  string `form:"category"`
+	Season    string `form:"season"`
+	StartDate string `form:"start_date"`
+	EndDate   string `form:"end_date"`
+}
+
+// ListStyleStock 按款式汇总库存
+func (sc *StyleController) ListStyleStock(c *gin.Context) {
+	var query StyleSummaryQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	items, err := services.StyleStockSummaries(sc.db, services.StyleFilter{
+		StoreID:  query.StoreID,
+		Category: query.Category,
+		Season:   query.Season,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// GetStyleSales 按款式汇总销量，含各尺码销量
+func (sc *StyleController) GetStyleSales(c *gin.Context) {
+	var query StyleSummaryQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	items, err := services.StyleSales(sc.db, services.StyleFilter{
+		StoreID:   query.StoreID,
+		Category:  query.Category,
+		Season:    query.Season,
+		StartDate: query.StartDate,
+		EndDate:   query.EndDate,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// GetStyleReplenishment 按款式和店铺汇总补货建议
+func (sc *StyleController) GetStyleReplenishment(c *gin.Context) {
+	var query ReplenishmentQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	items, err := services.ProposeStyleReplenishment(sc.db, services.ReplenishmentFilter{
+		StoreID:      query.StoreID,
+		SupplierID:   query.SupplierID,
+		VelocityDays: query.VelocityDays,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// errStyleCodeExists 款号已被其他款式使用
+var errStyleCodeExists = errors.New("Style code already exists")
+
+// respondStyleError 将款式相关错误转换为HTTP响应
+func respondStyleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errStyleCodeExists), errors.Is(err, services.ErrSKUExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrEmptyMatrix), errors.Is(err, skucode.ErrUnknownCode):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
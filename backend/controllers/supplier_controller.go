@@ -106,6 +106,7 @@ type CreateSupplierRequest struct {
 	Qualification string                `json:"qualification"`
 	PaymentTerms  string                `json:"payment_terms"`
 	DeliveryTerms string                `json:"delivery_terms"`
+	LeadTimeDays  *int                  `json:"lead_time_days" binding:"omitempty,min=0"` // 供货周期（天），不填时新建默认7天、更新时保持不变
 	Status        bool                  `json:"status"`
 	Note          string                `json:"note"`
 }
@@ -142,6 +143,9 @@ func (sc *SupplierController) CreateSupplier(c *gin.Context) {
 		Status:        request.Status,
 		Note:          request.Note,
 	}
+	if request.LeadTimeDays != nil {
+		supplier.LeadTimeDays = *request.LeadTimeDays
+	}
 
 	if err := sc.db.Create(&supplier).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建供应商失败: " + err.Error()})
@@ -192,6 +196,9 @@ func (sc *SupplierController) UpdateSupplier(c *gin.Context) {
 	supplier.DeliveryTerms = request.DeliveryTerms
 	supplier.Status = request.Status
 	supplier.Note = request.Note
+	if request.LeadTimeDays != nil {
+		supplier.LeadTimeDays = *request.LeadTimeDays
+	}
 
 	if err := sc.db.Save(&supplier).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新供应商失败: " + err.Error()})
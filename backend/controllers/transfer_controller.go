@@ -0,0 +1,759 @@
+package controllers
+
+import (
+	"fmt"
+	"hd_psi/backend/models"
+	"hd_psi/backend/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TransferController struct {
+	db     *gorm.DB
+	ledger *services.InventoryLedger
+}
+
+func NewTransferController(db *gorm.DB) *TransferController {
+	return &TransferController{db: db, ledger: services.NewInventoryLedger(db)}
+}
+
+// 调拨单列表请求参数
+type ListTransfersQuery struct {
+	Status        string `form:"status"`
+	SourceStoreID uint   `form:"source_store_id"`
+	DestStoreID   uint   `form:"dest_store_id"`
+	StartDate     string `form:"start_date"`
+	EndDate       string `form:"end_date"`
+	Page          int    `form:"page,default=1"`
+	PageSize      int    `form:"page_size,default=10"`
+}
+
+// 调拨单列表响应
+type TransfersResponse struct {
+	Total int                    `json:"total"`
+	Items []models.TransferOrder `json:"items"`
+}
+
+// ListTransfers 获取调拨单列表
+func (tc *TransferController) ListTransfers(c *gin.Context) {
+	var query ListTransfersQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 构建查询
+	db := tc.db.Model(&models.TransferOrder{})
+
+	// 应用过滤条件
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+	if query.SourceStoreID != 0 {
+		db = db.Where("source_store_id = ?", query.SourceStoreID)
+	}
+	if query.DestStoreID != 0 {
+		db = db.Where("dest_store_id = ?", query.DestStoreID)
+	}
+	if query.StartDate != "" {
+		db = db.Where("created_at >= ?", query.StartDate)
+	}
+	if query.EndDate != "" {
+		db = db.Where("created_at <= ?", query.EndDate+" 23:59:59")
+	}
+
+	// 计算总数
+	var total int64
+	db.Count(&total)
+
+	// 分页
+	offset := (query.Page - 1) * query.PageSize
+	var transfers []models.TransferOrder
+
+	if err := db.Preload("SourceStore").Preload("DestStore").
+		Offset(offset).Limit(query.PageSize).
+		Order("created_at DESC").
+		Find(&transfers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, TransfersResponse{
+		Total: int(total),
+		Items: transfers,
+	})
+}
+
+// GetTransfer 获取调拨单详情
+func (tc *TransferController) GetTransfer(c *gin.Context) {
+	id := c.Param("id")
+	var transfer models.TransferOrder
+
+	if err := tc.db.Preload("Items.Product").Preload("SourceStore").Preload("DestStore").
+		First(&transfer, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "调拨单不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, transfer)
+}
+
+// 在途库存汇总
+type InTransitStock struct {
+	SourceStoreID uint `json:"source_store_id"`
+	DestStoreID   uint `json:"dest_store_id"`
+	ProductID     uint `json:"product_id"`
+	Quantity      int  `json:"quantity"`
+}
+
+// ListInTransit 获取在途库存（已发货未收货的调拨数量）
+func (tc *TransferController) ListInTransit(c *gin.Context) {
+	var stocks []InTransitStock
+
+	db := tc.db.Table("transfer_order_items").
+		Select("transfer_orders.source_store_id, transfer_orders.dest_store_id, transfer_order_items.product_id, SUM(transfer_order_items.shipped_qty) AS quantity").
+		Joins("JOIN transfer_orders ON transfer_order_items.transfer_order_id = transfer_orders.id").
+		Where("transfer_orders.status = ?", models.TransferInTransit)
+
+	if storeID := c.Query("store_id"); storeID != "" {
+		db = db.Where("transfer_orders.source_store_id = ? OR transfer_orders.dest_store_id = ?", storeID, storeID)
+	}
+	if productID := c.Query("product_id"); productID != "" {
+		db = db.Where("transfer_order_items.product_id = ?", productID)
+	}
+
+	if err := db.Group("transfer_orders.source_store_id, transfer_orders.dest_store_id, transfer_order_items.product_id").
+		Scan(&stocks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, stocks)
+}
+
+// 调拨明细请求
+type TransferItemRequest struct {
+	ProductID uint   `json:"product_id" binding:"required"`
+	Quantity  int    `json:"quantity" binding:"required,min=1"`
+	Note      string `json:"note"`
+}
+
+// 创建调拨单请求
+type CreateTransferRequest struct {
+	SourceStoreID uint                  `json:"source_store_id" binding:"required"`
+	DestStoreID   uint                  `json:"dest_store_id" binding:"required"`
+	Note          string                `json:"note"`
+	Items         []TransferItemRequest `json:"items" binding:"required,min=1"`
+}
+
+// CreateTransfer 创建调拨单（草稿）
+func (tc *TransferController) CreateTransfer(c *gin.Context) {
+	var request CreateTransferRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if request.SourceStoreID == request.DestStoreID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "调出店铺和调入店铺不能相同"})
+		return
+	}
+
+	// 获取当前用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return
+	}
+
+	// 生成调拨单号
+	transferNumber := fmt.Sprintf("TO%s%04d", time.Now().Format("20060102"), 1)
+
+	// 查询当天最后一个调拨单号
+	var lastTransfer models.TransferOrder
+	tc.db.Where("transfer_number LIKE ?", "TO"+time.Now().Format("20060102")+"%").
+		Order("transfer_number DESC").
+		Limit(1).
+		Find(&lastTransfer)
+
+	if lastTransfer.ID != 0 {
+		// 提取序号并加1
+		seq, _ := strconv.Atoi(lastTransfer.TransferNumber[10:])
+		transferNumber = fmt.Sprintf("TO%s%04d", time.Now().Format("20060102"), seq+1)
+	}
+
+	// 开始事务
+	tx := tc.db.Begin()
+
+	transfer := models.TransferOrder{
+		TransferNumber: transferNumber,
+		SourceStoreID:  request.SourceStoreID,
+		DestStoreID:    request.DestStoreID,
+		Status:         models.TransferDraft,
+		CreatorID:      userID.(uint),
+		Note:           request.Note,
+	}
+
+	if err := tx.Create(&transfer).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建调拨单失败: " + err.Error()})
+		return
+	}
+
+	// 创建调拨明细
+	var items []models.TransferOrderItem
+	for _, item := range request.Items {
+		items = append(items, models.TransferOrderItem{
+			TransferOrderID: transfer.ID,
+			ProductID:       item.ProductID,
+			Quantity:        item.Quantity,
+			Note:            item.Note,
+		})
+	}
+
+	if err := tx.Create(&items).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建调拨明细失败: " + err.Error()})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "提交事务失败: " + err.Error()})
+		return
+	}
+
+	// 返回创建的调拨单
+	var result models.TransferOrder
+	tc.db.Preload("Items.Product").Preload("SourceStore").Preload("DestStore").
+		First(&result, transfer.ID)
+
+	c.JSON(http.StatusCreated, result)
+}
+
+// ApproveTransfer 审核调拨单
+func (tc *TransferController) ApproveTransfer(c *gin.Context) {
+	id := c.Param("id")
+	var transfer models.TransferOrder
+	if err := tc.db.First(&transfer, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "调拨单不存在"})
+		return
+	}
+
+	if transfer.Status != models.TransferDraft {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "只有草稿状态的调拨单可以审核"})
+		return
+	}
+
+	// 获取当前用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return
+	}
+
+	now := time.Now()
+	approverID := userID.(uint)
+	transfer.Status = models.TransferApproved
+	transfer.ApproverID = &approverID
+	transfer.ApprovalTime = &now
+
+	if err := tc.db.Save(&transfer).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "审核调拨单失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, transfer)
+}
+
+// 调拨数量确认请求（发货/收货）
+type TransferQuantityRequest struct {
+	Items []struct {
+		ItemID   uint `json:"item_id" binding:"required"`
+		Quantity int  `json:"quantity" binding:"min=0"`
+	} `json:"items"`
+	Note string `json:"note"`
+}
+
+// ShipTransfer 调拨发货，调出店铺记调拨出库，调拨单进入在途状态
+// 未提供明细数量时按计划数量发货
+func (tc *TransferController) ShipTransfer(c *gin.Context) {
+	id := c.Param("id")
+
+	var request TransferQuantityRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 获取当前用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return
+	}
+
+	// 开始事务
+	tx := tc.db.Begin()
+
+	transfer, err := lockTransfer(tx, id)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "调拨单不存在"})
+		return
+	}
+
+	if transfer.Status != models.TransferApproved {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "只有已审核的调拨单可以发货"})
+		return
+	}
+
+	shipped := make(map[uint]int)
+	for _, item := range request.Items {
+		shipped[item.ItemID] = item.Quantity
+	}
+	if err := checkTransferItems(transfer, shipped); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	for i := range transfer.Items {
+		item := &transfer.Items[i]
+		qty, ok := shipped[item.ID]
+		if !ok {
+			qty = item.Quantity
+		}
+		item.ShippedQty = qty
+
+		if err := tx.Save(item).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "更新调拨明细失败: " + err.Error()})
+			return
+		}
+
+		if qty == 0 {
+			continue
+		}
+
+		// 调出店铺记调拨出库
+		if err := tc.postMovement(tx, transfer.SourceStoreID, item.ProductID, -qty, models.TransferOut, nil, transfer.ID, userID.(uint),
+			fmt.Sprintf("调拨出库: %s", transfer.TransferNumber)); err != nil {
+			tx.Rollback()
+			respondLedgerError(c, err)
+			return
+		}
+	}
+
+	now := time.Now()
+	shipperID := userID.(uint)
+	transfer.Status = models.TransferInTransit
+	transfer.ShipperID = &shipperID
+	transfer.ShippedAt = &now
+	if request.Note != "" {
+		transfer.Note = request.Note
+	}
+
+	if err := tx.Omit("Items").Save(transfer).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新调拨单状态失败: " + err.Error()})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "提交事务失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, transfer)
+}
+
+// ReceiveTransfer 调拨收货，调入店铺按实收数量记调拨入库
+// 实收与发货数量不一致时调拨单进入差异待处理状态
+func (tc *TransferController) ReceiveTransfer(c *gin.Context) {
+	id := c.Param("id")
+
+	var request TransferQuantityRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 获取当前用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return
+	}
+
+	// 开始事务
+	tx := tc.db.Begin()
+
+	transfer, err := lockTransfer(tx, id)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "调拨单不存在"})
+		return
+	}
+
+	if transfer.Status != models.TransferInTransit {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "只有在途的调拨单可以收货"})
+		return
+	}
+
+	received := make(map[uint]int)
+	for _, item := range request.Items {
+		received[item.ItemID] = item.Quantity
+	}
+	if err := checkTransferItems(transfer, received); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hasDiscrepancy := false
+	for i := range transfer.Items {
+		item := &transfer.Items[i]
+		qty, ok := received[item.ID]
+		if !ok {
+			qty = item.ShippedQty
+		}
+		item.ReceivedQty = qty
+		item.DifferenceQty = qty - item.ShippedQty
+		if item.DifferenceQty != 0 {
+			hasDiscrepancy = true
+		}
+
+		if err := tx.Save(item).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "更新调拨明细失败: " + err.Error()})
+			return
+		}
+
+		if qty == 0 {
+			continue
+		}
+
+		// 调入店铺按发货批次记调拨入库
+		sourceStoreID := transfer.SourceStoreID
+		if err := tc.postInbound(tx, transfer.ID, item.ProductID, transfer.DestStoreID, &sourceStoreID, 0, qty, userID.(uint),
+			fmt.Sprintf("调拨入库: %s", transfer.TransferNumber)); err != nil {
+			tx.Rollback()
+			respondLedgerError(c, err)
+			return
+		}
+	}
+
+	now := time.Now()
+	receiverID := userID.(uint)
+	transfer.ReceiverID = &receiverID
+	transfer.ReceivedAt = &now
+	if hasDiscrepancy {
+		transfer.Status = models.TransferDiscrepancy
+	} else {
+		transfer.Status = models.TransferReceived
+	}
+	if request.Note != "" {
+		transfer.Note = request.Note
+	}
+
+	if err := tx.Omit("Items").Save(transfer).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新调拨单状态失败: " + err.Error()})
+		return
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "提交事务失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, transfer)
+}
+
+// 调拨差异处理请求
+type ReconcileTransferRequest struct {
+	Items []struct {
+		ItemID     uint   `json:"item_id" binding:"required"`
+		Resolution string `json:"resolution" binding:"required"`
+		Note       string `json:"note"`
+	} `json:"items" binding:"required,min=1"`
+}
+
+// ReconcileTransfer 处理调拨收货差异
+// 短收可退回调出店铺（return_source）或核销（write_off），超收需确认调出店铺多发（confirm_source）
+func (tc *TransferController) ReconcileTransfer(c *gin.Context) {
+	id := c.Param("id")
+
+	var request ReconcileTransferRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 获取当前用户ID
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		return
+	}
+
+	// 开始事务
+	tx := tc.db.Begin()
+
+	transfer, err := lockTransfer(tx, id)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "调拨单不存在"})
+		return
+	}
+
+	if transfer.Status != models.TransferDiscrepancy {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "只有存在收货差异的调拨单需要处理差异"})
+		return
+	}
+
+	itemIndex := make(map[uint]int)
+	for i, item := range transfer.Items {
+		itemIndex[item.ID] = i
+	}
+
+	for _, r := range request.Items {
+		i, ok := itemIndex[r.ItemID]
+		if !ok {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("明细 %d 不属于该调拨单", r.ItemID)})
+			return
+		}
+		item := &transfer.Items[i]
+
+		if item.DifferenceQty == 0 || item.Resolution != "" {
+			continue
+		}
+
+		resolution := models.TransferResolution(r.Resolution)
+		switch {
+		case item.DifferenceQty < 0 && resolution == models.ResolutionReturnSource:
+			// 短收商品退回调出店铺原批次
+			destStoreID := transfer.DestStoreID
+			if err := tc.postInbound(tx, transfer.ID, item.ProductID, transfer.SourceStoreID, &destStoreID, item.ReceivedQty, -item.DifferenceQty, userID.(uint),
+				fmt.Sprintf("调拨短收退回: %s", transfer.TransferNumber)); err != nil {
+				tx.Rollback()
+				respondLedgerError(c, err)
+				return
+			}
+		case item.DifferenceQty < 0 && resolution == models.ResolutionWriteOff:
+			// 在途损耗，不再产生库存变动
+		case item.DifferenceQty > 0 && resolution == models.ResolutionConfirmSource:
+			// 调出店铺补记多发数量
+			if err := tc.postMovement(tx, transfer.SourceStoreID, item.ProductID, -item.DifferenceQty, models.TransferOut, nil, transfer.ID, userID.(uint),
+				fmt.Sprintf("调拨超收补记: %s", transfer.TransferNumber)); err != nil {
+				tx.Rollback()
+				respondLedgerError(c, err)
+				return
+			}
+		default:
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("明细 %d 的差异处理方式无效", item.ID)})
+			return
+		}
+
+		item.Resolution = resolution
+		if r.Note != "" {
+			item.Note = r.Note
+		}
+		if err := tx.Save(item).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "更新调拨明细失败: " + err.Error()})
+			return
+		}
+	}
+
+	// 所有差异处理完毕后调拨单完成
+	resolved := true
+	for _, item := range transfer.Items {
+		if item.DifferenceQty != 0 && item.Resolution == "" {
+			resolved = false
+			break
+		}
+	}
+
+	if resolved {
+		transfer.Status = models.TransferReceived
+		if err := tx.Omit("Items").Save(transfer).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "更新调拨单状态失败: " + err.Error()})
+			return
+		}
+	}
+
+	// 提交事务
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "提交事务失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, transfer)
+}
+
+// CancelTransfer 取消调拨单，已发货的调拨单不能取消
+func (tc *TransferController) CancelTransfer(c *gin.Context) {
+	id := c.Param("id")
+
+	// 锁定调拨单，避免与发货并发
+	tx := tc.db.Begin()
+	var transfer models.TransferOrder
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&transfer, id).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "调拨单不存在"})
+		return
+	}
+
+	if transfer.Status != models.TransferDraft && transfer.Status != models.TransferApproved {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "只有草稿或已审核的调拨单可以取消"})
+		return
+	}
+
+	transfer.Status = models.TransferCancelled
+	if err := tx.Save(&transfer).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "取消调拨单失败: " + err.Error()})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "提交事务失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, transfer)
+}
+
+// lockTransfer 在事务中锁定调拨单并加载明细，状态须在锁定后检查，避免并发请求重复记账
+func lockTransfer(tx *gorm.DB, id string) (*models.TransferOrder, error) {
+	var transfer models.TransferOrder
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&transfer, id).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("transfer_order_id = ?", transfer.ID).Order("id").Find(&transfer.Items).Error; err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// checkTransferItems 检查请求中的明细ID均属于该调拨单
+func checkTransferItems(transfer *models.TransferOrder, quantities map[uint]int) error {
+	owned := make(map[uint]bool, len(transfer.Items))
+	for _, item := range transfer.Items {
+		owned[item.ID] = true
+	}
+	for itemID := range quantities {
+		if !owned[itemID] {
+			return fmt.Errorf("明细 %d 不属于该调拨单", itemID)
+		}
+	}
+	return nil
+}
+
+// postMovement 通过库存台账记录调拨库存交易
+func (tc *TransferController) postMovement(tx *gorm.DB, storeID, productID uint, quantity int, transactionType models.TransactionType,
+	sourceStoreID *uint, transferID uint, operatorID uint, note string) error {
+	return tc.postBatchMovement(tx, storeID, productID, quantity, "", 0, transactionType, sourceStoreID, transferID, operatorID, note)
+}
+
+// postBatchMovement 通过库存台账记录指定批次的调拨库存交易，unitCost 为0时按当前平均成本登记
+func (tc *TransferController) postBatchMovement(tx *gorm.DB, storeID, productID uint, quantity int, batchNumber string, unitCost float64, transactionType models.TransactionType,
+	sourceStoreID *uint, transferID uint, operatorID uint, note string) error {
+	refID := transferID
+	transaction := models.InventoryTransaction{
+		TransactionType: transactionType,
+		ProductID:       productID,
+		StoreID:         storeID,
+		Quantity:        quantity,
+		UnitCost:        unitCost,
+		BatchNumber:     batchNumber,
+		SourceStoreID:   sourceStoreID,
+		ReferenceID:     &refID,
+		ReferenceType:   "transfer_order",
+		OperatorID:      operatorID,
+		Note:            note,
+	}
+
+	_, err := tc.ledger.Post(tx, &transaction)
+	return err
+}
+
+// 批次数量
+type batchQuantity struct {
+	BatchNumber string
+	Quantity    int
+}
+
+// postInbound 按发货时的批次和成本记调拨入库
+// 发货数量按批次顺序排列，跳过前skip件后依次分摊quantity件，超出发货数量的部分记入最后一个批次
+func (tc *TransferController) postInbound(tx *gorm.DB, transferID, productID, storeID uint, sourceStoreID *uint, skip, quantity int, operatorID uint, note string) error {
+	var shipped []batchQuantity
+	if err := tx.Table("inventory_batch_movements").
+		Select("inventory_batch_movements.batch_number, -SUM(inventory_batch_movements.quantity) AS quantity").
+		Joins("JOIN inventory_transactions ON inventory_transactions.id = inventory_batch_movements.transaction_id").
+		Where("inventory_transactions.reference_type = ? AND inventory_transactions.reference_id = ? AND inventory_transactions.product_id = ? AND inventory_transactions.transaction_type = ?",
+			"transfer_order", transferID, productID, models.TransferOut).
+		Group("inventory_batch_movements.batch_number").
+		Order("MIN(inventory_batch_movements.id)").
+		Scan(&shipped).Error; err != nil {
+		return fmt.Errorf("查询调拨发货批次失败: %w", err)
+	}
+
+	// 调入成本取调出时登记的加权成本
+	var unitCost float64
+	if err := tx.Model(&models.InventoryTransaction{}).
+		Select("COALESCE(SUM(quantity * unit_cost) / NULLIF(SUM(quantity), 0), 0)").
+		Where("reference_type = ? AND reference_id = ? AND product_id = ? AND transaction_type = ?",
+			"transfer_order", transferID, productID, models.TransferOut).
+		Scan(&unitCost).Error; err != nil {
+		return fmt.Errorf("查询调拨发货成本失败: %w", err)
+	}
+
+	portions := make(map[string]int)
+	var order []string
+	for _, b := range shipped {
+		available := b.Quantity
+		if skip > 0 {
+			skipped := min(skip, available)
+			skip -= skipped
+			available -= skipped
+		}
+		if qty := min(available, quantity); qty > 0 {
+			portions[b.BatchNumber] += qty
+			order = append(order, b.BatchNumber)
+			quantity -= qty
+		}
+	}
+	if quantity > 0 {
+		last := ""
+		if len(shipped) > 0 {
+			last = shipped[len(shipped)-1].BatchNumber
+		}
+		if _, ok := portions[last]; !ok {
+			order = append(order, last)
+		}
+		portions[last] += quantity
+	}
+
+	for _, batchNumber := range order {
+		if err := tc.postBatchMovement(tx, storeID, productID, portions[batchNumber], batchNumber, unitCost, models.TransferIn, sourceStoreID, transferID, operatorID, note); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,11 +3,17 @@ package main
 import (
 	"hd_psi/backend/config"
 	"hd_psi/backend/controllers"
+	"hd_psi/backend/media"
 	"hd_psi/backend/middleware"
 	"hd_psi/backend/models"
+	"hd_psi/backend/notify"
 	"hd_psi/backend/routes"
+	"hd_psi/backend/scheduler"
+	"hd_psi/backend/services"
+	"hd_psi/backend/skucode"
 
 	"log"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/driver/mysql"
@@ -22,9 +28,22 @@ func main() {
 		log.Fatal("数据库连接失败: ", err)
 	}
 
+	// 创建店铺商品库存唯一索引前合并重复的库存记录
+	if merged, err := services.MergeDuplicateInventories(db); err != nil {
+		log.Fatal("合并重复库存记录失败: ", err)
+	} else if merged > 0 {
+		log.Printf("已合并 %d 条重复库存记录", merged)
+	}
+
 	// 自动迁移数据模型
 	db.AutoMigrate(
 		&models.User{},
+		&models.SKUCode{},
+		&models.Category{},
+		&models.Style{},
+		&models.ProductImage{},
+		&models.ProductPrice{},
+		&models.ProductPriceHistory{},
 		&models.Product{},
 		&models.Inventory{},
 		&models.Supplier{},
@@ -34,12 +53,24 @@ func main() {
 		&models.PurchaseReceivingItem{},
 		&models.Store{},
 		&models.InventoryTransaction{},
+		&models.InventoryBatch{},
+		&models.InventoryBatchMovement{},
+		&models.StockReservation{},
+		&models.InventorySnapshot{},
+		&models.DamageReport{},
+		&models.TransferOrder{},
+		&models.TransferOrderItem{},
 		&models.InventoryAlert{},
 		&models.InventoryThreshold{},
 		&models.Member{},
 		&models.InventoryCheck{},
 		&models.InventoryCheckItem{},
 		&models.InventoryCheckAdjustment{},
+		&models.InventoryCheckCount{},
+		&models.InventoryRecountTask{},
+		&models.ProductClass{},
+		&models.CountSession{},
+		&models.CountScan{},
 		&models.SalesOrder{},
 		&models.SalesOrderItem{},
 		&models.NegotiationRecord{},
@@ -48,7 +79,44 @@ func main() {
 		&models.ReturnOrderItem{},
 		&models.FittingRoom{},
 		&controllers.PointsTransaction{},
+		&models.ScheduledJob{},
+		&models.JobRun{},
+		&models.NotificationSubscription{},
+		&models.NotificationOutbox{},
+		&models.NotificationDelivery{},
+		&models.Notification{},
 	)
+
+	// 补齐启用批次管理前的库存批次
+	if err := services.SeedOpeningBatches(db); err != nil {
+		log.Fatal("初始化批次库存失败: ", err)
+	}
+
+	// 初始化启用成本核算前的库存平均成本
+	if err := services.SeedAverageCosts(db); err != nil {
+		log.Fatal("初始化库存平均成本失败: ", err)
+	}
+
+	// 写入默认季节编码
+	if err := skucode.SeedSeasons(db); err != nil {
+		log.Fatal("初始化SKU编码字典失败: ", err)
+	}
+
+	// 初始化上传文件存储
+	store, err := media.NewStorageFromConfig()
+	if err != nil {
+		log.Fatal("初始化文件存储失败: ", err)
+	}
+
+	// 启动定时任务调度器（库存预留释放、库存预警、会员等级、库存快照、通知分发、图片清理等）
+	sched := scheduler.New(db)
+	if err := scheduler.RegisterDefaultJobs(sched, notify.NewDispatcherFromConfig(db), store); err != nil {
+		log.Fatal("注册定时任务失败: ", err)
+	}
+	if err := sched.Start(30 * time.Second); err != nil {
+		log.Fatal("启动定时任务调度器失败: ", err)
+	}
+
 	// 初始化Gin引擎
 	r := gin.Default()
 
@@ -56,7 +124,7 @@ func main() {
 	r.Use(middleware.CORSMiddleware())
 
 	// 注册路由
-	routes.RegisterRoutes(r, db)
+	routes.RegisterRoutes(r, db, sched, store)
 
 	// 启动服务
 	if err := r.Run(":8080"); err != nil {
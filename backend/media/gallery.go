@@ -0,0 +1,241 @@
+package media
+
+import (
+	"errors"
+	"fmt"
+	"hd_psi/backend/models"
+	"log"
+	"mime/multipart"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrOwnerNotFound 图库所属的商品或款式不存在
+	ErrOwnerNotFound = errors.New("商品或款式不存在")
+	// ErrImageNotFound 图片不存在或不属于该图库
+	ErrImageNotFound = errors.New("图片不存在")
+	// ErrInvalidOrder 排序的图片与图库中的图片不一致
+	ErrInvalidOrder = errors.New("排序须包含图库中的全部图片")
+)
+
+// Owner 图库所属的商品或款式
+type Owner struct {
+	Kind string // product 或 style
+	ID   uint
+}
+
+// ProductOwner 商品图库
+func ProductOwner(id uint) Owner { return Owner{Kind: "product", ID: id} }
+
+// StyleOwner 款式图库
+func StyleOwner(id uint) Owner { return Owner{Kind: "style", ID: id} }
+
+// column 返回图片表中的所属字段
+func (o Owner) column() string {
+	if o.Kind == "style" {
+		return "style_id"
+	}
+	return "product_id"
+}
+
+// table 返回所属对象的表名，主图保存在该表的 image 字段
+func (o Owner) table() string {
+	if o.Kind == "style" {
+		return "styles"
+	}
+	return "products"
+}
+
+// Gallery 按顺序返回图库中的图片
+func Gallery(db *gorm.DB, owner Owner) ([]models.ProductImage, error) {
+	images := []models.ProductImage{}
+	err := db.Where(owner.column()+" = ?", owner.ID).Order("sort_order, id").Find(&images).Error
+	return images, err
+}
+
+// AddImages 上传图片并追加到图库末尾，图库原本为空时第一张图片成为主图
+// 保存记录失败时删除已上传的文件
+func AddImages(db *gorm.DB, store Storage, owner Owner, files []*multipart.FileHeader, uploaderID uint) ([]models.ProductImage, error) {
+	if err := checkOwner(db, owner); err != nil {
+		return nil, err
+	}
+
+	var saved []*SavedImage
+	cleanup := func() {
+		for _, s := range saved {
+			if err := Remove(store, s.Key, s.ThumbKey); err != nil {
+				log.Printf("删除上传文件 %s 失败: %v", s.Key, err)
+			}
+		}
+	}
+	for _, file := range files {
+		s, err := SaveImage(store, file, "gallery/"+owner.Kind, fmt.Sprintf("%s%d", owner.Kind, owner.ID))
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+		saved = append(saved, s)
+	}
+
+	var images []models.ProductImage
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var maxOrder *int
+		if err := tx.Model(&models.ProductImage{}).Where(owner.column()+" = ?", owner.ID).
+			Select("MAX(sort_order)").Scan(&maxOrder).Error; err != nil {
+			return err
+		}
+		next := 0
+		if maxOrder != nil {
+			next = *maxOrder + 1
+		}
+
+		for i, s := range saved {
+			image := models.ProductImage{
+				Key:         s.Key,
+				URL:         s.URL,
+				ThumbKey:    s.ThumbKey,
+				ThumbURL:    s.ThumbURL,
+				ContentType: s.ContentType,
+				Size:        s.Size,
+				Width:       s.Width,
+				Height:      s.Height,
+				SortOrder:   next + i,
+				UploadedBy:  uploaderID,
+			}
+			id := owner.ID
+			if owner.Kind == "style" {
+				image.StyleID = &id
+			} else {
+				image.ProductID = &id
+			}
+			images = append(images, image)
+		}
+		if err := tx.Create(&images).Error; err != nil {
+			return err
+		}
+		return syncPrimary(tx, owner, "")
+	})
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf("保存图片记录失败: %w", err)
+	}
+	return images, nil
+}
+
+// ReorderImages 按 ids 顺序重新排列图库，ids 须包含图库中的全部图片，第一张成为主图
+func ReorderImages(db *gorm.DB, owner Owner, ids []uint) ([]models.ProductImage, error) {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		images, err := Gallery(tx, owner)
+		if err != nil {
+			return err
+		}
+		if len(ids) != len(images) {
+			return ErrInvalidOrder
+		}
+		position := make(map[uint]int, len(ids))
+		for i, id := range ids {
+			position[id] = i
+		}
+		for _, image := range images {
+			order, ok := position[image.ID]
+			if !ok {
+				return ErrInvalidOrder
+			}
+			if err := tx.Model(&image).Update("sort_order", order).Error; err != nil {
+				return err
+			}
+		}
+		return syncPrimary(tx, owner, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return Gallery(db, owner)
+}
+
+// DeleteImage 从图库删除图片及其文件
+func DeleteImage(db *gorm.DB, store Storage, owner Owner, imageID uint) error {
+	var image models.ProductImage
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND "+owner.column()+" = ?", imageID, owner.ID).Limit(1).Find(&image)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrImageNotFound
+		}
+		if err := tx.Delete(&image).Error; err != nil {
+			return err
+		}
+		return syncPrimary(tx, owner, image.URL)
+	})
+	if err != nil {
+		return err
+	}
+
+	// 记录已删除，文件删除失败不影响结果，只记录日志
+	if err := Remove(store, image.Key, image.ThumbKey); err != nil {
+		log.Printf("删除图片文件 %s 失败: %v", image.Key, err)
+	}
+	return nil
+}
+
+// CleanupOrphans 删除所属商品或款式已不存在的图片及其文件
+// 商品或款式删除时调用，也由定时任务定期执行以清理删除文件失败的遗留记录
+// 返回：
+//   - int: 清理的图片数量
+//   - error: 查询失败时返回错误
+func CleanupOrphans(db *gorm.DB, store Storage) (int, error) {
+	var orphans []models.ProductImage
+	if err := db.Where("(product_id IS NOT NULL AND product_id NOT IN (SELECT id FROM products)) OR " +
+		"(style_id IS NOT NULL AND style_id NOT IN (SELECT id FROM styles))").
+		Find(&orphans).Error; err != nil {
+		return 0, fmt.Errorf("查询孤立图片失败: %w", err)
+	}
+
+	cleaned := 0
+	for _, image := range orphans {
+		// 先删文件再删记录，文件删除失败时保留记录等待下次清理
+		if err := Remove(store, image.Key, image.ThumbKey); err != nil {
+			log.Printf("删除图片文件 %s 失败: %v", image.Key, err)
+			continue
+		}
+		if err := db.Delete(&image).Error; err != nil {
+			log.Printf("删除图片记录 %d 失败: %v", image.ID, err)
+			continue
+		}
+		cleaned++
+	}
+	return cleaned, nil
+}
+
+// checkOwner 检查图库所属的商品或款式存在
+func checkOwner(db *gorm.DB, owner Owner) error {
+	var count int64
+	if err := db.Table(owner.table()).Where("id = ?", owner.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrOwnerNotFound
+	}
+	return nil
+}
+
+// syncPrimary 以图库第一张图片作为主图
+// 图库为空时，只有主图是被删除的图片（removedURL）才清空，保留启用图库前单独填写的图片地址
+func syncPrimary(tx *gorm.DB, owner Owner, removedURL string) error {
+	var first models.ProductImage
+	result := tx.Where(owner.column()+" = ?", owner.ID).Order("sort_order, id").Limit(1).Find(&first)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		return tx.Table(owner.table()).Where("id = ?", owner.ID).Update("image", first.URL).Error
+	}
+	if removedURL != "" {
+		return tx.Table(owner.table()).Where("id = ? AND image = ?", owner.ID, removedURL).Update("image", "").Error
+	}
+	return nil
+}
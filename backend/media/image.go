@@ -0,0 +1,147 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png" // 注册PNG解码
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MaxImageSize 上传图片大小上限 - 5MB
+const MaxImageSize = 5 << 20
+
+// MaxImagePixels 上传图片像素数上限，解码前按图片头校验，防止小文件解码出超大图片
+const MaxImagePixels = 40_000_000
+
+// ThumbnailSize 缩略图最长边像素
+const ThumbnailSize = 320
+
+var (
+	// ErrInvalidImage 上传的文件不是支持的图片格式
+	ErrInvalidImage = errors.New("只支持 jpg、png、webp 格式的图片")
+	// ErrImageTooLarge 上传的图片超过大小上限
+	ErrImageTooLarge = errors.New("图片大小不能超过5MB")
+	// ErrImageDimensions 上传的图片像素数超过上限
+	ErrImageDimensions = errors.New("图片尺寸过大")
+)
+
+// imageTypes 支持的图片扩展名及内容类型
+var imageTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".webp": "image/webp",
+}
+
+// SavedImage 已保存的图片及缩略图
+type SavedImage struct {
+	Key         string
+	URL         string
+	ThumbKey    string // 无法生成缩略图（如webp）时为空
+	ThumbURL    string // 无法生成缩略图时为原图地址
+	ContentType string
+	Size        int64
+	Width       int
+	Height      int
+}
+
+// SaveImage 校验并保存上传的图片，jpg、png 同时生成 jpg 缩略图
+// 文件保存为 dir/prefix_时间戳.扩展名，缩略图保存在 dir/thumbs 下
+func SaveImage(store Storage, file *multipart.FileHeader, dir, prefix string) (*SavedImage, error) {
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	contentType, ok := imageTypes[ext]
+	if !ok {
+		return nil, ErrInvalidImage
+	}
+	if file.Size > MaxImageSize {
+		return nil, ErrImageTooLarge
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("读取上传文件失败: %w", err)
+	}
+	defer src.Close()
+	data, err := io.ReadAll(io.LimitReader(src, MaxImageSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取上传文件失败: %w", err)
+	}
+	if len(data) > MaxImageSize {
+		return nil, ErrImageTooLarge
+	}
+	// 以文件内容校验格式，防止修改扩展名上传其他文件
+	if http.DetectContentType(data) != contentType {
+		return nil, ErrInvalidImage
+	}
+
+	name := fmt.Sprintf("%s_%d", prefix, time.Now().UnixNano())
+	saved := &SavedImage{
+		Key:         dir + "/" + name + ext,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+	}
+
+	var thumb []byte
+	if contentType != "image/webp" {
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrInvalidImage
+		}
+		if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > MaxImagePixels {
+			return nil, fmt.Errorf("%w: %dx%d", ErrImageDimensions, cfg.Width, cfg.Height)
+		}
+
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrInvalidImage
+		}
+		saved.Width, saved.Height = img.Bounds().Dx(), img.Bounds().Dy()
+
+		// JPEG不支持透明，透明区域以白色填充
+		small := Thumbnail(img, ThumbnailSize)
+		canvas := image.NewRGBA(small.Bounds())
+		draw.Draw(canvas, canvas.Bounds(), image.White, image.Point{}, draw.Src)
+		draw.Draw(canvas, canvas.Bounds(), small, small.Bounds().Min, draw.Over)
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, canvas, &jpeg.Options{Quality: 80}); err != nil {
+			return nil, fmt.Errorf("生成缩略图失败: %w", err)
+		}
+		thumb = buf.Bytes()
+	}
+
+	if err := store.Put(saved.Key, data, contentType); err != nil {
+		return nil, err
+	}
+	saved.URL = store.URL(saved.Key)
+	saved.ThumbURL = saved.URL
+
+	if thumb != nil {
+		saved.ThumbKey = dir + "/thumbs/" + name + ".jpg"
+		if err := store.Put(saved.ThumbKey, thumb, "image/jpeg"); err != nil {
+			store.Delete(saved.Key)
+			return nil, err
+		}
+		saved.ThumbURL = store.URL(saved.ThumbKey)
+	}
+	return saved, nil
+}
+
+// Remove 删除图片及其缩略图
+func Remove(store Storage, key, thumbKey string) error {
+	if thumbKey != "" {
+		if err := store.Delete(thumbKey); err != nil {
+			return err
+		}
+	}
+	return store.Delete(key)
+}
@@ -0,0 +1,105 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http/httptest"
+	"testing"
+)
+
+// memStorage 内存存储
+type memStorage map[string][]byte
+
+func (m memStorage) Put(key string, data []byte, contentType string) error {
+	m[key] = data
+	return nil
+}
+
+func (m memStorage) Get(key string) ([]byte, string, error) {
+	data, ok := m[key]
+	if !ok {
+		return nil, "", ErrFileNotFound
+	}
+	return data, "", nil
+}
+
+func (m memStorage) Delete(key string) error {
+	delete(m, key)
+	return nil
+}
+
+func (m memStorage) URL(key string) string { return "/uploads/" + key }
+
+// uploadFile 构造上传文件
+func uploadFile(t *testing.T, name string, data []byte) *multipart.FileHeader {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, _ := w.CreateFormFile("file", name)
+	part.Write(data)
+	w.Close()
+
+	req := httptest.NewRequest("POST", "/", &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	if err := req.ParseMultipartForm(MaxImageSize); err != nil {
+		t.Fatalf("ParseMultipartForm: %v", err)
+	}
+	return req.MultipartForm.File["file"][0]
+}
+
+// encodePNG 生成PNG图片，width、height 不为0时改写图片头中的尺寸
+func encodePNG(t *testing.T, width, height uint32) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 3))); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if width != 0 {
+		// IHDR 数据从第16字节开始，前8字节为宽和高，之后4字节为 CRC
+		binary.BigEndian.PutUint32(data[16:], width)
+		binary.BigEndian.PutUint32(data[20:], height)
+		binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	}
+	return data
+}
+
+func TestSaveImage(t *testing.T) {
+	store := memStorage{}
+	saved, err := SaveImage(store, uploadFile(t, "a.PNG", encodePNG(t, 0, 0)), "products/1", "p")
+	if err != nil {
+		t.Fatalf("SaveImage: %v", err)
+	}
+	if saved.Width != 4 || saved.Height != 3 || saved.ContentType != "image/png" {
+		t.Errorf("saved = %+v", saved)
+	}
+	if _, ok := store[saved.Key]; !ok {
+		t.Error("image not stored")
+	}
+	if _, ok := store[saved.ThumbKey]; !ok || saved.ThumbURL != "/uploads/"+saved.ThumbKey {
+		t.Errorf("thumbnail not stored: %+v", saved)
+	}
+}
+
+func TestSaveImageRejectsHugeDimensions(t *testing.T) {
+	store := memStorage{}
+	_, err := SaveImage(store, uploadFile(t, "bomb.png", encodePNG(t, 100000, 100000)), "products/1", "p")
+	if !errors.Is(err, ErrImageDimensions) {
+		t.Fatalf("err = %v, want ErrImageDimensions", err)
+	}
+	if len(store) != 0 {
+		t.Errorf("stored %d files", len(store))
+	}
+}
+
+func TestSaveImageRejectsMismatchedContent(t *testing.T) {
+	_, err := SaveImage(memStorage{}, uploadFile(t, "a.jpg", encodePNG(t, 0, 0)), "products/1", "p")
+	if !errors.Is(err, ErrInvalidImage) {
+		t.Fatalf("err = %v, want ErrInvalidImage", err)
+	}
+}
@@ -0,0 +1,65 @@
+package media
+
+import (
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+)
+
+// LocalStorage 本地磁盘存储，文件通过静态文件路由访问
+type LocalStorage struct {
+	dir     string // 文件保存根目录
+	baseURL string // 访问地址前缀，如 /uploads
+}
+
+// NewLocalStorage 创建本地磁盘存储
+func NewLocalStorage(dir, baseURL string) *LocalStorage {
+	return &LocalStorage{dir: dir, baseURL: baseURL}
+}
+
+// Put 保存文件到本地磁盘
+func (s *LocalStorage) Put(key string, data []byte, contentType string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建上传目录失败: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("保存上传文件失败: %w", err)
+	}
+	return nil
+}
+
+// Get 读取本地文件，内容类型按扩展名判断
+func (s *LocalStorage) Get(key string) ([]byte, string, error) {
+	if err := validKey(key); err != nil {
+		return nil, "", err
+	}
+	data, err := os.ReadFile(filepath.Join(s.dir, filepath.FromSlash(key)))
+	if os.IsNotExist(err) {
+		return nil, "", ErrFileNotFound
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("读取文件失败: %w", err)
+	}
+	return data, mime.TypeByExtension(filepath.Ext(key)), nil
+}
+
+// Delete 删除本地文件
+func (s *LocalStorage) Delete(key string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(s.dir, filepath.FromSlash(key))); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("删除文件失败: %w", err)
+	}
+	return nil
+}
+
+// URL 返回静态文件访问路径
+func (s *LocalStorage) URL(key string) string {
+	return s.baseURL + "/" + key
+}
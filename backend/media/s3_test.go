@@ -0,0 +1,192 @@
+package media
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	testAccessKey = "AKIDEXAMPLE"
+	testSecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	testRegion    = "us-east-1"
+	testBucket    = "media"
+)
+
+// fakeS3 内存中的S3服务，校验 SigV4 签名和载荷哈希
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]fakeObject
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if code, msg := f.verify(r, body); code != 0 {
+		http.Error(w, msg, code)
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/"+testBucket+"/")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[key] = fakeObject{data: body, contentType: r.Header.Get("Content-Type")}
+	case http.MethodGet:
+		obj, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", obj.contentType)
+		w.Write(obj.data)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// verify 按请求中声明的签名请求头重新计算签名
+func (f *fakeS3) verify(r *http.Request, body []byte) (int, string) {
+	sum := sha256.Sum256(body)
+	if r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+		return http.StatusBadRequest, "XAmzContentSHA256Mismatch"
+	}
+
+	auth := strings.TrimPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ")
+	fields := map[string]string{}
+	for _, part := range strings.Split(auth, ", ") {
+		if k, v, ok := strings.Cut(part, "="); ok {
+			fields[k] = v
+		}
+	}
+	amzDate := r.Header.Get("X-Amz-Date")
+	if len(amzDate) != 16 {
+		return http.StatusForbidden, "missing X-Amz-Date"
+	}
+	scope := amzDate[:8] + "/" + testRegion + "/s3/aws4_request"
+	if fields["Credential"] != testAccessKey+"/"+scope {
+		return http.StatusForbidden, "InvalidAccessKeyId"
+	}
+
+	signed := strings.Split(fields["SignedHeaders"], ";")
+	if !sort.StringsAreSorted(signed) {
+		return http.StatusForbidden, "SignedHeaders not sorted"
+	}
+	var headers strings.Builder
+	for _, name := range signed {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		headers.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	for _, required := range []string{"host", "x-amz-content-sha256", "x-amz-date"} {
+		if !strings.Contains(";"+fields["SignedHeaders"]+";", ";"+required+";") {
+			return http.StatusForbidden, required + " not signed"
+		}
+	}
+
+	canonical := strings.Join([]string{r.Method, r.URL.EscapedPath(), r.URL.RawQuery,
+		headers.String(), fields["SignedHeaders"], r.Header.Get("X-Amz-Content-Sha256")}, "\n")
+	hash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := []byte("AWS4" + testSecretKey)
+	for _, part := range []string{amzDate[:8], testRegion, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	if !hmac.Equal([]byte(hex.EncodeToString(hmacSHA256(key, toSign))), []byte(fields["Signature"])) {
+		return http.StatusForbidden, "SignatureDoesNotMatch"
+	}
+	return 0, ""
+}
+
+func newFakeS3(t *testing.T, secretKey string) (*S3Storage, *fakeS3) {
+	t.Helper()
+	fake := &fakeS3{objects: map[string]fakeObject{}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	store, err := NewS3Storage(srv.URL, testRegion, testBucket, testAccessKey, secretKey, "")
+	if err != nil {
+		t.Fatalf("NewS3Storage: %v", err)
+	}
+	return store, fake
+}
+
+func TestS3StoragePutGetDelete(t *testing.T) {
+	store, fake := newFakeS3(t, testSecretKey)
+	key := "products/1/图片 1.png"
+
+	if err := store.Put(key, []byte("png-data"), "image/png"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if obj := fake.objects[key]; string(obj.data) != "png-data" || obj.contentType != "image/png" {
+		t.Fatalf("stored object = %+v", obj)
+	}
+
+	data, contentType, err := store.Get(key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if string(data) != "png-data" || contentType != "image/png" {
+		t.Errorf("Get = %q %q", data, contentType)
+	}
+
+	if err := store.Delete(key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, _, err := store.Get(key); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("Get after delete err = %v, want ErrFileNotFound", err)
+	}
+	// 删除不存在的对象不报错
+	if err := store.Delete(key); err != nil {
+		t.Errorf("Delete missing: %v", err)
+	}
+}
+
+func TestS3StorageBadCredentials(t *testing.T) {
+	store, _ := newFakeS3(t, "wrong-secret")
+	err := store.Put("a.png", []byte("x"), "image/png")
+	if err == nil || !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Fatalf("err = %v, want 403 SignatureDoesNotMatch", err)
+	}
+	if _, _, err := store.Get("a.png"); err == nil || errors.Is(err, ErrFileNotFound) {
+		t.Fatalf("Get err = %v, want signature error", err)
+	}
+}
+
+func TestS3StorageRejectsInvalidKey(t *testing.T) {
+	store, _ := newFakeS3(t, testSecretKey)
+	for _, key := range []string{"", "/abs", "../x", "a//b"} {
+		if err := store.Put(key, nil, ""); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q) err = %v, want ErrInvalidKey", key, err)
+		}
+	}
+}
+
+func TestS3StorageURL(t *testing.T) {
+	store, err := NewS3Storage("https://s3.example.com/", testRegion, testBucket, testAccessKey, testSecretKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := store.URL("a/b.png"); got != "https://s3.example.com/media/a/b.png" {
+		t.Errorf("URL = %q", got)
+	}
+}
@@ -0,0 +1,53 @@
+// Package media 管理上传的图片：存储驱动（本地磁盘、S3兼容对象存储）、上传校验、缩略图和商品图库
+package media
+
+import (
+	"errors"
+	"fmt"
+	"hd_psi/backend/config"
+	"strings"
+)
+
+var (
+	// ErrInvalidKey 文件路径无效
+	ErrInvalidKey = errors.New("文件路径无效")
+	// ErrFileNotFound 文件不存在
+	ErrFileNotFound = errors.New("文件不存在")
+)
+
+// Storage 文件存储驱动
+type Storage interface {
+	// Put 保存文件，key 为以 / 分隔的相对路径，已存在时覆盖
+	Put(key string, data []byte, contentType string) error
+	// Get 读取文件内容及内容类型，文件不存在时返回 ErrFileNotFound
+	Get(key string) ([]byte, string, error)
+	// Delete 删除文件，文件不存在时不返回错误
+	Delete(key string) error
+	// URL 返回文件的访问地址
+	URL(key string) string
+}
+
+// NewStorageFromConfig 按配置创建存储驱动，默认使用本地磁盘
+func NewStorageFromConfig() (Storage, error) {
+	cfg := config.GetStorageConfig()
+	switch cfg.Driver {
+	case "local":
+		return NewLocalStorage(config.GetUploadDir(), "/uploads"), nil
+	case "s3":
+		return NewS3Storage(cfg.S3Endpoint, cfg.S3Region, cfg.S3Bucket, cfg.S3AccessKey, cfg.S3SecretKey, cfg.S3PublicURL)
+	}
+	return nil, fmt.Errorf("不支持的存储驱动: %s", cfg.Driver)
+}
+
+// validKey 校验文件路径，不允许绝对路径和上级目录
+func validKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+		}
+	}
+	return nil
+}
@@ -47,6 +47,22 @@ func JWTAuth() gin.HandlerFunc {
 	}
 }
 
+// JWTAuthWithQuery 创建JWT认证中间件，请求头没有令牌时从查询参数 token 读取
+// 仅用于 <img src> 等无法设置请求头的文件访问
+// 返回：
+//   - gin.HandlerFunc: Gin中间件函数
+func JWTAuthWithQuery() gin.HandlerFunc {
+	auth := JWTAuth()
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			if token := c.Query("token"); token != "" {
+				c.Request.Header.Set("Authorization", "Bearer "+token)
+			}
+		}
+		auth(c)
+	}
+}
+
 // RoleAuth 创建基于角色的权限控制中间件
 // 用于验证用户是否具有指定的角色权限
 // 参数：
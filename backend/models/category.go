@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Category 商品类别树节点，如 女装 > 外套 > 羽绒服
+// Path 为从根节点到本节点的ID路径（如 /1/4/9/），用于查询子树
+// 同一上级类别下名称唯一（MySQL唯一索引不约束空值，根类别的重名由保存前的检查保证）
+type Category struct {
+	ID        uint   `gorm:"primaryKey"`
+	ParentID  *uint  `gorm:"uniqueIndex:idx_category_sibling"` // 为空表示根节点
+	Name      string `gorm:"size:50;not null;uniqueIndex:idx_category_sibling"`
+	Path      string `gorm:"size:255;index;not null"`
+	Depth     int    `gorm:"not null"` // 根节点为1
+	SortOrder int    `gorm:"default:0"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
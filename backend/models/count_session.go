@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// CountSessionStatus 扫码盘点会话状态
+type CountSessionStatus string
+
+const (
+	SessionOpen   CountSessionStatus = "open"   // 扫码中
+	SessionClosed CountSessionStatus = "closed" // 已结束，扫码结果已计入盘点明细
+)
+
+// ScanResult 扫码结果
+type ScanResult string
+
+const (
+	ScanAccepted     ScanResult = "accepted"      // 已计数
+	ScanInvalid      ScanResult = "invalid"       // 二维码无效或校验失败
+	ScanUnknown      ScanResult = "unknown"       // 二维码中的SKU不存在
+	ScanNotInCheck   ScanResult = "not_in_check"  // 商品不在本次盘点范围内
+	ScanForeignStore ScanResult = "foreign_store" // 批次属于其他店铺
+)
+
+// CountSession 扫码盘点会话，一个计数人在一次盘点中的连续扫码
+type CountSession struct {
+	ID        uint               `gorm:"primaryKey"`
+	CheckID   uint               `gorm:"not null;index"` // 盘点单ID
+	CounterID uint               `gorm:"not null"`       // 计数人ID
+	Status    CountSessionStatus `gorm:"size:20;not null;default:'open'"`
+	ClosedAt  *time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// CountScan 扫码记录
+type CountScan struct {
+	ID          uint       `gorm:"primaryKey"`
+	SessionID   uint       `gorm:"not null;index"`
+	CheckID     uint       `gorm:"not null"`
+	Payload     string     `gorm:"size:512"` // 原始二维码数据
+	SKU         string     `gorm:"size:255"`
+	BatchNumber string     `gorm:"size:50"`
+	ProductID   *uint      // 识别出的商品ID
+	CheckItemID *uint      // 计入的盘点明细ID
+	Result      ScanResult `gorm:"size:20;not null"`
+	Undone      bool       `gorm:"default:false"` // 已撤销
+	CreatedAt   time.Time
+}
@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// DamageReason 报损原因代码
+type DamageReason string
+
+const (
+	DamageBroken    DamageReason = "broken"    // 破损
+	DamageStained   DamageReason = "stained"   // 污渍
+	DamageDefective DamageReason = "defective" // 质量瑕疵
+	DamageLost      DamageReason = "lost"      // 丢失或盗损
+	DamageOther     DamageReason = "other"     // 其他
+)
+
+// Valid 是否为有效的报损原因代码
+func (r DamageReason) Valid() bool {
+	switch r {
+	case DamageBroken, DamageStained, DamageDefective, DamageLost, DamageOther:
+		return true
+	}
+	return false
+}
+
+// DamageStatus 报损单状态
+type DamageStatus string
+
+const (
+	DamagePending  DamageStatus = "pending"  // 待审批
+	DamageApproved DamageStatus = "approved" // 已审批出库
+	DamageRejected DamageStatus = "rejected" // 已驳回
+)
+
+// DamageReport 商品报损单
+type DamageReport struct {
+	ID            uint         `gorm:"primaryKey"`
+	ReportNumber  string       `gorm:"size:50;uniqueIndex"`                // 报损单号
+	StoreID       uint         `gorm:"not null;index"`                     // 店铺ID
+	ProductID     uint         `gorm:"not null"`                           // 商品ID
+	Quantity      int          `gorm:"not null"`                           // 报损数量
+	BatchNumber   string       `gorm:"size:50"`                            // 批次号
+	ReasonCode    DamageReason `gorm:"size:20;not null"`                   // 报损原因代码
+	Description   string       `gorm:"size:255"`                           // 报损说明
+	PhotoURL      string       `gorm:"size:255"`                           // 照片地址
+	Status        DamageStatus `gorm:"size:20;not null;default:'pending'"` // 状态
+	ReporterID    uint         `gorm:"not null"`                           // 报损人ID
+	ApproverID    *uint        // 审批人ID
+	ApprovalTime  *time.Time   // 审批时间
+	ApprovalNote  string       `gorm:"size:255"` // 审批备注
+	UnitCost      float64      // 报损单位成本，审批出库时按平均成本登记
+	TotalCost     float64      // 报损金额
+	TransactionID *uint        // 报损出库交易ID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+
+	// 关联
+	Product Product `gorm:"foreignKey:ProductID"`
+	Store   Store   `gorm:"foreignKey:StoreID"`
+}
@@ -2,7 +2,11 @@ package models
 
 type Inventory struct {
 	ID        uint `gorm:"primaryKey"`
-	StoreID   uint
-	ProductID uint
+	StoreID   uint `gorm:"uniqueIndex:idx_inventory_store_product"`
+	ProductID uint `gorm:"uniqueIndex:idx_inventory_store_product"`
 	Quantity  int
+	Reserved  int     `gorm:"default:0"` // 线上订单预留数量，可售数量 = Quantity - Reserved
+	AvgCost   float64 `gorm:"default:0"` // 移动加权平均单位成本
+
+	QuarantineQty int `gorm:"default:0"` // 隔离库存数量（质检不合格），不可销售，不计入 Quantity
 }
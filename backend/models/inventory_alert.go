@@ -24,7 +24,7 @@ type InventoryAlert struct {
 	ID          uint        `gorm:"primaryKey"`
 	StoreID     uint        `gorm:"not null"`
 	ProductID   uint        `gorm:"not null"`
-	Category    string      `gorm:"size:50"` // 商品类别
+	CategoryID  *uint       `gorm:"index"` // 商品类别
 	AlertType   AlertType   `gorm:"size:20;not null"`
 	Threshold   int         `gorm:"not null"` // 预警阈值
 	CurrentQty  int         `gorm:"not null"` // 当前库存
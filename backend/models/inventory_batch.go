@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// InventoryBatch 批次库存余额
+// 同一店铺同一商品的所有批次数量之和等于 Inventory.Quantity
+type InventoryBatch struct {
+	ID          uint      `gorm:"primaryKey"`
+	StoreID     uint      `gorm:"not null;uniqueIndex:idx_batch_store_product_number"`
+	ProductID   uint      `gorm:"not null;uniqueIndex:idx_batch_store_product_number"`
+	BatchNumber string    `gorm:"size:50;uniqueIndex:idx_batch_store_product_number"` // 批次号，空字符串表示未登记批次
+	Quantity    int       `gorm:"not null"`                                           // 批次当前数量
+	ReceivingID *uint     // 首次入库的采购入库单ID，用于追溯供应商
+	ReceivedAt  time.Time // 批次首次入库时间，先进先出的依据
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// InventoryBatchMovement 库存交易在各批次上的分摊明细
+type InventoryBatchMovement struct {
+	ID            uint   `gorm:"primaryKey"`
+	TransactionID uint   `gorm:"not null;index"` // 库存交易ID
+	BatchID       uint   `gorm:"not null;index"` // 批次库存ID
+	BatchNumber   string `gorm:"size:50"`        // 批次号
+	Quantity      int    `gorm:"not null"`       // 分摊数量，正数入库，负数出库
+	CreatedAt     time.Time
+}
@@ -31,6 +31,11 @@ type InventoryCheck struct {
 	StartTime   *time.Time  // 实际开始时间
 	EndTime     *time.Time  // 实际结束时间
 	OperatorID  uint        // 操作人ID
+	BlindCount  bool        `gorm:"default:false"` // 盲盘：盘点中不显示系统库存，每个商品由两人独立计数
+	FreezeStock bool        `gorm:"default:false"` // 全盘期间冻结店铺的调拨和采购入库（不影响销售）
+	CutoffAt    *time.Time  // 盘点截止时间，开始盘点时记录系统库存快照
+	CutoffTransactionID uint // 截止时最后一条库存交易ID，之后的交易计入截止后变动
+	CyclePeriod string      `gorm:"size:7;index"` // 循环盘点月份（YYYY-MM），由循环盘点计划生成时填写
 	Description string      `gorm:"size:255"` // 盘点说明
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
@@ -42,14 +47,67 @@ type InventoryCheckItem struct {
 	CheckID         uint    `gorm:"not null"` // 关联盘点主表ID
 	ProductID       uint    `gorm:"not null"` // 商品ID
 	SystemQuantity  int     `gorm:"not null"` // 系统库存数量
+	QuarantineQty   int     // 隔离库存数量，不计入盘点数量
 	ActualQuantity  int     // 实际盘点数量
-	DifferenceQty   int     // 差异数量（实际-系统）
+	MovementQty     int     // 截止后到计数时的库存变动数量
+	DifferenceQty   int     // 差异数量（实际-系统-截止后变动）
 	Status          string  `gorm:"size:20;default:'pending'"` // 状态：待盘点/已盘点
 	Note            string  `gorm:"size:255"` // 备注
 	CreatedAt       time.Time
 	UpdatedAt       time.Time
 }
 
+// 盘点明细状态
+const (
+	CheckItemPending  = "pending"  // 待盘点
+	CheckItemCounting = "counting" // 盲盘已有一人计数
+	CheckItemRecount  = "recount"  // 盲盘两人计数不一致，待复盘
+	CheckItemChecked  = "checked"  // 已盘点
+)
+
+// InventoryCheckCount 盲盘计数记录，每个商品由两人独立计数，不一致时再复盘一次
+type InventoryCheckCount struct {
+	ID          uint   `gorm:"primaryKey"`
+	CheckID     uint   `gorm:"not null;index"` // 关联盘点主表ID
+	CheckItemID uint   `gorm:"not null;index"` // 关联盘点明细ID
+	Round       int    `gorm:"not null"`       // 计数轮次：1、2为独立计数，3为复盘
+	CounterID   uint   `gorm:"not null"`       // 计数人ID
+	Quantity    int    `gorm:"not null"`       // 计数数量
+	Note        string `gorm:"size:255"`       // 备注
+	CreatedAt   time.Time
+}
+
+// RecountStatus 复盘任务状态
+type RecountStatus string
+
+const (
+	RecountPending   RecountStatus = "pending"   // 待复盘
+	RecountCompleted RecountStatus = "completed" // 已复盘
+)
+
+// InventoryRecountTask 复盘任务，两人计数不一致时创建
+type InventoryRecountTask struct {
+	ID          uint          `gorm:"primaryKey"`
+	CheckID     uint          `gorm:"not null;index"` // 关联盘点主表ID
+	CheckItemID uint          `gorm:"not null"`       // 关联盘点明细ID
+	ProductID   uint          `gorm:"not null"`       // 商品ID
+	FirstCount  int           // 第一人计数
+	SecondCount int           // 第二人计数
+	Status      RecountStatus `gorm:"size:20;not null;default:'pending'"`
+	RecountQty  *int          // 复盘数量，作为最终实盘数量
+	CounterID   *uint         // 复盘人ID
+	CompletedAt *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// 盘点调整审批状态
+const (
+	AdjustmentPending  = "pending"  // 待审批
+	AdjustmentApproved = "approved" // 已审批
+	AdjustmentRejected = "rejected" // 已拒绝
+)
+
 // InventoryCheckAdjustment 库存盘点调整记录
 type InventoryCheckAdjustment struct {
 	ID              uint      `gorm:"primaryKey"`
@@ -62,6 +120,7 @@ type InventoryCheckAdjustment struct {
 	ApprovalStatus  string    `gorm:"size:20;default:'pending'"` // 审批状态：待审批/已审批/已拒绝
 	ApprovalTime    *time.Time // 审批时间
 	ApprovalNote    string    `gorm:"size:255"` // 审批备注
+	AutoApproved    bool      `gorm:"default:false"` // 差异在阈值内自动审批
 	CreatedAt       time.Time
 	UpdatedAt       time.Time
 }
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// InventorySnapshot 每日库存快照，记录快照日期当天结束时的库存数量
+// 由库存交易回放生成，数量为0的店铺商品不保存
+type InventorySnapshot struct {
+	ID           uint      `gorm:"primaryKey"`
+	SnapshotDate time.Time `gorm:"type:date;not null;uniqueIndex:idx_snapshot_date_store_product"` // 快照日期
+	StoreID      uint      `gorm:"not null;uniqueIndex:idx_snapshot_date_store_product"`
+	ProductID    uint      `gorm:"not null;uniqueIndex:idx_snapshot_date_store_product"`
+	Quantity     int       `gorm:"not null"` // 当天结束时的库存数量
+	CreatedAt    time.Time
+}
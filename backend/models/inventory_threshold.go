@@ -4,11 +4,13 @@ import "time"
 
 // InventoryThreshold 库存阈值设置
 type InventoryThreshold struct {
-	ID        uint   `gorm:"primaryKey"`
-	StoreID   uint   `gorm:"default:0"` // 0表示适用于所有店铺
-	Category  string `gorm:"size:50;default:''"` // 空字符串表示适用于所有类别
-	LowLevel  int    `gorm:"not null"` // 低库存阈值
-	HighLevel int    `gorm:"not null"` // 高库存阈值
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID         uint   `gorm:"primaryKey"`
+	StoreID    uint   `gorm:"default:0"`          // 0表示适用于所有店铺
+	ProductID  uint   `gorm:"default:0"`          // 0表示适用于所有商品，指定商品时优先于类别设置
+	CategoryID uint   `gorm:"default:0"`          // 0表示适用于所有类别，指定类别时同样适用于其子类别
+	Size       string `gorm:"size:20;default:''"` // 空字符串表示适用于所有尺码
+	LowLevel   int    `gorm:"not null"`           // 低库存阈值
+	HighLevel  int    `gorm:"not null"`           // 高库存阈值
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
 }
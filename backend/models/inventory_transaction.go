@@ -7,15 +7,31 @@ type TransactionType string
 
 const (
 	// 入库类型
-	PurchaseIn     TransactionType = "purchase_in"     // 采购入库
-	ReturnIn       TransactionType = "return_in"       // 退货入库
-	TransferIn     TransactionType = "transfer_in"     // 调拨入库
-	
+	PurchaseIn TransactionType = "purchase_in" // 采购入库
+	ReturnIn   TransactionType = "return_in"   // 退货入库
+	TransferIn TransactionType = "transfer_in" // 调拨入库
+
 	// 出库类型
-	SaleOut        TransactionType = "sale_out"        // 销售出库
-	ExchangeOut    TransactionType = "exchange_out"    // 换货出库
-	DamageOut      TransactionType = "damage_out"      // 报损出库
-	TransferOut    TransactionType = "transfer_out"    // 调拨出库
+	SaleOut     TransactionType = "sale_out"     // 销售出库
+	ExchangeOut TransactionType = "exchange_out" // 换货出库
+	DamageOut   TransactionType = "damage_out"   // 报损出库
+	TransferOut TransactionType = "transfer_out" // 调拨出库
+
+	// 调整类型
+	CheckAdjust      TransactionType = "inventory_check"   // 盘点调整
+	LedgerCorrection TransactionType = "ledger_correction" // 台账修正（只补记交易，不变更余额）
+
+	// 隔离库存类型
+	QuarantineRelease TransactionType = "quarantine_release" // 隔离库存放行转为可售库存
+	SupplierReturn    TransactionType = "supplier_return"    // 隔离库存退回供应商
+)
+
+// StockBucket 库存分区
+type StockBucket string
+
+const (
+	BucketSellable   StockBucket = ""           // 可售库存
+	BucketQuarantine StockBucket = "quarantine" // 隔离库存
 )
 
 // InventoryTransaction 库存交易记录
@@ -24,11 +40,13 @@ type InventoryTransaction struct {
 	TransactionType TransactionType `gorm:"size:20;not null"`
 	ProductID       uint            `gorm:"not null"`
 	StoreID         uint            `gorm:"not null"`
-	Quantity        int             `gorm:"not null"` // 正数表示入库，负数表示出库
-	BatchNumber     string          `gorm:"size:50"`  // 批次号
+	Quantity        int             `gorm:"not null"`           // 正数表示入库，负数表示出库
+	Bucket          StockBucket     `gorm:"size:20;default:''"` // 库存分区，空表示可售库存
+	UnitCost        float64         `gorm:"default:0"`          // 单位成本，出库按移动加权平均成本登记
+	BatchNumber     string          `gorm:"size:50"`            // 批次号
 	SourceStoreID   *uint           // 调拨来源店铺ID，仅调拨入库时有值
 	ReferenceID     *uint           // 关联单据ID（采购单/销售单/调拨单）
-	ReferenceType   string          `gorm:"size:20"`  // 关联单据类型
+	ReferenceType   string          `gorm:"size:20"` // 关联单据类型
 	OperatorID      uint            // 操作人ID
 	Note            string          `gorm:"size:255"` // 备注
 	CreatedAt       time.Time
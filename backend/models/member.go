@@ -69,3 +69,19 @@ type Member struct {
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
+
+// CalculateLevel 根据累计消费金额计算会员等级
+func (m *Member) CalculateLevel() MemberLevel {
+	switch {
+	case m.TotalSpent >= 50000:
+		return Diamond
+	case m.TotalSpent >= 20000:
+		return Platinum
+	case m.TotalSpent >= 10000:
+		return Gold
+	case m.TotalSpent >= 5000:
+		return Silver
+	default:
+		return Regular
+	}
+}
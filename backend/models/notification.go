@@ -0,0 +1,102 @@
+package models
+
+import "time"
+
+// NotificationEvent 通知事件类型
+type NotificationEvent string
+
+const (
+	EventInventoryAlert         NotificationEvent = "inventory_alert"          // 新的库存预警
+	EventCheckAdjustmentPending NotificationEvent = "check_adjustment_pending" // 盘点调整待审批
+	EventPurchaseOrderPending   NotificationEvent = "purchase_order_pending"   // 采购单待审核
+	EventDailySalesReport       NotificationEvent = "daily_sales_report"       // 店铺每日销售日报
+)
+
+// Valid 判断事件类型是否有效
+func (e NotificationEvent) Valid() bool {
+	switch e {
+	case EventInventoryAlert, EventCheckAdjustmentPending, EventPurchaseOrderPending, EventDailySalesReport:
+		return true
+	}
+	return false
+}
+
+// NotificationChannel 通知渠道
+type NotificationChannel string
+
+const (
+	ChannelInbox   NotificationChannel = "inbox"   // 站内信
+	ChannelEmail   NotificationChannel = "email"   // 邮件
+	ChannelWebhook NotificationChannel = "webhook" // Webhook回调
+	ChannelSMS     NotificationChannel = "sms"     // 短信
+)
+
+// Valid 判断通知渠道是否有效
+func (c NotificationChannel) Valid() bool {
+	switch c {
+	case ChannelInbox, ChannelEmail, ChannelWebhook, ChannelSMS:
+		return true
+	}
+	return false
+}
+
+// DeliveryStatus 通知发送状态
+type DeliveryStatus string
+
+const (
+	DeliveryPending DeliveryStatus = "pending" // 待发送
+	DeliverySent    DeliveryStatus = "sent"    // 已发送
+	DeliveryFailed  DeliveryStatus = "failed"  // 发送失败
+)
+
+// NotificationSubscription 用户按事件和渠道订阅通知
+type NotificationSubscription struct {
+	ID        uint                `gorm:"primaryKey"`
+	UserID    uint                `gorm:"not null;uniqueIndex:idx_subscription"`
+	Event     NotificationEvent   `gorm:"size:50;not null;uniqueIndex:idx_subscription"`
+	Channel   NotificationChannel `gorm:"size:20;not null;uniqueIndex:idx_subscription"`
+	Target    string              `gorm:"size:255"` // 接收地址，为空时邮件用用户邮箱、短信用用户手机号、webhook用系统配置的地址
+	Enabled   bool                `gorm:"default:true"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// NotificationOutbox 待分发的通知事件
+// 业务事务中写入，事务提交后由定时任务按订阅展开为发送记录
+type NotificationOutbox struct {
+	ID           uint              `gorm:"primaryKey"`
+	Event        NotificationEvent `gorm:"size:50;not null"`
+	StoreID      *uint             // 事件所属店铺，只通知总部人员和该店铺人员；为空时通知所有订阅者
+	Payload      string            `gorm:"type:text"` // 模板数据，JSON格式
+	DispatchedAt *time.Time        `gorm:"index"`     // 展开为发送记录的时间
+	CreatedAt    time.Time
+}
+
+// NotificationDelivery 单个用户单个渠道的通知发送记录
+type NotificationDelivery struct {
+	ID        uint                `gorm:"primaryKey"`
+	OutboxID  uint                `gorm:"not null;index"`
+	UserID    uint                `gorm:"not null"`
+	Event     NotificationEvent   `gorm:"size:50;not null"`
+	Channel   NotificationChannel `gorm:"size:20;not null"`
+	Target    string              `gorm:"size:255"` // 实际接收地址
+	Subject   string              `gorm:"size:200"`
+	Body      string              `gorm:"type:text"`
+	Status    DeliveryStatus      `gorm:"size:20;not null;default:'pending';index"`
+	Attempts  int                 // 已尝试发送次数
+	LastError string              `gorm:"size:1000"`
+	SentAt    *time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Notification 站内信
+type Notification struct {
+	ID        uint              `gorm:"primaryKey"`
+	UserID    uint              `gorm:"not null;index"`
+	Event     NotificationEvent `gorm:"size:50;not null"`
+	Title     string            `gorm:"size:200"`
+	Content   string            `gorm:"type:text"`
+	ReadAt    *time.Time        // 阅读时间，为空表示未读
+	CreatedAt time.Time
+}
@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// PriceType 价格类型
+type PriceType string
+
+const (
+	PriceTag   PriceType = "tag"   // 吊牌价，适用于所有店铺
+	PricePromo PriceType = "promo" // 促销价，可限定店铺
+	PriceStore PriceType = "store" // 门店价，覆盖指定店铺的吊牌价
+)
+
+// Valid 判断价格类型是否有效
+func (t PriceType) Valid() bool {
+	switch t {
+	case PriceTag, PricePromo, PriceStore:
+		return true
+	}
+	return false
+}
+
+// ProductPrice 价格表条目，在生效区间 [StartAt, EndAt) 内有效，EndAt 为空表示长期有效
+// 同类型区间重叠时以开始时间最晚的条目为准
+type ProductPrice struct {
+	ID        uint       `gorm:"primaryKey"`
+	ProductID uint       `gorm:"not null;index:idx_price_lookup"`
+	Type      PriceType  `gorm:"size:20;not null;index:idx_price_lookup"`
+	StoreID   *uint      `gorm:"index"` // 适用店铺，为空表示所有店铺；门店价必填
+	Price     float64    `gorm:"not null"`
+	StartAt   time.Time  `gorm:"not null"` // 生效时间
+	EndAt     *time.Time // 失效时间（不含）
+	Note      string     `gorm:"size:255"`
+	CreatedBy uint
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// PriceAction 价格变更操作
+type PriceAction string
+
+const (
+	PriceCreated PriceAction = "create" // 新建
+	PriceUpdated PriceAction = "update" // 修改价格或生效区间
+	PriceEnded   PriceAction = "end"    // 提前结束
+)
+
+// ProductPriceHistory 价格表变更历史，每次新建、修改和结束价格都记录变更前后的值
+type ProductPriceHistory struct {
+	ID         uint      `gorm:"primaryKey"`
+	PriceID    uint      `gorm:"not null;index"`
+	ProductID  uint      `gorm:"not null;index"`
+	Type       PriceType `gorm:"size:20;not null"`
+	StoreID    *uint
+	Action     PriceAction `gorm:"size:20;not null"`
+	OldPrice   float64
+	NewPrice   float64
+	OldStartAt *time.Time
+	NewStartAt time.Time
+	OldEndAt   *time.Time
+	NewEndAt   *time.Time
+	OperatorID uint
+	Note       string `gorm:"size:255"`
+	CreatedAt  time.Time
+}
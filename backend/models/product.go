@@ -2,13 +2,15 @@ package models
 
 type Product struct {
 	ID          uint   `gorm:"primaryKey"`
+	StyleID     *uint  `gorm:"index"`
+	CategoryID  *uint  `gorm:"index"` // 类别树节点
 	SKU         string `gorm:"size:255;uniqueIndex"`
-	Name        string
-	Color       string
-	Size        string
-	Season      string
-	Category    string
+	Name        string `gorm:"size:255;index"`
+	Color       string `gorm:"size:255;index"`
+	Size        string `gorm:"size:255;index"`
+	Season      string `gorm:"size:255;index"`
+	Category    string `gorm:"size:255;index"` // 类别名称，与 CategoryID 对应节点同步
 	Image       string
 	CostPrice   float64
-	RetailPrice float64
+	RetailPrice float64 `gorm:"index"`
 }
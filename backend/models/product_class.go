@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// ABCClass 商品ABC分类
+type ABCClass string
+
+const (
+	ClassA ABCClass = "A" // 销售额累计占比前80%
+	ClassB ABCClass = "B" // 销售额累计占比80%-95%
+	ClassC ABCClass = "C" // 其余商品，包括无销售的在库商品
+)
+
+// ProductClass 店铺商品的ABC分类结果
+type ProductClass struct {
+	ID           uint      `gorm:"primaryKey"`
+	StoreID      uint      `gorm:"not null;uniqueIndex:idx_store_product_class"`
+	ProductID    uint      `gorm:"not null;uniqueIndex:idx_store_product_class"`
+	Class        ABCClass  `gorm:"size:1;not null;index"`
+	SalesValue   float64   // 统计期内销售额
+	ValueShare   float64   // 累计销售额占比（%）
+	Rank         int       // 店铺内按销售额排名
+	CalculatedAt time.Time // 分类计算时间
+}
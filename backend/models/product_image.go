@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// ProductImage 商品或款式图库中的图片，按 SortOrder 排序，第一张为主图
+type ProductImage struct {
+	ID          uint   `gorm:"primaryKey"`
+	ProductID   *uint  `gorm:"index"`             // 所属商品，与 StyleID 二选一
+	StyleID     *uint  `gorm:"index"`             // 所属款式
+	Key         string `gorm:"size:255;not null"` // 存储路径
+	URL         string `gorm:"size:500;not null"`
+	ThumbKey    string `gorm:"size:255"` // 缩略图存储路径，无缩略图时为空
+	ThumbURL    string `gorm:"size:500"`
+	ContentType string `gorm:"size:50"`
+	Size        int64
+	Width       int
+	Height      int
+	SortOrder   int  `gorm:"not null;default:0"`
+	UploadedBy  uint // 上传人ID
+	CreatedAt   time.Time
+}
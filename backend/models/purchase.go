@@ -129,6 +129,7 @@ type Supplier struct {
 	Qualification string         `gorm:"size:255"`            // 资质证明
 	PaymentTerms  string         `gorm:"size:100"`            // 付款条件
 	DeliveryTerms string         `gorm:"size:100"`            // 交货条件
+	LeadTimeDays  int            `gorm:"default:7"`           // 供货周期（下单到到货天数）
 	Status        bool           `gorm:"default:true"`        // 状态：true-启用，false-禁用
 	Note          string         `gorm:"size:255"`            // 备注
 	CreatedAt     time.Time
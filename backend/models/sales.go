@@ -23,6 +23,24 @@ const (
 	Returned       OrderStatus = "returned"  // 已退货
 )
 
+// orderTransitions 订单允许的状态变更，已取消和已退货为终态
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	Created:        {Paid, Shipped, OrderCompleted, OrderCancelled},
+	Paid:           {Shipped, OrderCompleted, Returned},
+	Shipped:        {OrderCompleted, Returned},
+	OrderCompleted: {Returned},
+}
+
+// CanTransitionTo 判断订单能否从当前状态变更为 next
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
 // PaymentMethod 支付方式
 type PaymentMethod string
 
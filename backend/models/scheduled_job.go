@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// ScheduledJob 定时任务，执行计划使用5段cron表达式（分 时 日 月 周）
+type ScheduledJob struct {
+	ID          uint       `gorm:"primaryKey"`
+	Name        string     `gorm:"size:50;uniqueIndex"` // 任务名称，与代码中注册的任务对应
+	Description string     `gorm:"size:255"`            // 任务说明
+	Cron        string     `gorm:"size:100;not null"`   // 执行计划
+	Paused      bool       `gorm:"default:false"`       // 是否暂停
+	NextRunAt   *time.Time `gorm:"index"`               // 下次执行时间
+	LastRunAt   *time.Time // 上次执行时间
+	LockedBy    string     `gorm:"size:100"` // 持有执行锁的实例
+	LockedUntil *time.Time // 执行锁过期时间
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// JobRunStatus 任务执行状态
+type JobRunStatus string
+
+const (
+	JobRunning   JobRunStatus = "running"   // 执行中
+	JobSucceeded JobRunStatus = "succeeded" // 成功
+	JobFailed    JobRunStatus = "failed"    // 失败
+)
+
+// JobRun 定时任务执行记录
+type JobRun struct {
+	ID         uint         `gorm:"primaryKey"`
+	JobID      uint         `gorm:"not null;index"` // 任务ID
+	JobName    string       `gorm:"size:50"`        // 任务名称
+	Trigger    string       `gorm:"size:20"`        // 触发方式：schedule/manual
+	Instance   string       `gorm:"size:100"`       // 执行实例
+	Status     JobRunStatus `gorm:"size:20;not null"`
+	StartedAt  time.Time    // 开始时间
+	FinishedAt *time.Time   // 结束时间
+	DurationMs int64        // 耗时（毫秒）
+	Message    string       `gorm:"size:1000"` // 执行结果或错误信息
+}
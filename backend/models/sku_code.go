@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// SKUCodeKind SKU编码字典类型，对应SKU编码规则中的各段
+type SKUCodeKind string
+
+const (
+	SKUCategory SKUCodeKind = "category" // 品类，2位
+	SKUSeason   SKUCodeKind = "season"   // 季节，1位
+	SKUColor    SKUCodeKind = "color"    // 颜色，3位
+	SKUSize     SKUCodeKind = "size"     // 尺码，2位
+)
+
+// CodeLength 返回该类型编码的固定长度，类型无效时返回0
+func (k SKUCodeKind) CodeLength() int {
+	switch k {
+	case SKUCategory, SKUSize:
+		return 2
+	case SKUSeason:
+		return 1
+	case SKUColor:
+		return 3
+	}
+	return 0
+}
+
+// SKUCode SKU编码字典，将商品属性值映射为固定长度的编码
+type SKUCode struct {
+	ID         uint        `gorm:"primaryKey"`
+	Kind       SKUCodeKind `gorm:"size:20;not null;uniqueIndex:idx_sku_kind_code;uniqueIndex:idx_sku_kind_name"`
+	Code       string      `gorm:"size:3;not null;uniqueIndex:idx_sku_kind_code"`  // 编码，大写字母或数字
+	Name       string      `gorm:"size:50;not null;uniqueIndex:idx_sku_kind_name"` // 属性值，与商品的品类、季节、颜色、尺码一致
+	CategoryID *uint       `gorm:"index"`                                          // 品类编码对应的类别树节点
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
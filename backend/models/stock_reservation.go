@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// ReservationStatus 库存预留状态
+type ReservationStatus string
+
+const (
+	ReservationActive    ReservationStatus = "active"    // 预留中
+	ReservationConverted ReservationStatus = "converted" // 已支付转为销售出库
+	ReservationReleased  ReservationStatus = "released"  // 订单取消已释放
+	ReservationExpired   ReservationStatus = "expired"   // 超时已释放
+)
+
+// StockReservation 线上订单库存预留
+type StockReservation struct {
+	ID          uint              `gorm:"primaryKey"`
+	StoreID     uint              `gorm:"not null;index:idx_reservation_store_product"`
+	ProductID   uint              `gorm:"not null;index:idx_reservation_store_product"`
+	OrderID     uint              `gorm:"not null;index"` // 销售订单ID
+	OrderItemID uint              `gorm:"not null"`       // 销售订单明细ID
+	Quantity    int               `gorm:"not null"`       // 预留数量
+	BatchNumber string            `gorm:"size:50"`        // 扫码指定的批次号
+	Status      ReservationStatus `gorm:"size:20;not null;default:'active';index"`
+	ExpiresAt   time.Time         `gorm:"index"` // 预留过期时间
+	ReleasedAt  *time.Time        // 释放或转出库时间
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
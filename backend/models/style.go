@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Style 款式，同一款式按颜色×尺码组合拆分为多个商品（SKU）
+type Style struct {
+	ID          uint      `gorm:"primaryKey"`
+	Code        string    `gorm:"size:50;uniqueIndex;not null"` // 款号
+	Name        string    `gorm:"size:100;not null"`
+	Season      string    `gorm:"size:20"`
+	Category    string    `gorm:"size:50"`
+	Image       string    `gorm:"size:255"`
+	CostPrice   float64   // 款式默认成本价
+	RetailPrice float64   // 款式默认零售价
+	Variants    []Product `gorm:"foreignKey:StyleID"` // 款式下的颜色尺码商品
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
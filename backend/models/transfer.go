@@ -0,0 +1,66 @@
+package models
+
+import "time"
+
+// TransferStatus 调拨单状态
+type TransferStatus string
+
+const (
+	TransferDraft       TransferStatus = "draft"       // 草稿
+	TransferApproved    TransferStatus = "approved"    // 已审核
+	TransferInTransit   TransferStatus = "in_transit"  // 在途
+	TransferDiscrepancy TransferStatus = "discrepancy" // 收货差异待处理
+	TransferReceived    TransferStatus = "received"    // 已收货
+	TransferCancelled   TransferStatus = "cancelled"   // 已取消
+)
+
+// TransferResolution 调拨差异处理方式
+type TransferResolution string
+
+const (
+	ResolutionReturnSource  TransferResolution = "return_source"  // 短收：差异商品退回调出店铺
+	ResolutionWriteOff      TransferResolution = "write_off"      // 短收：差异商品作为在途损耗核销
+	ResolutionConfirmSource TransferResolution = "confirm_source" // 超收：确认调出店铺多发，补记调出
+)
+
+// TransferOrder 店铺间调拨单
+type TransferOrder struct {
+	ID             uint           `gorm:"primaryKey"`
+	TransferNumber string         `gorm:"size:50;uniqueIndex"`              // 调拨单号
+	SourceStoreID  uint           `gorm:"not null"`                         // 调出店铺ID
+	DestStoreID    uint           `gorm:"not null"`                         // 调入店铺ID
+	Status         TransferStatus `gorm:"size:20;not null;default:'draft'"` // 调拨单状态
+	CreatorID      uint           `gorm:"not null"`                         // 创建人ID
+	ApproverID     *uint          // 审核人ID
+	ApprovalTime   *time.Time     // 审核时间
+	ShipperID      *uint          // 发货人ID
+	ShippedAt      *time.Time     // 发货时间
+	ReceiverID     *uint          // 收货人ID
+	ReceivedAt     *time.Time     // 收货时间
+	Note           string         `gorm:"size:255"` // 备注
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+
+	// 关联
+	Items       []TransferOrderItem // 调拨明细
+	SourceStore Store               `gorm:"foreignKey:SourceStoreID"`
+	DestStore   Store               `gorm:"foreignKey:DestStoreID"`
+}
+
+// TransferOrderItem 调拨单明细
+type TransferOrderItem struct {
+	ID              uint               `gorm:"primaryKey"`
+	TransferOrderID uint               `gorm:"not null"` // 调拨单ID
+	ProductID       uint               `gorm:"not null"` // 商品ID
+	Quantity        int                `gorm:"not null"` // 计划调拨数量
+	ShippedQty      int                // 实际发货数量
+	ReceivedQty     int                // 实际收货数量
+	DifferenceQty   int                // 差异数量（收货-发货）
+	Resolution      TransferResolution `gorm:"size:20"`  // 差异处理方式
+	Note            string             `gorm:"size:255"` // 备注
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+
+	// 关联
+	Product Product `gorm:"foreignKey:ProductID"`
+}
@@ -0,0 +1,312 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hd_psi/backend/config"
+	"hd_psi/backend/models"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DispatchResult 一次分发的结果
+type DispatchResult struct {
+	Events int `json:"events"` // 展开的通知事件数
+	Sent   int `json:"sent"`   // 发送成功数
+	Failed int `json:"failed"` // 最终发送失败数
+}
+
+// Dispatcher 通知分发器
+// 将通知事件按订阅展开为发送记录，并通过各渠道驱动发送，发送失败时在下次分发时重试
+type Dispatcher struct {
+	db          *gorm.DB
+	drivers     map[models.NotificationChannel]Driver
+	templates   *Templates
+	maxAttempts int // 单条发送记录的最大尝试次数
+	batchSize   int // 每次分发处理的最大记录数
+}
+
+// NewDispatcher 创建分发器，默认启用站内信渠道
+func NewDispatcher(db *gorm.DB) *Dispatcher {
+	d := &Dispatcher{
+		db:          db,
+		drivers:     make(map[models.NotificationChannel]Driver),
+		templates:   NewTemplates(),
+		maxAttempts: 3,
+		batchSize:   200,
+	}
+	d.RegisterDriver(models.ChannelInbox, NewInboxDriver(db))
+	return d
+}
+
+// NewDispatcherFromConfig 创建分发器，并按配置启用邮件、Webhook和短信渠道
+func NewDispatcherFromConfig(db *gorm.DB) *Dispatcher {
+	d := NewDispatcher(db)
+
+	if smtpConfig := config.GetSMTPConfig(); smtpConfig.Host != "" {
+		d.RegisterDriver(models.ChannelEmail, NewSMTPDriver(smtpConfig.Host, smtpConfig.Port,
+			smtpConfig.Username, smtpConfig.Password, smtpConfig.From))
+	}
+
+	// 订阅可以单独配置回调地址，未配置默认地址时也启用
+	url, secret := config.GetNotifyWebhook()
+	d.RegisterDriver(models.ChannelWebhook, NewWebhookDriver(url, secret))
+
+	if url, apiKey := config.GetSMSConfig(); url != "" {
+		d.RegisterDriver(models.ChannelSMS, NewSMSDriver(NewHTTPSMSProvider(url, apiKey)))
+	}
+
+	return d
+}
+
+// RegisterDriver 注册渠道驱动，已注册的驱动会被替换
+func (d *Dispatcher) RegisterDriver(channel models.NotificationChannel, driver Driver) {
+	d.drivers[channel] = driver
+}
+
+// Templates 返回分发器使用的模板集合，可用于覆盖默认模板
+func (d *Dispatcher) Templates() *Templates {
+	return d.templates
+}
+
+// Dispatch 展开待分发的通知事件并发送待发送的记录
+func (d *Dispatcher) Dispatch() (DispatchResult, error) {
+	var result DispatchResult
+
+	events, err := d.expandPending()
+	result.Events = events
+	if err != nil {
+		return result, err
+	}
+
+	result.Sent, result.Failed, err = d.sendPending()
+	return result, err
+}
+
+// expandPending 将未分发的通知事件按订阅展开为发送记录
+func (d *Dispatcher) expandPending() (int, error) {
+	var outboxes []models.NotificationOutbox
+	if err := d.db.Where("dispatched_at IS NULL").Order("id").
+		Limit(d.batchSize).Find(&outboxes).Error; err != nil {
+		return 0, fmt.Errorf("查询通知事件失败: %w", err)
+	}
+
+	for i := range outboxes {
+		if err := d.expand(&outboxes[i]); err != nil {
+			return i, fmt.Errorf("分发通知事件 %d 失败: %w", outboxes[i].ID, err)
+		}
+	}
+	return len(outboxes), nil
+}
+
+// expand 补充模板数据、渲染通知内容，并为每个订阅创建发送记录
+func (d *Dispatcher) expand(outbox *models.NotificationOutbox) error {
+	data := make(map[string]interface{})
+	if outbox.Payload != "" {
+		if err := json.Unmarshal([]byte(outbox.Payload), &data); err != nil {
+			return fmt.Errorf("解析通知数据失败: %w", err)
+		}
+	}
+	d.enrich(data)
+
+	msg, err := d.templates.Render(outbox.Event, data)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("序列化通知数据失败: %w", err)
+	}
+
+	type subscriber struct {
+		models.NotificationSubscription
+		Email string
+		Phone string
+	}
+	query := d.db.Table("notification_subscriptions").
+		Select("notification_subscriptions.*, users.email, users.phone").
+		Joins("JOIN users ON users.id = notification_subscriptions.user_id").
+		Where("notification_subscriptions.event = ? AND notification_subscriptions.enabled = ? AND users.status = ?",
+			outbox.Event, true, true)
+	if outbox.StoreID != nil {
+		query = query.Where("users.store_id IS NULL OR users.store_id = ?", *outbox.StoreID)
+	}
+	var subscribers []subscriber
+	if err := query.Scan(&subscribers).Error; err != nil {
+		return fmt.Errorf("查询通知订阅失败: %w", err)
+	}
+
+	deliveries := make([]models.NotificationDelivery, 0, len(subscribers))
+	for _, s := range subscribers {
+		target := s.Target
+		if target == "" {
+			switch s.Channel {
+			case models.ChannelEmail:
+				target = s.Email
+			case models.ChannelSMS:
+				target = s.Phone
+			}
+		}
+		deliveries = append(deliveries, models.NotificationDelivery{
+			OutboxID: outbox.ID,
+			UserID:   s.UserID,
+			Event:    outbox.Event,
+			Channel:  s.Channel,
+			Target:   target,
+			Subject:  msg.Subject,
+			Body:     msg.Body,
+			Status:   models.DeliveryPending,
+		})
+	}
+
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		// 以未分发为条件更新，避免重复展开
+		result := tx.Model(outbox).Where("dispatched_at IS NULL").
+			Updates(map[string]interface{}{"dispatched_at": time.Now(), "payload": string(payload)})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 || len(deliveries) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(&deliveries, 100).Error
+	})
+}
+
+// enrich 根据事件数据中的店铺、商品和供应商ID补充名称，便于模板展示
+func (d *Dispatcher) enrich(data map[string]interface{}) {
+	if id := idOf(data, "store_id"); id != 0 {
+		var store models.Store
+		if err := d.db.Select("id, name").First(&store, id).Error; err == nil {
+			data["store_name"] = store.Name
+		}
+	}
+	if id := idOf(data, "product_id"); id != 0 {
+		var product models.Product
+		if err := d.db.Select("id, sku, name").First(&product, id).Error; err == nil {
+			data["product_name"] = product.Name
+			data["product_sku"] = product.SKU
+		}
+	}
+	if id := idOf(data, "supplier_id"); id != 0 {
+		var supplier models.Supplier
+		if err := d.db.Select("id, name").First(&supplier, id).Error; err == nil {
+			data["supplier_name"] = supplier.Name
+		}
+	}
+}
+
+// idOf 读取事件数据中的ID字段，JSON解码后的数字为float64
+func idOf(data map[string]interface{}, key string) uint {
+	switch v := data[key].(type) {
+	case float64:
+		return uint(v)
+	case uint:
+		return v
+	case int:
+		return uint(v)
+	}
+	return 0
+}
+
+// sendPending 发送待发送的记录
+// 返回：
+//   - int: 发送成功数
+//   - int: 最终发送失败数（不再重试）
+//   - error: 查询发送记录失败时返回错误
+func (d *Dispatcher) sendPending() (int, int, error) {
+	var deliveries []models.NotificationDelivery
+	if err := d.db.Where("status = ?", models.DeliveryPending).Order("id").
+		Limit(d.batchSize).Find(&deliveries).Error; err != nil {
+		return 0, 0, fmt.Errorf("查询通知发送记录失败: %w", err)
+	}
+
+	sent, failed := 0, 0
+	payloads := make(map[uint]map[string]interface{})
+	for i := range deliveries {
+		delivery := &deliveries[i]
+
+		data, ok := payloads[delivery.OutboxID]
+		if !ok {
+			data = d.loadPayload(delivery.OutboxID)
+			payloads[delivery.OutboxID] = data
+		}
+
+		d.record(delivery, d.send(delivery, data))
+		switch delivery.Status {
+		case models.DeliverySent:
+			sent++
+		case models.DeliveryFailed:
+			failed++
+		}
+
+		if err := d.db.Save(delivery).Error; err != nil {
+			log.Printf("更新通知发送记录 %d 失败: %v", delivery.ID, err)
+		}
+	}
+	return sent, failed, nil
+}
+
+// record 记录一次发送结果
+// 没有接收地址、渠道未启用、回调地址不允许或达到最大尝试次数时标记为失败，其余错误保持待发送，下次分发时重试
+func (d *Dispatcher) record(delivery *models.NotificationDelivery, err error) {
+	delivery.Attempts++
+	if err == nil {
+		now := time.Now()
+		delivery.Status = models.DeliverySent
+		delivery.SentAt = &now
+		delivery.LastError = ""
+		return
+	}
+
+	delivery.LastError = truncate(err.Error(), 1000)
+	if errors.Is(err, ErrNoTarget) || errors.Is(err, errNoDriver) || errors.Is(err, ErrForbiddenTarget) ||
+		delivery.Attempts >= d.maxAttempts {
+		delivery.Status = models.DeliveryFailed
+	}
+}
+
+// errNoDriver 渠道未启用
+var errNoDriver = errors.New("通知渠道未启用")
+
+// send 通过渠道驱动发送一条记录
+func (d *Dispatcher) send(delivery *models.NotificationDelivery, data map[string]interface{}) error {
+	driver, ok := d.drivers[delivery.Channel]
+	if !ok {
+		return errNoDriver
+	}
+
+	to := Recipient{UserID: delivery.UserID, Target: delivery.Target}
+	var user models.User
+	if err := d.db.Select("id, name").First(&user, delivery.UserID).Error; err == nil {
+		to.Name = user.Name
+	}
+
+	return driver.Send(to, Message{
+		Event:   delivery.Event,
+		Subject: delivery.Subject,
+		Body:    delivery.Body,
+		Data:    data,
+	})
+}
+
+// loadPayload 读取通知事件的模板数据
+func (d *Dispatcher) loadPayload(outboxID uint) map[string]interface{} {
+	data := make(map[string]interface{})
+	var outbox models.NotificationOutbox
+	if err := d.db.Select("id, payload").First(&outbox, outboxID).Error; err == nil && outbox.Payload != "" {
+		json.Unmarshal([]byte(outbox.Payload), &data)
+	}
+	return data
+}
+
+// truncate 按字符截断字符串
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
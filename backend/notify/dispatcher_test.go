@@ -0,0 +1,152 @@
+package notify
+
+import (
+	"errors"
+	"testing"
+
+	"hd_psi/backend/models"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// dryRunDB 不连接数据库的 gorm 实例，只生成SQL
+func dryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		DSN:                       "test:test@tcp(127.0.0.1:0)/test?parseTime=true",
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		DryRun:                 true,
+		SkipDefaultTransaction: true,
+		DisableAutomaticPing:   true,
+		Logger:                 logger.Discard,
+	})
+	if err != nil {
+		t.Fatalf("open dry-run db: %v", err)
+	}
+	return db
+}
+
+// stubDriver 记录发送内容并返回预设错误的渠道驱动
+type stubDriver struct {
+	sent []Recipient
+	msgs []Message
+	err  error
+}
+
+func (d *stubDriver) Send(to Recipient, msg Message) error {
+	d.sent = append(d.sent, to)
+	d.msgs = append(d.msgs, msg)
+	return d.err
+}
+
+func TestEnqueueWritesOutbox(t *testing.T) {
+	db := dryRunDB(t)
+	var created *models.NotificationOutbox
+	db.Callback().Create().After("gorm:create").Register("test:capture", func(tx *gorm.DB) {
+		created, _ = tx.Statement.Dest.(*models.NotificationOutbox)
+	})
+
+	storeID := uint(3)
+	if err := Enqueue(db, models.EventInventoryAlert, &storeID, map[string]interface{}{"product_id": 5}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if created == nil {
+		t.Fatal("outbox was not created")
+	}
+	if created.Event != models.EventInventoryAlert || created.StoreID == nil || *created.StoreID != 3 ||
+		created.Payload != `{"product_id":5}` || created.DispatchedAt != nil {
+		t.Errorf("outbox = %+v", created)
+	}
+
+	if err := Enqueue(db, models.EventInventoryAlert, nil, map[string]interface{}{"bad": func() {}}); err == nil {
+		t.Error("expected error for unserializable data")
+	}
+}
+
+func TestDispatcherSendUsesChannelDriver(t *testing.T) {
+	d := NewDispatcher(dryRunDB(t))
+	stub := &stubDriver{}
+	d.RegisterDriver(models.ChannelSMS, stub)
+
+	delivery := &models.NotificationDelivery{
+		UserID: 9, Event: models.EventInventoryAlert, Channel: models.ChannelSMS,
+		Target: "13800000000", Subject: "标题", Body: "正文",
+	}
+	data := map[string]interface{}{"store_name": "一店"}
+	if err := d.send(delivery, data); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if len(stub.sent) != 1 || stub.sent[0].UserID != 9 || stub.sent[0].Target != "13800000000" {
+		t.Fatalf("recipient = %+v", stub.sent)
+	}
+	if msg := stub.msgs[0]; msg.Subject != "标题" || msg.Body != "正文" || msg.Data["store_name"] != "一店" {
+		t.Errorf("message = %+v", msg)
+	}
+
+	delivery.Channel = models.ChannelEmail
+	if err := d.send(delivery, data); !errors.Is(err, errNoDriver) {
+		t.Errorf("err = %v, want errNoDriver", err)
+	}
+}
+
+func TestDispatcherRecordRetries(t *testing.T) {
+	d := NewDispatcher(dryRunDB(t))
+	delivery := &models.NotificationDelivery{Status: models.DeliveryPending}
+
+	transient := errors.New("connection reset")
+	for attempt := 1; attempt < d.maxAttempts; attempt++ {
+		d.record(delivery, transient)
+		if delivery.Status != models.DeliveryPending || delivery.Attempts != attempt {
+			t.Fatalf("attempt %d: status %s attempts %d", attempt, delivery.Status, delivery.Attempts)
+		}
+	}
+	d.record(delivery, transient)
+	if delivery.Status != models.DeliveryFailed || delivery.LastError != transient.Error() {
+		t.Errorf("after max attempts: status %s error %q", delivery.Status, delivery.LastError)
+	}
+}
+
+func TestDispatcherRecordPermanentErrors(t *testing.T) {
+	d := NewDispatcher(dryRunDB(t))
+	for _, err := range []error{ErrNoTarget, errNoDriver, ErrForbiddenTarget} {
+		delivery := &models.NotificationDelivery{Status: models.DeliveryPending}
+		d.record(delivery, err)
+		if delivery.Status != models.DeliveryFailed || delivery.Attempts != 1 {
+			t.Errorf("%v: status %s attempts %d", err, delivery.Status, delivery.Attempts)
+		}
+	}
+}
+
+func TestDispatcherRecordSuccessClearsError(t *testing.T) {
+	d := NewDispatcher(dryRunDB(t))
+	delivery := &models.NotificationDelivery{Status: models.DeliveryPending, Attempts: 1, LastError: "timeout"}
+	d.record(delivery, nil)
+	if delivery.Status != models.DeliverySent || delivery.SentAt == nil || delivery.LastError != "" || delivery.Attempts != 2 {
+		t.Errorf("delivery = %+v", delivery)
+	}
+}
+
+func TestTemplatesRender(t *testing.T) {
+	msg, err := NewTemplates().Render(models.EventPurchaseOrderPending, map[string]interface{}{
+		"store_name":   "一店",
+		"order_number": "PO001",
+		"total_amount": 100,
+	})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if msg.Subject != "【待审核】采购单 PO001" {
+		t.Errorf("subject = %q", msg.Subject)
+	}
+	// 缺少的字段渲染为空
+	if want := "一店 的采购单 PO001 已提交审核，供应商：，金额 100 元。请及时审核。"; msg.Body != want {
+		t.Errorf("body = %q, want %q", msg.Body, want)
+	}
+
+	if _, err := NewTemplates().Render("unknown", nil); err == nil {
+		t.Error("expected error for event without template")
+	}
+}
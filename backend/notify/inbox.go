@@ -0,0 +1,27 @@
+package notify
+
+import (
+	"hd_psi/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// InboxDriver 站内信驱动，将通知写入用户收件箱
+type InboxDriver struct {
+	db *gorm.DB
+}
+
+// NewInboxDriver 创建站内信驱动
+func NewInboxDriver(db *gorm.DB) *InboxDriver {
+	return &InboxDriver{db: db}
+}
+
+// Send 写入一条站内信
+func (d *InboxDriver) Send(to Recipient, msg Message) error {
+	return d.db.Create(&models.Notification{
+		UserID:  to.UserID,
+		Event:   msg.Event,
+		Title:   msg.Subject,
+		Content: msg.Body,
+	}).Error
+}
@@ -0,0 +1,52 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hd_psi/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrNoTarget 订阅者没有配置该渠道的接收地址
+var ErrNoTarget = errors.New("未配置接收地址")
+
+// Message 渲染后的通知内容
+type Message struct {
+	Event   models.NotificationEvent
+	Subject string
+	Body    string
+	Data    map[string]interface{} // 模板数据，webhook 原样发送
+}
+
+// Recipient 通知接收人
+type Recipient struct {
+	UserID uint
+	Name   string
+	Target string // 渠道接收地址：邮箱、手机号或回调URL
+}
+
+// Driver 通知渠道驱动
+type Driver interface {
+	Send(to Recipient, msg Message) error
+}
+
+// Enqueue 在事务tx中写入待分发的通知事件，事务提交后由调度任务分发
+// storeID 不为空时只通知总部人员和该店铺人员
+func Enqueue(tx *gorm.DB, event models.NotificationEvent, storeID *uint, data map[string]interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("序列化通知数据失败: %w", err)
+	}
+
+	outbox := models.NotificationOutbox{
+		Event:   event,
+		StoreID: storeID,
+		Payload: string(payload),
+	}
+	if err := tx.Create(&outbox).Error; err != nil {
+		return fmt.Errorf("创建通知事件失败: %w", err)
+	}
+	return nil
+}
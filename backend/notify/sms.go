@@ -0,0 +1,68 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SMSProvider 短信服务商接口，接入具体服务商时实现该接口
+type SMSProvider interface {
+	SendSMS(phone, content string) error
+}
+
+// SMSDriver 短信驱动，短信内容为标题和正文
+type SMSDriver struct {
+	Provider SMSProvider
+}
+
+// NewSMSDriver 创建短信驱动
+func NewSMSDriver(provider SMSProvider) *SMSDriver {
+	return &SMSDriver{Provider: provider}
+}
+
+// Send 发送短信
+func (d *SMSDriver) Send(to Recipient, msg Message) error {
+	if to.Target == "" {
+		return ErrNoTarget
+	}
+	return d.Provider.SendSMS(to.Target, msg.Subject+" "+msg.Body)
+}
+
+// HTTPSMSProvider 通用HTTP短信网关
+// 以 JSON {"phone": "...", "content": "..."} POST 到网关地址，密钥通过 Authorization: Bearer 传递
+type HTTPSMSProvider struct {
+	URL    string
+	APIKey string
+	Client *http.Client
+}
+
+// NewHTTPSMSProvider 创建HTTP短信网关
+func NewHTTPSMSProvider(url, apiKey string) *HTTPSMSProvider {
+	return &HTTPSMSProvider{
+		URL:    url,
+		APIKey: apiKey,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// SendSMS 调用短信网关发送短信
+func (p *HTTPSMSProvider) SendSMS(phone, content string) error {
+	body, err := json.Marshal(map[string]string{"phone": phone, "content": content})
+	if err != nil {
+		return fmt.Errorf("序列化短信请求失败: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("创建短信请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if p.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+
+	return doRequest(p.Client, req)
+}
@@ -0,0 +1,57 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// stubSMSProvider 记录发送内容的短信服务商
+type stubSMSProvider struct {
+	phone, content string
+	err            error
+}
+
+func (p *stubSMSProvider) SendSMS(phone, content string) error {
+	p.phone, p.content = phone, content
+	return p.err
+}
+
+func TestSMSDriverSend(t *testing.T) {
+	provider := &stubSMSProvider{}
+	d := NewSMSDriver(provider)
+	if err := d.Send(Recipient{Target: "13800000000"}, Message{Subject: "【库存预警】", Body: "库存不足"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if provider.phone != "13800000000" || provider.content != "【库存预警】 库存不足" {
+		t.Errorf("provider got %q %q", provider.phone, provider.content)
+	}
+
+	if err := d.Send(Recipient{}, Message{}); !errors.Is(err, ErrNoTarget) {
+		t.Errorf("err = %v, want ErrNoTarget", err)
+	}
+
+	provider.err = errors.New("quota exceeded")
+	if err := d.Send(Recipient{Target: "13800000000"}, Message{}); !errors.Is(err, provider.err) {
+		t.Errorf("err = %v, want provider error", err)
+	}
+}
+
+func TestHTTPSMSProvider(t *testing.T) {
+	var got map[string]string
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	if err := NewHTTPSMSProvider(srv.URL, "key").SendSMS("13800000000", "内容"); err != nil {
+		t.Fatalf("SendSMS: %v", err)
+	}
+	if auth != "Bearer key" || got["phone"] != "13800000000" || got["content"] != "内容" {
+		t.Errorf("auth %q body %v", auth, got)
+	}
+}
@@ -0,0 +1,77 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// SMTPDriver 邮件驱动，正文按 UTF-8 和 base64 编码，支持中文标题和内容
+type SMTPDriver struct {
+	Addr     string // 服务器地址 host:port
+	Username string // 为空时不认证
+	Password string
+	From     string
+}
+
+// NewSMTPDriver 创建邮件驱动
+func NewSMTPDriver(host, port, username, password, from string) *SMTPDriver {
+	if from == "" {
+		from = username
+	}
+	return &SMTPDriver{
+		Addr:     net.JoinHostPort(host, port),
+		Username: username,
+		Password: password,
+		From:     from,
+	}
+}
+
+// Send 发送邮件
+func (d *SMTPDriver) Send(to Recipient, msg Message) error {
+	if to.Target == "" {
+		return ErrNoTarget
+	}
+
+	var auth smtp.Auth
+	if d.Username != "" {
+		host, _, _ := net.SplitHostPort(d.Addr)
+		auth = smtp.PlainAuth("", d.Username, d.Password, host)
+	}
+
+	if err := smtp.SendMail(d.Addr, auth, d.From, []string{to.Target}, d.buildMessage(to, msg)); err != nil {
+		return fmt.Errorf("发送邮件失败: %w", err)
+	}
+	return nil
+}
+
+// buildMessage 组装 MIME 邮件
+func (d *SMTPDriver) buildMessage(to Recipient, msg Message) []byte {
+	recipient := to.Target
+	if to.Name != "" {
+		recipient = fmt.Sprintf("%s <%s>", mime.BEncoding.Encode("UTF-8", to.Name), to.Target)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", d.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", recipient)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+
+	// base64 正文按每行76个字符换行
+	encoded := base64.StdEncoding.EncodeToString([]byte(msg.Body))
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\r\n")
+
+	return buf.Bytes()
+}
@@ -0,0 +1,56 @@
+package notify
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestSMTPDriverBuildMessage(t *testing.T) {
+	d := NewSMTPDriver("smtp.example.com", "25", "noreply@example.com", "", "")
+	body := strings.Repeat("库存预警", 20)
+	raw := d.buildMessage(Recipient{Name: "张三", Target: "zhang@example.com"}, Message{Subject: "【库存预警】", Body: body})
+
+	m, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	dec := new(mime.WordDecoder)
+	if subject, _ := dec.DecodeHeader(m.Header.Get("Subject")); subject != "【库存预警】" {
+		t.Errorf("subject = %q", subject)
+	}
+	to, err := m.Header.AddressList("To")
+	if err != nil || len(to) != 1 || to[0].Name != "张三" || to[0].Address != "zhang@example.com" {
+		t.Errorf("to = %v, %v", to, err)
+	}
+	if m.Header.Get("From") != "noreply@example.com" {
+		t.Errorf("from = %q", m.Header.Get("From"))
+	}
+
+	content, err := io.ReadAll(m.Body)
+	if err != nil {
+		t.Fatalf("read body: %v", err)
+	}
+	var encoded strings.Builder
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\r\n") {
+		if len(line) > 76 {
+			t.Errorf("line longer than 76: %d", len(line))
+		}
+		encoded.WriteString(line)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded.String())
+	if err != nil || string(decoded) != body {
+		t.Errorf("body = %q, %v", decoded, err)
+	}
+}
+
+func TestSMTPDriverNoTarget(t *testing.T) {
+	d := NewSMTPDriver("smtp.example.com", "25", "", "", "")
+	if err := d.Send(Recipient{}, Message{}); !errors.Is(err, ErrNoTarget) {
+		t.Fatalf("err = %v, want ErrNoTarget", err)
+	}
+}
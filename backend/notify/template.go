@@ -0,0 +1,87 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"hd_psi/backend/models"
+	"strings"
+	"text/template"
+)
+
+// 默认通知模板，模板数据为事件数据，并补充 store_name、product_name、product_sku
+var defaultTemplates = map[models.NotificationEvent][2]string{
+	models.EventInventoryAlert: {
+		"【库存预警】{{.store_name}} {{.product_name}}",
+		"{{.store_name}} 的商品 {{.product_name}}（{{.product_sku}}）{{.description}}：当前库存 {{.current_qty}}，阈值 {{.threshold}}。",
+	},
+	models.EventCheckAdjustmentPending: {
+		"【待审批】盘点调整 {{.check_code}}",
+		"{{.store_name}} 盘点单 {{.check_code}} 的商品 {{.product_name}}（{{.product_sku}}）申请调整 {{.adjust_quantity}} 件，原因：{{.reason}}。请及时审批。",
+	},
+	models.EventPurchaseOrderPending: {
+		"【待审核】采购单 {{.order_number}}",
+		"{{.store_name}} 的采购单 {{.order_number}} 已提交审核，供应商：{{.supplier_name}}，金额 {{.total_amount}} 元。请及时审核。",
+	},
+	models.EventDailySalesReport: {
+		"【销售日报】{{.store_name}} {{.date}}",
+		"{{.store_name}} {{.date}} 销售订单 {{.order_count}} 笔，实收 {{.sales_amount}} 元；净销售 {{.sold_quantity}} 件，销售成本 {{.cogs}} 元，毛利 {{.gross_profit}} 元。",
+	},
+}
+
+// Templates 通知模板集合
+type Templates struct {
+	subjects map[models.NotificationEvent]*template.Template
+	bodies   map[models.NotificationEvent]*template.Template
+}
+
+// NewTemplates 创建包含默认模板的模板集合
+func NewTemplates() *Templates {
+	t := &Templates{
+		subjects: make(map[models.NotificationEvent]*template.Template),
+		bodies:   make(map[models.NotificationEvent]*template.Template),
+	}
+	for event, tpl := range defaultTemplates {
+		if err := t.Set(event, tpl[0], tpl[1]); err != nil {
+			panic(err)
+		}
+	}
+	return t
+}
+
+// Set 设置事件的标题和正文模板，模板语法为 text/template
+func (t *Templates) Set(event models.NotificationEvent, subject, body string) error {
+	s, err := template.New(string(event) + ".subject").Option("missingkey=zero").Parse(subject)
+	if err != nil {
+		return fmt.Errorf("解析通知标题模板失败: %w", err)
+	}
+	b, err := template.New(string(event) + ".body").Option("missingkey=zero").Parse(body)
+	if err != nil {
+		return fmt.Errorf("解析通知正文模板失败: %w", err)
+	}
+	t.subjects[event] = s
+	t.bodies[event] = b
+	return nil
+}
+
+// Render 渲染事件的通知内容
+func (t *Templates) Render(event models.NotificationEvent, data map[string]interface{}) (Message, error) {
+	s, ok := t.subjects[event]
+	if !ok {
+		return Message{}, fmt.Errorf("通知事件 %s 没有模板", event)
+	}
+
+	var subject, body bytes.Buffer
+	if err := s.Execute(&subject, data); err != nil {
+		return Message{}, fmt.Errorf("渲染通知标题失败: %w", err)
+	}
+	if err := t.bodies[event].Execute(&body, data); err != nil {
+		return Message{}, fmt.Errorf("渲染通知正文失败: %w", err)
+	}
+
+	return Message{
+		Event:   event,
+		Subject: strings.ReplaceAll(subject.String(), "<no value>", ""),
+		Body:    strings.ReplaceAll(body.String(), "<no value>", ""),
+		Data:    data,
+	}, nil
+}
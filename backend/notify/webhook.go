@@ -0,0 +1,145 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenTarget 回调地址不是公网地址
+var ErrForbiddenTarget = errors.New("回调地址不允许指向内网或本机")
+
+// WebhookDriver 通用 Webhook 驱动，以 JSON 格式 POST 通知内容
+// 配置了密钥时在 X-Signature 请求头中附带请求体的 HMAC-SHA256 签名
+// 默认地址由部署配置，视为可信；订阅中配置的地址通过 TargetClient 发送，只允许连接公网地址
+type WebhookDriver struct {
+	URL          string // 默认回调地址，订阅中配置了地址时以订阅为准
+	Secret       string
+	Client       *http.Client // 发送到默认地址
+	TargetClient *http.Client // 发送到订阅配置的地址
+}
+
+// NewWebhookDriver 创建 Webhook 驱动
+func NewWebhookDriver(url, secret string) *WebhookDriver {
+	return &WebhookDriver{
+		URL:          url,
+		Secret:       secret,
+		Client:       &http.Client{Timeout: 10 * time.Second},
+		TargetClient: newPublicClient(10 * time.Second),
+	}
+}
+
+// newPublicClient 创建只允许连接公网地址的HTTP客户端
+// 在域名解析后的建立连接阶段检查IP，重定向和DNS重绑定同样受限；不使用环境变量中的代理
+func newPublicClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("%w: %s", ErrForbiddenTarget, host)
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+		},
+	}
+}
+
+// isPublicIP 判断是否为公网地址，排除本机、内网、链路本地、组播和未指定地址
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	// 100.64.0.0/10 运营商级NAT地址
+	if ip4 := ip.To4(); ip4 != nil && ip4[0] == 100 && ip4[1]&0xc0 == 64 {
+		return false
+	}
+	return true
+}
+
+// webhookPayload Webhook 请求体
+type webhookPayload struct {
+	Event   string                 `json:"event"`
+	UserID  uint                   `json:"user_id"`
+	Subject string                 `json:"subject"`
+	Body    string                 `json:"body"`
+	Data    map[string]interface{} `json:"data"`
+	SentAt  time.Time              `json:"sent_at"`
+}
+
+// Send 发送 Webhook 请求，非2xx响应视为失败
+func (d *WebhookDriver) Send(to Recipient, msg Message) error {
+	target, client := to.Target, d.TargetClient
+	if target == "" {
+		target, client = d.URL, d.Client
+	}
+	if target == "" {
+		return ErrNoTarget
+	}
+	if to.Target != "" {
+		if u, err := url.Parse(target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: %s", ErrForbiddenTarget, target)
+		}
+	}
+
+	body, err := json.Marshal(webhookPayload{
+		Event:   string(msg.Event),
+		UserID:  to.UserID,
+		Subject: msg.Subject,
+		Body:    msg.Body,
+		Data:    msg.Data,
+		SentAt:  time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("序列化Webhook请求失败: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("创建Webhook请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if d.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(d.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	return doRequest(client, req)
+}
+
+// doRequest 发送HTTP请求，非2xx响应返回包含响应内容的错误
+func doRequest(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求 %s 失败: %w", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("请求 %s 返回 %d: %s", req.URL.Host, resp.StatusCode, bytes.TrimSpace(detail))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
@@ -0,0 +1,138 @@
+package notify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"hd_psi/backend/models"
+)
+
+func TestWebhookDriverSendsSignedPayload(t *testing.T) {
+	var got webhookPayload
+	var signature string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write(body)
+		signature = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		if r.Header.Get("X-Signature") != signature {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.Unmarshal(body, &got)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	d := NewWebhookDriver(srv.URL, "secret")
+	err := d.Send(Recipient{UserID: 7}, Message{
+		Event:   models.EventInventoryAlert,
+		Subject: "标题",
+		Body:    "正文",
+		Data:    map[string]interface{}{"store_id": 1},
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if got.Event != string(models.EventInventoryAlert) || got.UserID != 7 || got.Subject != "标题" || got.Body != "正文" {
+		t.Errorf("payload = %+v", got)
+	}
+	if got.Data["store_id"] != float64(1) {
+		t.Errorf("data = %v", got.Data)
+	}
+}
+
+func TestWebhookDriverNon2xxIsError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	err := NewWebhookDriver(srv.URL, "").Send(Recipient{}, Message{})
+	if err == nil || !strings.Contains(err.Error(), "502") || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("err = %v, want 502 with body", err)
+	}
+}
+
+func TestWebhookDriverNoTarget(t *testing.T) {
+	if err := NewWebhookDriver("", "").Send(Recipient{}, Message{}); !errors.Is(err, ErrNoTarget) {
+		t.Fatalf("err = %v, want ErrNoTarget", err)
+	}
+}
+
+func TestWebhookDriverRejectsPrivateSubscriberTarget(t *testing.T) {
+	hit := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hit = true
+	}))
+	defer srv.Close()
+
+	d := NewWebhookDriver("", "")
+	for _, target := range []string{srv.URL, "file:///etc/passwd", "http://"} {
+		if err := d.Send(Recipient{Target: target}, Message{}); !errors.Is(err, ErrForbiddenTarget) {
+			t.Errorf("Send(%q) err = %v, want ErrForbiddenTarget", target, err)
+		}
+	}
+	if hit {
+		t.Error("request reached loopback server")
+	}
+}
+
+func TestWebhookDriverDefaultURLIsTrusted(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	// 部署配置的默认地址可以是内网地址
+	if err := NewWebhookDriver(srv.URL, "").Send(Recipient{}, Message{}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+}
+
+func TestWebhookDriverSubscriberTargetUsesTargetClient(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	d := NewWebhookDriver("http://default.invalid", "")
+	d.TargetClient = srv.Client()
+	if err := d.Send(Recipient{Target: srv.URL}, Message{}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if !called {
+		t.Error("subscriber target was not called")
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	cases := map[string]bool{
+		"8.8.8.8":         true,
+		"2001:4860::8888": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fe80::1":         false,
+		"fd00::1":         false,
+		"::ffff:10.0.0.1": false,
+	}
+	for addr, want := range cases {
+		if got := isPublicIP(net.ParseIP(addr)); got != want {
+			t.Errorf("isPublicIP(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
@@ -2,14 +2,16 @@ package routes
 
 import (
 	"hd_psi/backend/controllers"
+	"hd_psi/backend/media"
 	"hd_psi/backend/middleware"
+	"hd_psi/backend/scheduler"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 // RegisterRoutes 注册所有路由
-func RegisterRoutes(r *gin.Engine, db *gorm.DB) {
+func RegisterRoutes(r *gin.Engine, db *gorm.DB, sched *scheduler.Scheduler, store media.Storage) {
 	// 认证路由 - 不需要认证
 	authController := controllers.NewAuthController(db)
 	// 认证路由
@@ -39,7 +41,8 @@ func RegisterRoutes(r *gin.Engine, db *gorm.DB) {
 		apiAuth.PUT("/change-password", authController.ChangePassword)
 
 		// 商品管理路由
-		productController := controllers.NewProductController(db)
+		productController := controllers.NewProductController(db, store)
+		mediaController := controllers.NewMediaController(db, store)
 		productGroup := apiAuth.Group("/products")
 		{
 			productGroup.GET("", productController.ListProducts)
@@ -47,13 +50,90 @@ func RegisterRoutes(r *gin.Engine, db *gorm.DB) {
 			productGroup.POST("", middleware.RoleAuth("admin", "manager"), productController.CreateProduct)
 			productGroup.PUT("/:id", middleware.RoleAuth("admin", "manager"), productController.UpdateProduct)
 			productGroup.DELETE("/:id", middleware.RoleAuth("admin"), productController.DeleteProduct)
+			productGroup.GET("/:id/images", mediaController.ListProductImages)
+			productGroup.POST("/:id/images", middleware.RoleAuth("admin", "manager"), mediaController.UploadProductImages)
+			productGroup.PUT("/:id/images/order", middleware.RoleAuth("admin", "manager"), mediaController.ReorderProductImages)
+			productGroup.DELETE("/:id/images/:imageId", middleware.RoleAuth("admin", "manager"), mediaController.DeleteProductImage)
+		}
+
+		// 上传文件访问，沿用存储的 /uploads 地址，需登录；图片标签无法设置请求头，可用 token 查询参数传递令牌
+		r.GET("/uploads/*key", middleware.JWTAuthWithQuery(), mediaController.ServeUpload)
+
+		// 商品类别路由
+		categoryController := controllers.NewCategoryController(db)
+		categoryGroup := apiAuth.Group("/categories")
+		{
+			categoryGroup.GET("", categoryController.ListCategories)
+			categoryGroup.GET("/stock", middleware.RoleAuth("admin", "manager"), categoryController.GetCategoryStock)
+			categoryGroup.GET("/:id", categoryController.GetCategory)
+			categoryGroup.POST("", middleware.RoleAuth("admin", "manager"), categoryController.CreateCategory)
+			categoryGroup.PUT("/:id", middleware.RoleAuth("admin", "manager"), categoryController.UpdateCategory)
+			categoryGroup.DELETE("/:id", middleware.RoleAuth("admin"), categoryController.DeleteCategory)
+		}
+
+		// 价格表路由
+		priceController := controllers.NewPriceController(db)
+		priceGroup := apiAuth.Group("/prices")
+		{
+			priceGroup.GET("", priceController.ListPrices)
+			priceGroup.GET("/resolve", priceController.ResolvePrice)
+			priceGroup.GET("/history", middleware.RoleAuth("admin", "manager"), priceController.ListPriceHistory)
+			priceGroup.POST("", middleware.RoleAuth("admin", "manager"), priceController.CreatePrice)
+			priceGroup.PUT("/:id", middleware.RoleAuth("admin", "manager"), priceController.UpdatePrice)
+			priceGroup.PUT("/:id/end", middleware.RoleAuth("admin", "manager"), priceController.EndPrice)
+		}
+
+		// SKU编码路由
+		skuCodeController := controllers.NewSKUCodeController(db)
+		skuGroup := apiAuth.Group("/sku")
+		{
+			skuGroup.GET("/codes", skuCodeController.ListSKUCodes)
+			skuGroup.POST("/codes", middleware.RoleAuth("admin", "manager"), skuCodeController.CreateSKUCode)
+			skuGroup.PUT("/codes/:id", middleware.RoleAuth("admin", "manager"), skuCodeController.UpdateSKUCode)
+			skuGroup.DELETE("/codes/:id", middleware.RoleAuth("admin"), skuCodeController.DeleteSKUCode)
+			skuGroup.POST("/generate", skuCodeController.GenerateSKU)
+			skuGroup.GET("/parse", skuCodeController.ParseSKU)
+			skuGroup.GET("/nonconforming", middleware.RoleAuth("admin", "manager"), skuCodeController.ListNonconformingSKUs)
+		}
+
+		// 款式管理路由
+		styleController := controllers.NewStyleController(db, store)
+		styleGroup := apiAuth.Group("/styles")
+		{
+			styleGroup.GET("", styleController.ListStyles)
+			styleGroup.GET("/stock", styleController.ListStyleStock)
+			styleGroup.GET("/sales", middleware.RoleAuth("admin", "manager"), styleController.GetStyleSales)
+			styleGroup.GET("/replenishment", middleware.RoleAuth("admin", "manager"), styleController.GetStyleReplenishment)
+			styleGroup.GET("/:id", styleController.GetStyle)
+			styleGroup.GET("/:id/stock", styleController.GetStyleStock)
+			styleGroup.POST("", middleware.RoleAuth("admin", "manager"), styleController.CreateStyle)
+			styleGroup.PUT("/:id", middleware.RoleAuth("admin", "manager"), styleController.UpdateStyle)
+			styleGroup.POST("/:id/variants", middleware.RoleAuth("admin", "manager"), styleController.GenerateVariants)
+			styleGroup.DELETE("/:id", middleware.RoleAuth("admin"), styleController.DeleteStyle)
+			styleGroup.GET("/:id/images", mediaController.ListStyleImages)
+			styleGroup.POST("/:id/images", middleware.RoleAuth("admin", "manager"), mediaController.UploadStyleImages)
+			styleGroup.PUT("/:id/images/order", middleware.RoleAuth("admin", "manager"), mediaController.ReorderStyleImages)
+			styleGroup.DELETE("/:id/images/:imageId", middleware.RoleAuth("admin", "manager"), mediaController.DeleteStyleImage)
 		}
 
 		// 库存管理路由
 		inventoryController := controllers.NewInventoryController(db)
+		quarantineController := controllers.NewQuarantineController(db)
 		inventoryGroup := apiAuth.Group("/inventory")
 		{
 			inventoryGroup.GET("", inventoryController.ListInventories)
+			inventoryGroup.GET("/batches", inventoryController.ListBatches)
+			inventoryGroup.GET("/availability", inventoryController.GetAvailability)
+			inventoryGroup.GET("/as-of", inventoryController.GetInventoryAsOf)
+			inventoryGroup.POST("/snapshots", middleware.RoleAuth("admin"), inventoryController.CreateSnapshots)
+			inventoryGroup.GET("/reconciliation", middleware.RoleAuth("admin", "manager"), inventoryController.GetReconciliation)
+			inventoryGroup.POST("/reconciliation/repair", middleware.RoleAuth("admin"), inventoryController.RepairLedger)
+
+			// 隔离库存
+			inventoryGroup.GET("/quarantine", quarantineController.ListQuarantine)
+			inventoryGroup.POST("/quarantine/release", middleware.RoleAuth("admin", "manager"), quarantineController.ReleaseQuarantine)
+			inventoryGroup.POST("/quarantine/return", middleware.RoleAuth("admin", "manager"), quarantineController.ReturnQuarantineToSupplier)
+
 			inventoryGroup.GET("/:id", inventoryController.GetInventory)
 			inventoryGroup.POST("", middleware.RoleAuth("admin", "manager"), inventoryController.CreateInventory)
 			inventoryGroup.PUT("/:id", middleware.RoleAuth("admin", "manager"), inventoryController.UpdateInventory)
@@ -76,6 +156,8 @@ func RegisterRoutes(r *gin.Engine, db *gorm.DB) {
 		purchaseGroup := apiAuth.Group("/purchases")
 		{
 			purchaseGroup.GET("", purchaseController.ListPurchaseOrders)
+			purchaseGroup.GET("/replenishment", middleware.RoleAuth("admin", "manager"), purchaseController.GetReplenishment)
+			purchaseGroup.POST("/replenishment/drafts", middleware.RoleAuth("admin", "manager"), purchaseController.CreateReplenishmentDrafts)
 			purchaseGroup.GET("/:id", purchaseController.GetPurchaseOrder)
 			purchaseGroup.POST("", middleware.RoleAuth("admin", "manager"), purchaseController.CreatePurchaseOrder)
 			purchaseGroup.PUT("/:id", middleware.RoleAuth("admin", "manager"), purchaseController.UpdatePurchaseOrder)
@@ -123,6 +205,21 @@ func RegisterRoutes(r *gin.Engine, db *gorm.DB) {
 			storeGroup.DELETE("/:id", middleware.RoleAuth("admin"), storeController.DeleteStore)
 		}
 
+		// 调拨管理路由
+		transferController := controllers.NewTransferController(db)
+		transferGroup := apiAuth.Group("/transfers")
+		{
+			transferGroup.GET("", transferController.ListTransfers)
+			transferGroup.GET("/in-transit", transferController.ListInTransit)
+			transferGroup.GET("/:id", transferController.GetTransfer)
+			transferGroup.POST("", middleware.RoleAuth("admin", "manager", "staff"), transferController.CreateTransfer)
+			transferGroup.PUT("/:id/approve", middleware.RoleAuth("admin", "manager"), transferController.ApproveTransfer)
+			transferGroup.PUT("/:id/ship", middleware.RoleAuth("admin", "manager", "staff"), transferController.ShipTransfer)
+			transferGroup.PUT("/:id/receive", middleware.RoleAuth("admin", "manager", "staff"), transferController.ReceiveTransfer)
+			transferGroup.PUT("/:id/reconcile", middleware.RoleAuth("admin", "manager"), transferController.ReconcileTransfer)
+			transferGroup.PUT("/:id/cancel", middleware.RoleAuth("admin", "manager"), transferController.CancelTransfer)
+		}
+
 		// 库存交易路由
 		inventoryTransactionController := controllers.NewInventoryTransactionController(db)
 		transactionGroup := api.Group("/inventory-transactions")
@@ -149,6 +246,7 @@ func RegisterRoutes(r *gin.Engine, db *gorm.DB) {
 		thresholdGroup := api.Group("/inventory-thresholds")
 		{
 			thresholdGroup.GET("", inventoryThresholdController.ListThresholds)
+			thresholdGroup.GET("/explain", middleware.JWTAuth(), inventoryThresholdController.ExplainThreshold)
 			thresholdGroup.GET("/:id", inventoryThresholdController.GetThreshold)
 			thresholdGroup.POST("", middleware.RoleAuth("admin", "manager"), inventoryThresholdController.CreateThreshold)
 			thresholdGroup.PUT("/:id", middleware.RoleAuth("admin", "manager"), inventoryThresholdController.UpdateThreshold)
@@ -157,7 +255,8 @@ func RegisterRoutes(r *gin.Engine, db *gorm.DB) {
 
 		// 库存盘点路由
 		inventoryCheckController := controllers.NewInventoryCheckController(db)
-		checkGroup := api.Group("/inventory-checks")
+		countSessionController := controllers.NewCountSessionController(db)
+		checkGroup := apiAuth.Group("/inventory-checks")
 		{
 			checkGroup.GET("", inventoryCheckController.ListChecks)
 			checkGroup.GET("/:id", inventoryCheckController.GetCheck)
@@ -166,8 +265,77 @@ func RegisterRoutes(r *gin.Engine, db *gorm.DB) {
 			checkGroup.PUT("/:id/complete", middleware.RoleAuth("admin", "manager"), inventoryCheckController.CompleteCheck)
 			checkGroup.PUT("/:id/cancel", middleware.RoleAuth("admin", "manager"), inventoryCheckController.CancelCheck)
 			checkGroup.PUT("/:id/items/:itemId", middleware.RoleAuth("admin", "manager", "staff"), inventoryCheckController.UpdateCheckItem)
+			checkGroup.POST("/:id/items/:itemId/counts", middleware.RoleAuth("admin", "manager", "staff"), inventoryCheckController.SubmitCount)
+			checkGroup.GET("/:id/movements", inventoryCheckController.ListCutoffMovements)
+
+			// 扫码盘点会话
+			checkGroup.POST("/:id/sessions", middleware.RoleAuth("admin", "manager", "staff"), countSessionController.OpenSession)
+			checkGroup.GET("/:id/sessions/:sessionId", countSessionController.GetSession)
+			checkGroup.POST("/:id/sessions/:sessionId/scans", middleware.RoleAuth("admin", "manager", "staff"), countSessionController.Scan)
+			checkGroup.DELETE("/:id/sessions/:sessionId/scans/last", middleware.RoleAuth("admin", "manager", "staff"), countSessionController.UndoLastScan)
+			checkGroup.PUT("/:id/sessions/:sessionId/close", middleware.RoleAuth("admin", "manager", "staff"), countSessionController.CloseSession)
+			checkGroup.GET("/:id/recounts", inventoryCheckController.ListRecountTasks)
+			checkGroup.PUT("/:id/recounts/:taskId", middleware.RoleAuth("admin", "manager", "staff"), inventoryCheckController.CompleteRecountTask)
 			checkGroup.POST("/:id/adjustments", middleware.RoleAuth("admin", "manager"), inventoryCheckController.CreateAdjustment)
 			checkGroup.PUT("/adjustments/:adjustmentId/approve", middleware.RoleAuth("admin", "manager"), inventoryCheckController.ApproveAdjustment)
+			checkGroup.PUT("/adjustments/bulk", middleware.RoleAuth("admin", "manager"), inventoryCheckController.BulkReviewAdjustments)
+			checkGroup.GET("/:id/variance", middleware.RoleAuth("admin", "manager"), inventoryCheckController.GetVariance)
+			checkGroup.POST("/:id/adjustments/generate", middleware.RoleAuth("admin", "manager"), inventoryCheckController.GenerateAdjustments)
+		}
+
+		// 循环盘点路由
+		cycleCountController := controllers.NewCycleCountController(db)
+		cycleCountGroup := apiAuth.Group("/cycle-counts")
+		{
+			cycleCountGroup.GET("/classes", cycleCountController.ListProductClasses)
+			cycleCountGroup.POST("/classes/classify", middleware.RoleAuth("admin", "manager"), cycleCountController.ClassifyProducts)
+			cycleCountGroup.POST("/plan", middleware.RoleAuth("admin", "manager"), cycleCountController.PlanCycleCounts)
+			cycleCountGroup.GET("/coverage", cycleCountController.GetCycleCoverage)
+		}
+
+		// 报损管理路由
+		damageController := controllers.NewDamageController(db, store)
+		damageGroup := apiAuth.Group("/damage-reports")
+		{
+			damageGroup.GET("", damageController.ListDamageReports)
+			damageGroup.GET("/:id", damageController.GetDamageReport)
+			damageGroup.POST("", middleware.RoleAuth("admin", "manager", "staff"), damageController.CreateDamageReport)
+			damageGroup.POST("/:id/photo", middleware.RoleAuth("admin", "manager", "staff"), damageController.UploadDamagePhoto)
+			damageGroup.PUT("/:id/approve", middleware.RoleAuth("admin", "manager"), damageController.ApproveDamageReport)
+			damageGroup.PUT("/:id/reject", middleware.RoleAuth("admin", "manager"), damageController.RejectDamageReport)
+		}
+
+		// 报表路由
+		reportController := controllers.NewReportController(db)
+		reportGroup := apiAuth.Group("/reports")
+		{
+			reportGroup.GET("/inventory-valuation", middleware.RoleAuth("admin", "manager"), reportController.GetInventoryValuation)
+			reportGroup.GET("/cogs", middleware.RoleAuth("admin", "manager"), reportController.GetCOGS)
+			reportGroup.GET("/shrinkage", middleware.RoleAuth("admin", "manager"), reportController.GetShrinkage)
+		}
+
+		// 通知路由
+		notificationController := controllers.NewNotificationController(db)
+		notificationGroup := apiAuth.Group("/notifications")
+		{
+			notificationGroup.GET("", notificationController.ListNotifications)
+			notificationGroup.PUT("/read-all", notificationController.MarkAllNotificationsRead)
+			notificationGroup.PUT("/:id/read", notificationController.MarkNotificationRead)
+			notificationGroup.GET("/subscriptions", notificationController.ListSubscriptions)
+			notificationGroup.PUT("/subscriptions", notificationController.UpdateSubscriptions)
+			notificationGroup.GET("/deliveries", middleware.RoleAuth("admin"), notificationController.ListDeliveries)
+		}
+
+		// 定时任务路由
+		schedulerController := controllers.NewSchedulerController(db, sched)
+		schedulerGroup := apiAuth.Group("/scheduler")
+		{
+			schedulerGroup.GET("/jobs", middleware.RoleAuth("admin"), schedulerController.ListJobs)
+			schedulerGroup.GET("/runs", middleware.RoleAuth("admin"), schedulerController.ListJobRuns)
+			schedulerGroup.POST("/jobs/:id/trigger", middleware.RoleAuth("admin"), schedulerController.TriggerJob)
+			schedulerGroup.PUT("/jobs/:id/pause", middleware.RoleAuth("admin"), schedulerController.PauseJob)
+			schedulerGroup.PUT("/jobs/:id/resume", middleware.RoleAuth("admin"), schedulerController.ResumeJob)
+			schedulerGroup.PUT("/jobs/:id", middleware.RoleAuth("admin"), schedulerController.UpdateJob)
 		}
 
 		// 销售管理路由
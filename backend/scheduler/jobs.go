@@ -0,0 +1,164 @@
+package scheduler
+
+import (
+	"fmt"
+	"hd_psi/backend/media"
+	"hd_psi/backend/models"
+	"hd_psi/backend/notify"
+	"hd_psi/backend/services"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RegisterDefaultJobs 注册系统内置的定时任务
+func RegisterDefaultJobs(s *Scheduler, dispatcher *notify.Dispatcher, store media.Storage) error {
+	jobs := []struct {
+		name, description, cron string
+		fn                      JobFunc
+	}{
+		{"reservation_expiry", "释放过期的线上订单库存预留并取消超时未支付订单", "* * * * *", expireReservations},
+		{"inventory_alerts", "全量评估库存预警", "0 * * * *", evaluateAlerts},
+		{"member_levels", "按累计消费金额重新计算会员等级", "0 3 * * *", recalculateMemberLevels},
+		{"inventory_snapshot", "生成前一天的库存快照", "5 0 * * *", buildSnapshot},
+		{"daily_sales_report", "汇总前一天各店铺销售并发送销售日报", "0 7 * * *", sendDailySalesReport},
+		{"abc_classification", "按近90天销售额重新计算商品ABC分类", "0 2 1 * *", classifyProducts},
+		{"cycle_count_plan", "按ABC分类生成当月循环盘点抽盘单", "30 2 1 * *", planCycleCounts},
+		{"notification_dispatch", "分发待发送的通知", "* * * * *", dispatchNotifications(dispatcher)},
+		{"media_cleanup", "清理已删除商品和款式遗留的图片", "0 4 * * *", cleanupMedia(store)},
+	}
+
+	for _, j := range jobs {
+		if err := s.Register(j.name, j.description, j.cron, j.fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expireReservations 释放过期的库存预留
+func expireReservations(db *gorm.DB) (string, error) {
+	count, err := services.ExpireReservations(db, time.Now())
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("取消超时未支付订单 %d 个", count), nil
+}
+
+// evaluateAlerts 全量评估库存预警
+func evaluateAlerts(db *gorm.DB) (string, error) {
+	changes, err := services.EvaluateInventoryAlerts(db, 0)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("新建预警 %d 条，更新 %d 条，自动解决 %d 条", changes.Created, changes.Updated, changes.Resolved), nil
+}
+
+// recalculateMemberLevels 重新计算所有会员等级，只更新等级有变化的会员
+func recalculateMemberLevels(db *gorm.DB) (string, error) {
+	changed := 0
+	var members []models.Member
+	result := db.Select("id, level, total_spent").FindInBatches(&members, 500, func(tx *gorm.DB, batch int) error {
+		for i := range members {
+			level := members[i].CalculateLevel()
+			if members[i].Level == level {
+				continue
+			}
+			if err := db.Model(&models.Member{}).Where("id = ?", members[i].ID).Update("level", level).Error; err != nil {
+				return err
+			}
+			changed++
+		}
+		return nil
+	})
+	if result.Error != nil {
+		return "", result.Error
+	}
+	return fmt.Sprintf("更新会员等级 %d 个", changed), nil
+}
+
+// buildSnapshot 生成前一天的库存快照
+func buildSnapshot(db *gorm.DB) (string, error) {
+	day := time.Now().AddDate(0, 0, -1)
+	count, err := services.BuildDailySnapshot(db, day)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("生成 %s 库存快照 %d 条", day.Format("2006-01-02"), count), nil
+}
+
+// sendDailySalesReport 汇总前一天各店铺的销售，每个店铺写入一条销售日报通知
+func sendDailySalesReport(db *gorm.DB) (string, error) {
+	day := time.Now().AddDate(0, 0, -1)
+	summaries, err := services.DailySalesSummary(db, day)
+	if err != nil {
+		return "", err
+	}
+
+	date := day.Format("2006-01-02")
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, s := range summaries {
+			storeID := s.StoreID
+			if err := notify.Enqueue(tx, models.EventDailySalesReport, &storeID, map[string]interface{}{
+				"store_id":      s.StoreID,
+				"date":          date,
+				"order_count":   s.OrderCount,
+				"sales_amount":  fmt.Sprintf("%.2f", s.SalesAmount),
+				"sold_quantity": s.SoldQuantity,
+				"cogs":          fmt.Sprintf("%.2f", s.COGS),
+				"gross_profit":  fmt.Sprintf("%.2f", s.GrossProfit),
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("生成 %s 销售日报 %d 份", date, len(summaries)), nil
+}
+
+// classifyProducts 重新计算所有店铺的商品ABC分类
+func classifyProducts(db *gorm.DB) (string, error) {
+	count, err := services.ClassifyProducts(db, 0, services.DefaultABCDays)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("分类商品 %d 个", count), nil
+}
+
+// planCycleCounts 生成当月循环盘点抽盘单
+func planCycleCounts(db *gorm.DB) (string, error) {
+	plans, err := services.PlanCycleCounts(db, time.Now(), 0, 0)
+	if err != nil {
+		return "", err
+	}
+	items := 0
+	for _, plan := range plans {
+		items += plan.Items
+	}
+	return fmt.Sprintf("生成循环盘点单 %d 个，共 %d 个商品", len(plans), items), nil
+}
+
+// dispatchNotifications 分发待发送的通知
+func dispatchNotifications(dispatcher *notify.Dispatcher) JobFunc {
+	return func(db *gorm.DB) (string, error) {
+		result, err := dispatcher.Dispatch()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("展开通知事件 %d 个，发送成功 %d 条，发送失败 %d 条", result.Events, result.Sent, result.Failed), nil
+	}
+}
+
+// cleanupMedia 清理所属商品或款式已删除的图片
+func cleanupMedia(store media.Storage) JobFunc {
+	return func(db *gorm.DB) (string, error) {
+		count, err := media.CleanupOrphans(db, store)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("清理图片 %d 张", count), nil
+	}
+}
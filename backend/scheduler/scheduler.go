@@ -0,0 +1,296 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"hd_psi/backend/models"
+	"log"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrJobNotFound 定时任务不存在或未在代码中注册
+	ErrJobNotFound = errors.New("定时任务不存在")
+	// ErrJobLocked 定时任务正在执行
+	ErrJobLocked = errors.New("定时任务正在执行")
+)
+
+// JobFunc 定时任务执行函数，返回执行结果说明
+type JobFunc func(db *gorm.DB) (string, error)
+
+// job 代码中注册的任务
+type job struct {
+	description string
+	cron        string // 默认执行计划，数据库中已有记录时以数据库为准
+	fn          JobFunc
+}
+
+// Scheduler 进程内定时任务调度器
+// 执行计划保存在数据库中，多实例部署时通过数据库行锁保证同一任务只由一个实例执行
+type Scheduler struct {
+	db       *gorm.DB
+	instance string        // 当前实例标识
+	lease    time.Duration // 执行锁有效期，任务执行时间不应超过该时长
+	jobs     map[string]*job
+}
+
+// New 创建调度器
+func New(db *gorm.DB) *Scheduler {
+	host, _ := os.Hostname()
+	return &Scheduler{
+		db:       db,
+		instance: fmt.Sprintf("%s-%d", host, os.Getpid()),
+		lease:    30 * time.Minute,
+		jobs:     make(map[string]*job),
+	}
+}
+
+// Register 注册定时任务，cron 为默认执行计划
+func (s *Scheduler) Register(name, description, cron string, fn JobFunc) error {
+	if _, err := ParseCron(cron); err != nil {
+		return fmt.Errorf("任务 %s 执行计划无效: %w", name, err)
+	}
+	s.jobs[name] = &job{description: description, cron: cron, fn: fn}
+	return nil
+}
+
+// Start 同步任务定义到数据库，并按 interval 间隔检查到期任务
+func (s *Scheduler) Start(interval time.Duration) error {
+	if err := s.syncJobs(); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			s.runDue(now)
+		}
+	}()
+	return nil
+}
+
+// syncJobs 为新注册的任务创建数据库记录，已有记录保留其执行计划和暂停状态
+func (s *Scheduler) syncJobs() error {
+	now := time.Now()
+	for name, j := range s.jobs {
+		var record models.ScheduledJob
+		result := s.db.Where("name = ?", name).Limit(1).Find(&record)
+		if result.Error != nil {
+			return fmt.Errorf("查询定时任务失败: %w", result.Error)
+		}
+
+		if result.RowsAffected == 0 {
+			schedule, _ := ParseCron(j.cron)
+			next := schedule.Next(now)
+			record = models.ScheduledJob{
+				Name:        name,
+				Description: j.description,
+				Cron:        j.cron,
+				NextRunAt:   &next,
+			}
+			if err := s.db.Create(&record).Error; err != nil {
+				return fmt.Errorf("创建定时任务失败: %w", err)
+			}
+			continue
+		}
+
+		updates := map[string]interface{}{"description": j.description}
+		if record.NextRunAt == nil {
+			if schedule, err := ParseCron(record.Cron); err == nil {
+				updates["next_run_at"] = schedule.Next(now)
+			}
+		}
+		if err := s.db.Model(&record).Updates(updates).Error; err != nil {
+			return fmt.Errorf("更新定时任务失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// runDue 执行已到期且未暂停的任务
+func (s *Scheduler) runDue(now time.Time) {
+	var due []models.ScheduledJob
+	if err := s.db.Where("paused = ? AND next_run_at <= ?", false, now).Find(&due).Error; err != nil {
+		log.Printf("查询到期定时任务失败: %v", err)
+		return
+	}
+
+	for i := range due {
+		record := due[i]
+		if _, ok := s.jobs[record.Name]; !ok {
+			continue
+		}
+		// 以下次执行时间作为条件加锁，其他实例已执行过的任务不会重复执行
+		if !s.acquire(&record, now, record.NextRunAt) {
+			continue
+		}
+		run, err := s.startRun(&record, "schedule")
+		if err != nil {
+			log.Printf("创建任务 %s 执行记录失败: %v", record.Name, err)
+			s.release(&record, false)
+			continue
+		}
+		go s.execute(&record, run, true)
+	}
+}
+
+// Trigger 立即执行任务，不影响下次计划执行时间
+func (s *Scheduler) Trigger(id uint) (*models.JobRun, error) {
+	var record models.ScheduledJob
+	if err := s.db.First(&record, id).Error; err != nil {
+		return nil, ErrJobNotFound
+	}
+	if _, ok := s.jobs[record.Name]; !ok {
+		return nil, ErrJobNotFound
+	}
+
+	if !s.acquire(&record, time.Now(), nil) {
+		return nil, ErrJobLocked
+	}
+	run, err := s.startRun(&record, "manual")
+	if err != nil {
+		s.release(&record, false)
+		return nil, err
+	}
+
+	go s.execute(&record, run, false)
+	return run, nil
+}
+
+// SetPaused 暂停或恢复任务，恢复时从当前时间重新计算下次执行时间
+func (s *Scheduler) SetPaused(id uint, paused bool) (*models.ScheduledJob, error) {
+	var record models.ScheduledJob
+	if err := s.db.First(&record, id).Error; err != nil {
+		return nil, ErrJobNotFound
+	}
+
+	record.Paused = paused
+	if !paused {
+		schedule, err := ParseCron(record.Cron)
+		if err != nil {
+			return nil, err
+		}
+		next := schedule.Next(time.Now())
+		record.NextRunAt = &next
+	}
+
+	if err := s.db.Model(&record).Select("paused", "next_run_at").Updates(&record).Error; err != nil {
+		return nil, fmt.Errorf("更新定时任务失败: %w", err)
+	}
+	return &record, nil
+}
+
+// UpdateCron 修改任务执行计划
+func (s *Scheduler) UpdateCron(id uint, cron string) (*models.ScheduledJob, error) {
+	schedule, err := ParseCron(cron)
+	if err != nil {
+		return nil, err
+	}
+
+	var record models.ScheduledJob
+	if err := s.db.First(&record, id).Error; err != nil {
+		return nil, ErrJobNotFound
+	}
+
+	next := schedule.Next(time.Now())
+	record.Cron = cron
+	record.NextRunAt = &next
+	if err := s.db.Model(&record).Select("cron", "next_run_at").Updates(&record).Error; err != nil {
+		return nil, fmt.Errorf("更新定时任务失败: %w", err)
+	}
+	return &record, nil
+}
+
+// acquire 以条件更新抢占任务执行锁，只有一个实例能更新成功
+// nextRunAt 不为空时还要求下次执行时间未被其他实例推进
+func (s *Scheduler) acquire(record *models.ScheduledJob, now time.Time, nextRunAt *time.Time) bool {
+	until := now.Add(s.lease)
+	query := s.db.Model(&models.ScheduledJob{}).
+		Where("id = ? AND (locked_until IS NULL OR locked_until < ?)", record.ID, now)
+	if nextRunAt != nil {
+		query = query.Where("next_run_at = ?", *nextRunAt)
+	}
+
+	result := query.Updates(map[string]interface{}{
+		"locked_by":    s.instance,
+		"locked_until": until,
+	})
+	if result.Error != nil {
+		log.Printf("获取任务 %s 执行锁失败: %v", record.Name, result.Error)
+		return false
+	}
+	return result.RowsAffected == 1
+}
+
+// release 释放执行锁，scheduled 为true时同时推进下次执行时间
+func (s *Scheduler) release(record *models.ScheduledJob, scheduled bool) {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"locked_by":    "",
+		"locked_until": nil,
+		"last_run_at":  now,
+	}
+	if scheduled {
+		if schedule, err := ParseCron(record.Cron); err == nil {
+			updates["next_run_at"] = schedule.Next(now)
+		}
+	}
+
+	if err := s.db.Model(&models.ScheduledJob{}).
+		Where("id = ? AND locked_by = ?", record.ID, s.instance).
+		Updates(updates).Error; err != nil {
+		log.Printf("释放任务 %s 执行锁失败: %v", record.Name, err)
+	}
+}
+
+// startRun 创建执行中的执行记录
+func (s *Scheduler) startRun(record *models.ScheduledJob, trigger string) (*models.JobRun, error) {
+	run := models.JobRun{
+		JobID:     record.ID,
+		JobName:   record.Name,
+		Trigger:   trigger,
+		Instance:  s.instance,
+		Status:    models.JobRunning,
+		StartedAt: time.Now(),
+	}
+	if err := s.db.Create(&run).Error; err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// execute 执行任务并记录结果，任务panic时记为失败
+func (s *Scheduler) execute(record *models.ScheduledJob, run *models.JobRun, scheduled bool) {
+	defer s.release(record, scheduled)
+
+	message, err := func() (message string, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("任务异常退出: %v", r)
+			}
+		}()
+		return s.jobs[record.Name].fn(s.db)
+	}()
+
+	finished := time.Now()
+	run.FinishedAt = &finished
+	run.DurationMs = finished.Sub(run.StartedAt).Milliseconds()
+	run.Status = models.JobSucceeded
+	run.Message = message
+	if err != nil {
+		run.Status = models.JobFailed
+		run.Message = err.Error()
+		log.Printf("定时任务 %s 执行失败: %v", record.Name, err)
+	}
+	if message := []rune(run.Message); len(message) > 1000 {
+		run.Message = string(message[:1000])
+	}
+
+	if err := s.db.Save(run).Error; err != nil {
+		log.Printf("保存任务 %s 执行记录失败: %v", record.Name, err)
+	}
+}
@@ -0,0 +1,623 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"hd_psi/backend/models"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrCategoryNotFound 类别不存在
+	ErrCategoryNotFound = errors.New("类别不存在")
+	// ErrInvalidCategory 类别名称或层级无效
+	ErrInvalidCategory = errors.New("类别无效")
+	// ErrCategoryExists 同一上级类别下已有同名类别
+	ErrCategoryExists = errors.New("同级类别名称已存在")
+	// ErrCategoryInUse 类别下仍有子类别、商品或阈值设置，不能删除
+	ErrCategoryInUse = errors.New("类别仍在使用")
+)
+
+// CategoryNode 类别树节点
+type CategoryNode struct {
+	models.Category
+	Children []*CategoryNode `json:"children"`
+}
+
+// NormalizeCategoryName 去除类别名称首尾空白并合并连续空白，如“外套 ”与“外套”视为同一类别
+func NormalizeCategoryName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// splitCategoryPath 拆分“女装/外套/羽绒服”或“女装 > 外套 > 羽绒服”形式的类别路径
+func splitCategoryPath(path string) []string {
+	var names []string
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '>' }) {
+		if name := NormalizeCategoryName(part); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// FindCategory 按ID查询类别
+func FindCategory(db *gorm.DB, id uint) (*models.Category, error) {
+	var category models.Category
+	result := db.Limit(1).Find(&category, id)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("%w: %d", ErrCategoryNotFound, id)
+	}
+	return &category, nil
+}
+
+// CategoryTree 返回完整类别树，同级按排序值和名称排列
+func CategoryTree(db *gorm.DB) ([]*CategoryNode, error) {
+	var categories []models.Category
+	if err := db.Order("depth, sort_order, name, id").Find(&categories).Error; err != nil {
+		return nil, fmt.Errorf("查询类别失败: %w", err)
+	}
+
+	roots := []*CategoryNode{}
+	nodes := make(map[uint]*CategoryNode, len(categories))
+	for _, category := range categories {
+		node := &CategoryNode{Category: category, Children: []*CategoryNode{}}
+		nodes[category.ID] = node
+		// 按层级排序，上级节点总是先于子节点加入
+		if category.ParentID != nil {
+			if parent, ok := nodes[*category.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+	return roots, nil
+}
+
+// CategoryAncestorIDs 返回类别及其全部上级类别的ID，从根节点开始
+func CategoryAncestorIDs(category *models.Category) []uint {
+	var ids []uint
+	for _, part := range strings.Split(strings.Trim(category.Path, "/"), "/") {
+		if id, err := strconv.ParseUint(part, 10, 32); err == nil {
+			ids = append(ids, uint(id))
+		}
+	}
+	return ids
+}
+
+// ApplyCategoryFilter 只保留属于类别或其任一子类别的记录，column 为记录中的类别ID字段
+// 指定多个类别时属于其中任一类别即可
+func ApplyCategoryFilter(query *gorm.DB, column string, categoryIDs ...uint) *gorm.DB {
+	return query.Where(column+" IN (SELECT sub.id FROM categories sub "+
+		"JOIN categories root ON sub.path LIKE CONCAT(root.path, '%') WHERE root.id IN ?)", categoryIDs)
+}
+
+// CategoryNamePaths 返回类别的名称路径，如“女装 > 外套 > 羽绒服”，不存在的类别不包含在结果中
+func CategoryNamePaths(db *gorm.DB, ids []uint) (map[uint]string, error) {
+	paths := make(map[uint]string, len(ids))
+	if len(ids) == 0 {
+		return paths, nil
+	}
+
+	var categories []models.Category
+	if err := db.Where("id IN ?", ids).Find(&categories).Error; err != nil {
+		return nil, fmt.Errorf("查询类别失败: %w", err)
+	}
+	var ancestorIDs []uint
+	for i := range categories {
+		ancestorIDs = append(ancestorIDs, CategoryAncestorIDs(&categories[i])...)
+	}
+	var ancestors []models.Category
+	if err := db.Select("id, name").Where("id IN ?", ancestorIDs).Find(&ancestors).Error; err != nil {
+		return nil, fmt.Errorf("查询类别失败: %w", err)
+	}
+	names := make(map[uint]string, len(ancestors))
+	for _, ancestor := range ancestors {
+		names[ancestor.ID] = ancestor.Name
+	}
+
+	for i := range categories {
+		var parts []string
+		for _, id := range CategoryAncestorIDs(&categories[i]) {
+			parts = append(parts, names[id])
+		}
+		paths[categories[i].ID] = strings.Join(parts, " > ")
+	}
+	return paths, nil
+}
+
+// CreateCategory 新建类别，parentID 为空时新建根类别
+func CreateCategory(tx *gorm.DB, parentID *uint, name string, sortOrder int) (*models.Category, error) {
+	name, err := validCategoryName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	category := models.Category{ParentID: parentID, Name: name, SortOrder: sortOrder, Path: "/", Depth: 1}
+	if parentID != nil {
+		parent, err := FindCategory(tx, *parentID)
+		if err != nil {
+			return nil, err
+		}
+		category.Path, category.Depth = parent.Path, parent.Depth+1
+	}
+	if err := checkSiblingName(tx, parentID, name, 0); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Create(&category).Error; err != nil {
+		if isDuplicateKey(tx, err) {
+			return nil, fmt.Errorf("%w: %s", ErrCategoryExists, name)
+		}
+		return nil, fmt.Errorf("保存类别失败: %w", err)
+	}
+	// 路径包含自身ID，创建后才能确定
+	category.Path += strconv.FormatUint(uint64(category.ID), 10) + "/"
+	if err := tx.Model(&category).Update("path", category.Path).Error; err != nil {
+		return nil, fmt.Errorf("保存类别失败: %w", err)
+	}
+	return &category, nil
+}
+
+// UpdateCategory 修改类别名称、上级类别和排序值
+// 移动类别时同步更新整棵子树的路径和层级，重命名时同步更新商品的类别名称
+func UpdateCategory(tx *gorm.DB, category *models.Category, name string, parentID *uint, sortOrder int) error {
+	name, err := validCategoryName(name)
+	if err != nil {
+		return err
+	}
+	if err := checkSiblingName(tx, parentID, name, category.ID); err != nil {
+		return err
+	}
+
+	oldPath, oldName := category.Path, category.Name
+	if !sameParent(category.ParentID, parentID) {
+		path, depth := "/", 1
+		if parentID != nil {
+			parent, err := FindCategory(tx, *parentID)
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(parent.Path, oldPath) {
+				return fmt.Errorf("%w: 不能移动到自身或子类别下", ErrInvalidCategory)
+			}
+			path, depth = parent.Path, parent.Depth+1
+		}
+		path += strconv.FormatUint(uint64(category.ID), 10) + "/"
+
+		if err := tx.Model(&models.Category{}).Where("path LIKE ?", oldPath+"%").Updates(map[string]interface{}{
+			"path":  gorm.Expr("CONCAT(?, SUBSTRING(path, ?))", path, len(oldPath)+1),
+			"depth": gorm.Expr("depth + ?", depth-category.Depth),
+		}).Error; err != nil {
+			return fmt.Errorf("移动类别失败: %w", err)
+		}
+		category.ParentID, category.Path, category.Depth = parentID, path, depth
+	}
+
+	category.Name = name
+	category.SortOrder = sortOrder
+	if err := tx.Model(category).Updates(map[string]interface{}{
+		"parent_id":  category.ParentID,
+		"name":       category.Name,
+		"sort_order": category.SortOrder,
+	}).Error; err != nil {
+		if isDuplicateKey(tx, err) {
+			return fmt.Errorf("%w: %s", ErrCategoryExists, name)
+		}
+		return fmt.Errorf("保存类别失败: %w", err)
+	}
+
+	if name != oldName {
+		if err := tx.Model(&models.Product{}).Where("category_id = ?", category.ID).Update("category", name).Error; err != nil {
+			return fmt.Errorf("同步商品类别名称失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// DeleteCategory 删除没有子类别、商品和阈值设置的类别，历史预警保留但不再关联类别
+func DeleteCategory(tx *gorm.DB, category *models.Category) error {
+	checks := []struct {
+		model interface{}
+		where string
+		what  string
+	}{
+		{&models.Category{}, "parent_id = ?", "子类别"},
+		{&models.Product{}, "category_id = ?", "商品"},
+		{&models.InventoryThreshold{}, "category_id = ?", "库存阈值设置"},
+	}
+	for _, check := range checks {
+		var count int64
+		if err := tx.Model(check.model).Where(check.where, category.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("%w: 类别下还有 %d 个%s", ErrCategoryInUse, count, check.what)
+		}
+	}
+
+	if err := tx.Model(&models.InventoryAlert{}).Where("category_id = ?", category.ID).Update("category_id", nil).Error; err != nil {
+		return err
+	}
+	return tx.Delete(category).Error
+}
+
+// FindCategoryPath 按路径查找已有类别，路径只有一级时按名称在整棵树中查找
+func FindCategoryPath(db *gorm.DB, path string) (*models.Category, error) {
+	return resolveCategoryPath(db, path, false)
+}
+
+// EnsureCategoryPath 按路径查找类别，路径中不存在的类别逐级新建
+// 路径只有一级时按名称在整棵树中查找，找不到时新建为根类别
+func EnsureCategoryPath(tx *gorm.DB, path string) (*models.Category, error) {
+	return resolveCategoryPath(tx, path, true)
+}
+
+func resolveCategoryPath(db *gorm.DB, path string, create bool) (*models.Category, error) {
+	names := splitCategoryPath(path)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%w: 类别不能为空", ErrInvalidCategory)
+	}
+
+	if len(names) == 1 {
+		var matches []models.Category
+		if err := db.Where("name = ?", names[0]).Limit(2).Find(&matches).Error; err != nil {
+			return nil, err
+		}
+		switch {
+		case len(matches) == 1:
+			return &matches[0], nil
+		case len(matches) > 1:
+			return nil, fmt.Errorf("%w: 类别名称 %s 不唯一，请使用完整路径（如 女装/外套）", ErrInvalidCategory, names[0])
+		}
+	}
+
+	var parent *models.Category
+	for _, name := range names {
+		query := db.Where("name = ?", name)
+		if parent == nil {
+			query = query.Where("parent_id IS NULL")
+		} else {
+			query = query.Where("parent_id = ?", parent.ID)
+		}
+		var category models.Category
+		result := query.Limit(1).Find(&category)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			if !create {
+				return nil, fmt.Errorf("%w: %s", ErrCategoryNotFound, strings.Join(names, "/"))
+			}
+			var parentID *uint
+			if parent != nil {
+				parentID = &parent.ID
+			}
+			created, err := CreateCategory(db, parentID, name, 0)
+			if err != nil {
+				return nil, err
+			}
+			category = *created
+		}
+		parent = &category
+	}
+	return parent, nil
+}
+
+// AssignProductCategory 确定商品所属类别：指定了 CategoryID 时以该类别为准并同步类别名称，
+// 否则按 Category 中的类别名称或路径查找，create 为 true 时自动新建不存在的类别
+// 类别名称是SKU编码字典中的品类时，按字典关联的类别节点确定类别，见 skuCategory
+func AssignProductCategory(db *gorm.DB, product *models.Product, create bool) error {
+	if product.CategoryID != nil {
+		category, err := FindCategory(db, *product.CategoryID)
+		if err != nil {
+			return err
+		}
+		product.Category = category.Name
+		return nil
+	}
+	if NormalizeCategoryName(product.Category) == "" {
+		product.Category = ""
+		return nil
+	}
+
+	category, err := skuCategory(db, product.Category)
+	if err != nil {
+		return err
+	}
+	if category == nil {
+		if category, err = resolveCategoryPath(db, product.Category, create); err != nil {
+			return err
+		}
+	}
+	product.CategoryID = &category.ID
+	product.Category = category.Name
+	return nil
+}
+
+// skuCategory 返回SKU编码字典中品类对应的类别节点，name 不是字典中的品类时返回 nil
+// 品类尚未关联类别节点时按名称查找唯一的同名类别，找不到时新建根类别，并将品类关联到该类别
+func skuCategory(db *gorm.DB, name string) (*models.Category, error) {
+	var entry models.SKUCode
+	result := db.Where("kind = ? AND name = ?", models.SKUCategory, NormalizeCategoryName(name)).Limit(1).Find(&entry)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	if entry.CategoryID != nil {
+		category, err := FindCategory(db, *entry.CategoryID)
+		if !errors.Is(err, ErrCategoryNotFound) {
+			return category, err
+		}
+	}
+
+	category, err := resolveCategoryPath(db, entry.Name, false)
+	if errors.Is(err, ErrCategoryNotFound) {
+		category, err = CreateCategory(db, nil, entry.Name, 0)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Model(&entry).Update("category_id", category.ID).Error; err != nil {
+		return nil, fmt.Errorf("关联品类编码与类别失败: %w", err)
+	}
+	return category, nil
+}
+
+// CategoryStock 类别库存汇总，Quantity 和 Value 包含全部子类别
+type CategoryStock struct {
+	CategoryID uint    `json:"category_id"`
+	ParentID   *uint   `json:"parent_id"`
+	Name       string  `json:"name"`
+	Path       string  `json:"path"`
+	Depth      int     `json:"depth"`
+	OwnQty     int     `json:"own_quantity"` // 直接归属该类别的商品库存
+	Quantity   int     `json:"quantity"`
+	Value      float64 `json:"value"` // 按移动加权平均成本计算的库存价值
+}
+
+// CategoryStockRollup 按类别树逐级汇总库存数量和价值，storeID 为0时不限店铺
+// 返回：
+//   - []CategoryStock: 按类别路径排列的全部类别
+//   - CategoryStock: 未设置类别的商品库存
+//   - error: 查询失败时返回错误
+func CategoryStockRollup(db *gorm.DB, storeID uint) ([]CategoryStock, CategoryStock, error) {
+	var uncategorized CategoryStock
+	var categories []models.Category
+	if err := db.Order("path").Find(&categories).Error; err != nil {
+		return nil, uncategorized, fmt.Errorf("查询类别失败: %w", err)
+	}
+
+	var rows []struct {
+		CategoryID *uint
+		Quantity   int
+		Value      float64
+	}
+	query := db.Table("inventories").
+		Select("products.category_id, SUM(inventories.quantity) AS quantity, SUM(inventories.quantity * inventories.avg_cost) AS value").
+		Joins("JOIN products ON products.id = inventories.product_id")
+	if storeID != 0 {
+		query = query.Where("inventories.store_id = ?", storeID)
+	}
+	if err := query.Group("products.category_id").Scan(&rows).Error; err != nil {
+		return nil, uncategorized, fmt.Errorf("查询库存失败: %w", err)
+	}
+
+	stocks := make([]CategoryStock, len(categories))
+	index := make(map[uint]int, len(categories))
+	for i, category := range categories {
+		stocks[i] = CategoryStock{
+			CategoryID: category.ID,
+			ParentID:   category.ParentID,
+			Name:       category.Name,
+			Path:       category.Path,
+			Depth:      category.Depth,
+		}
+		index[category.ID] = i
+	}
+
+	for _, row := range rows {
+		i, ok := -1, false
+		if row.CategoryID != nil {
+			i, ok = index[*row.CategoryID]
+		}
+		if !ok {
+			uncategorized.Quantity += row.Quantity
+			uncategorized.Value += row.Value
+			continue
+		}
+		stocks[i].OwnQty += row.Quantity
+		// 计入该类别及其全部上级类别
+		for _, id := range CategoryAncestorIDs(&categories[i]) {
+			if j, ok := index[id]; ok {
+				stocks[j].Quantity += row.Quantity
+				stocks[j].Value += row.Value
+			}
+		}
+	}
+	for i := range stocks {
+		stocks[i].Value = roundAmount(stocks[i].Value)
+	}
+	uncategorized.Name = "未分类"
+	uncategorized.Value = roundAmount(uncategorized.Value)
+	return stocks, uncategorized, nil
+}
+
+// MigrateCategories 将启用类别树前的类别文本迁移为类别节点，由 cmd/migrate_categories 执行，可重复执行
+// 原类别文本整体作为一个类别名称，不按 / 或 > 拆分：树中有唯一的同名类别时归入该类别，否则新建根类别
+// 商品和库存阈值按类别文本归类，库存预警按所属商品的类别关联；无法迁移的类别文本记录日志后跳过
+// 阈值和预警表中原有的类别文本字段保留，核对无误后由 DropLegacyCategoryColumns 删除
+// 返回：
+//   - int: 迁移的商品数量
+//   - error: 迁移失败时返回错误
+func MigrateCategories(db *gorm.DB) (int, error) {
+	migrated := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var names []string
+		if err := tx.Model(&models.Product{}).Where("category_id IS NULL AND category <> ''").
+			Distinct().Pluck("category", &names).Error; err != nil {
+			return err
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			category, err := ensureLegacyCategory(tx, name)
+			if errors.Is(err, ErrInvalidCategory) {
+				log.Printf("商品类别 %q 无法迁移: %v", name, err)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			result := tx.Model(&models.Product{}).Where("category_id IS NULL AND category = ?", name).
+				Updates(map[string]interface{}{"category_id": category.ID, "category": category.Name})
+			if result.Error != nil {
+				return result.Error
+			}
+			migrated += int(result.RowsAffected)
+		}
+
+		if tx.Migrator().HasColumn(&models.InventoryThreshold{}, "category") {
+			var thresholds []struct {
+				ID       uint
+				Category string
+			}
+			if err := tx.Table("inventory_thresholds").Select("id, category").
+				Where("category_id = 0 AND category <> ''").Scan(&thresholds).Error; err != nil {
+				return err
+			}
+			for _, t := range thresholds {
+				category, err := ensureLegacyCategory(tx, t.Category)
+				if errors.Is(err, ErrInvalidCategory) {
+					log.Printf("库存阈值 %d 的类别 %q 无法迁移: %v", t.ID, t.Category, err)
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				if err := tx.Table("inventory_thresholds").Where("id = ?", t.ID).Update("category_id", category.ID).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		if tx.Migrator().HasColumn(&models.InventoryAlert{}, "category") {
+			if err := tx.Exec("UPDATE inventory_alerts SET category_id = " +
+				"(SELECT products.category_id FROM products WHERE products.id = inventory_alerts.product_id) " +
+				"WHERE category_id IS NULL").Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return migrated, fmt.Errorf("迁移商品类别失败: %w", err)
+	}
+	return migrated, nil
+}
+
+// DropLegacyCategoryColumns 删除库存阈值和预警表中原有的类别文本字段，迁移结果核对无误后执行
+// 仍有未迁移类别的阈值时不删除
+func DropLegacyCategoryColumns(db *gorm.DB) error {
+	if db.Migrator().HasColumn(&models.InventoryThreshold{}, "category") {
+		var pending int64
+		if err := db.Table("inventory_thresholds").Where("category_id = 0 AND category <> ''").Count(&pending).Error; err != nil {
+			return err
+		}
+		if pending > 0 {
+			return fmt.Errorf("还有 %d 条库存阈值的类别未迁移，请先处理后再删除原类别字段", pending)
+		}
+	}
+	for _, model := range []interface{}{&models.InventoryThreshold{}, &models.InventoryAlert{}} {
+		if db.Migrator().HasColumn(model, "category") {
+			if err := db.Migrator().DropColumn(model, "category"); err != nil {
+				return fmt.Errorf("删除原类别字段失败: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// ensureLegacyCategory 按原类别文本查找类别，找不到时新建根类别
+// 类别名称中不允许的 / 和 > 替换为全角字符，超长部分截断
+func ensureLegacyCategory(tx *gorm.DB, legacy string) (*models.Category, error) {
+	name := strings.NewReplacer("/", "／", ">", "＞").Replace(NormalizeCategoryName(legacy))
+	if runes := []rune(name); len(runes) > 50 {
+		name = strings.TrimSpace(string(runes[:50]))
+	}
+	name, err := validCategoryName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []models.Category
+	if err := tx.Where("name = ?", name).Limit(2).Find(&matches).Error; err != nil {
+		return nil, err
+	}
+	switch len(matches) {
+	case 0:
+		return CreateCategory(tx, nil, name, 0)
+	case 1:
+		return &matches[0], nil
+	}
+	return nil, fmt.Errorf("%w: 类别树中有多个名为 %s 的类别", ErrInvalidCategory, name)
+}
+
+// validCategoryName 规范化并校验类别名称
+func validCategoryName(name string) (string, error) {
+	name = NormalizeCategoryName(name)
+	switch {
+	case name == "":
+		return "", fmt.Errorf("%w: 类别名称不能为空", ErrInvalidCategory)
+	case strings.ContainsAny(name, "/>"):
+		return "", fmt.Errorf("%w: 类别名称不能包含 / 或 >", ErrInvalidCategory)
+	case utf8.RuneCountInString(name) > 50:
+		return "", fmt.Errorf("%w: 类别名称不能超过50个字符", ErrInvalidCategory)
+	}
+	return name, nil
+}
+
+// checkSiblingName 检查同一上级类别下没有同名类别，excludeID 为修改中的类别
+func checkSiblingName(tx *gorm.DB, parentID *uint, name string, excludeID uint) error {
+	query := tx.Model(&models.Category{}).Where("name = ? AND id <> ?", name, excludeID)
+	if parentID == nil {
+		query = query.Where("parent_id IS NULL")
+	} else {
+		query = query.Where("parent_id = ?", *parentID)
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: %s", ErrCategoryExists, name)
+	}
+	return nil
+}
+
+// isDuplicateKey 判断是否为唯一索引冲突
+func isDuplicateKey(db *gorm.DB, err error) bool {
+	if translator, ok := db.Dialector.(gorm.ErrorTranslator); ok {
+		err = translator.Translate(err)
+	}
+	return errors.Is(err, gorm.ErrDuplicatedKey)
+}
+
+// sameParent 判断两个上级类别ID是否相同
+func sameParent(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"hd_psi/backend/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrCheckNotInProcess 盘点单不在盘点中
+	ErrCheckNotInProcess = errors.New("盘点单不在盘点中")
+	// ErrNotBlindCount 盘点单不是盲盘
+	ErrNotBlindCount = errors.New("盘点单不是盲盘")
+	// ErrSameCounter 同一人不能对同一商品重复计数
+	ErrSameCounter = errors.New("两次计数必须由不同的人完成")
+	// ErrAwaitingRecount 两人计数不一致，等待复盘
+	ErrAwaitingRecount = errors.New("两人计数不一致，请完成复盘任务")
+	// ErrItemChecked 盘点明细已完成盘点
+	ErrItemChecked = errors.New("该商品已完成盘点")
+	// ErrRecountCompleted 复盘任务已完成
+	ErrRecountCompleted = errors.New("复盘任务已完成")
+)
+
+// CountResult 盲盘计数结果，不包含系统库存和其他人的计数
+type CountResult struct {
+	CheckItemID uint   `json:"check_item_id"`
+	Round       int    `json:"round"`  // 本次计数轮次
+	Status      string `json:"status"` // 计数后的明细状态
+}
+
+// SubmitBlindCount 在事务tx中提交盲盘计数
+// 第一次计数后等待第二人计数；两人计数一致时作为实盘数量，不一致时创建复盘任务
+func SubmitBlindCount(tx *gorm.DB, check *models.InventoryCheck, itemID, counterID uint, quantity int, note string) (*CountResult, error) {
+	if !check.BlindCount {
+		return nil, ErrNotBlindCount
+	}
+	if check.Status != models.InProcess {
+		return nil, ErrCheckNotInProcess
+	}
+	if quantity < 0 {
+		return nil, ErrInvalidQuantity
+	}
+
+	item, err := lockCheckItem(tx, check.ID, itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch item.Status {
+	case models.CheckItemRecount:
+		return nil, ErrAwaitingRecount
+	case models.CheckItemChecked:
+		return nil, ErrItemChecked
+	}
+
+	var counts []models.InventoryCheckCount
+	if err := tx.Where("check_item_id = ?", item.ID).Order("round").Find(&counts).Error; err != nil {
+		return nil, fmt.Errorf("查询盘点计数失败: %w", err)
+	}
+	for _, count := range counts {
+		if count.CounterID == counterID {
+			return nil, ErrSameCounter
+		}
+	}
+
+	count := models.InventoryCheckCount{
+		CheckID:     check.ID,
+		CheckItemID: item.ID,
+		Round:       len(counts) + 1,
+		CounterID:   counterID,
+		Quantity:    quantity,
+		Note:        note,
+	}
+	if err := tx.Create(&count).Error; err != nil {
+		return nil, fmt.Errorf("创建盘点计数失败: %w", err)
+	}
+
+	if count.Round == 1 {
+		item.Status = models.CheckItemCounting
+	} else if first := counts[0]; first.Quantity == quantity {
+		if err := acceptCount(tx, check, item, quantity, note); err != nil {
+			return nil, err
+		}
+	} else {
+		// 两人计数不一致，创建复盘任务
+		item.Status = models.CheckItemRecount
+		task := models.InventoryRecountTask{
+			CheckID:     check.ID,
+			CheckItemID: item.ID,
+			ProductID:   item.ProductID,
+			FirstCount:  first.Quantity,
+			SecondCount: quantity,
+			Status:      models.RecountPending,
+		}
+		if err := tx.Create(&task).Error; err != nil {
+			return nil, fmt.Errorf("创建复盘任务失败: %w", err)
+		}
+	}
+
+	if err := tx.Save(item).Error; err != nil {
+		return nil, fmt.Errorf("更新盘点明细失败: %w", err)
+	}
+
+	return &CountResult{CheckItemID: item.ID, Round: count.Round, Status: item.Status}, nil
+}
+
+// CompleteRecount 在事务tx中完成复盘任务，复盘数量作为最终实盘数量
+func CompleteRecount(tx *gorm.DB, check *models.InventoryCheck, taskID, counterID uint, quantity int, note string) (*models.InventoryRecountTask, error) {
+	if check.Status != models.InProcess {
+		return nil, ErrCheckNotInProcess
+	}
+	if quantity < 0 {
+		return nil, ErrInvalidQuantity
+	}
+
+	var task models.InventoryRecountTask
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND check_id = ?", taskID, check.ID).
+		First(&task).Error; err != nil {
+		return nil, err
+	}
+	if task.Status != models.RecountPending {
+		return nil, ErrRecountCompleted
+	}
+
+	item, err := lockCheckItem(tx, check.ID, task.CheckItemID)
+	if err != nil {
+		return nil, err
+	}
+
+	var rounds int64
+	if err := tx.Model(&models.InventoryCheckCount{}).Where("check_item_id = ?", item.ID).Count(&rounds).Error; err != nil {
+		return nil, fmt.Errorf("查询盘点计数失败: %w", err)
+	}
+	if err := tx.Create(&models.InventoryCheckCount{
+		CheckID:     check.ID,
+		CheckItemID: item.ID,
+		Round:       int(rounds) + 1,
+		CounterID:   counterID,
+		Quantity:    quantity,
+		Note:        note,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("创建盘点计数失败: %w", err)
+	}
+
+	if err := acceptCount(tx, check, item, quantity, note); err != nil {
+		return nil, err
+	}
+	if err := tx.Save(item).Error; err != nil {
+		return nil, fmt.Errorf("更新盘点明细失败: %w", err)
+	}
+
+	now := time.Now()
+	task.Status = models.RecountCompleted
+	task.RecountQty = &quantity
+	task.CounterID = &counterID
+	task.CompletedAt = &now
+	if err := tx.Save(&task).Error; err != nil {
+		return nil, fmt.Errorf("更新复盘任务失败: %w", err)
+	}
+
+	return &task, nil
+}
+
+// lockCheckItem 锁定盘点明细，避免并发计数
+func lockCheckItem(tx *gorm.DB, checkID, itemID uint) (*models.InventoryCheckItem, error) {
+	var item models.InventoryCheckItem
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND check_id = ?", itemID, checkID).
+		First(&item).Error; err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// acceptCount 将计数作为明细的最终实盘数量
+func acceptCount(tx *gorm.DB, check *models.InventoryCheck, item *models.InventoryCheckItem, quantity int, note string) error {
+	if err := ApplyCount(tx, check, item, quantity); err != nil {
+		return err
+	}
+	if note != "" {
+		item.Note = note
+	}
+	return nil
+}
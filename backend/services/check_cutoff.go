@@ -0,0 +1,133 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"hd_psi/backend/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrStoreFrozen 店铺正在全盘，库存已冻结
+var ErrStoreFrozen = errors.New("店铺正在全盘，暂停调拨和采购入库")
+
+// frozenTransactionTypes 冻结期间禁止的库存交易类型，销售和退货不受影响
+var frozenTransactionTypes = map[models.TransactionType]bool{
+	models.PurchaseIn:  true,
+	models.TransferIn:  true,
+	models.TransferOut: true,
+}
+
+// checkFreeze 店铺有冻结库存的全盘进行中时，拒绝调拨和采购入库
+// 只有冻结类型的交易锁定店铺，与 CaptureCutoff 使用同一把店铺锁；销售等其他交易不锁店铺，
+// 与截止快照通过库存行锁互斥
+func (l *InventoryLedger) checkFreeze(tx *gorm.DB, transaction *models.InventoryTransaction) error {
+	if !frozenTransactionTypes[transaction.TransactionType] {
+		return nil
+	}
+	if err := lockStore(tx, transaction.StoreID); err != nil {
+		return err
+	}
+
+	var count int64
+	if err := tx.Model(&models.InventoryCheck{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("store_id = ? AND status = ? AND check_type = ? AND freeze_stock = ?",
+			transaction.StoreID, models.InProcess, models.FullCheck, true).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("查询盘点冻结状态失败: %w", err)
+	}
+	if count > 0 {
+		return ErrStoreFrozen
+	}
+	return nil
+}
+
+// CaptureCutoff 在事务tx中记录盘点截止快照
+// 锁定店铺，等待进行中的库存变动提交后，以当前库存刷新盘点明细的系统库存，
+// 并记录最后一条库存交易ID，之后的交易作为截止后变动计入差异
+func CaptureCutoff(tx *gorm.DB, check *models.InventoryCheck) error {
+	if err := lockStore(tx, check.StoreID); err != nil {
+		return err
+	}
+
+	var inventories []models.Inventory
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("store_id = ?", check.StoreID).
+		Find(&inventories).Error; err != nil {
+		return fmt.Errorf("锁定店铺库存失败: %w", err)
+	}
+	balances := make(map[uint]models.Inventory, len(inventories))
+	for _, inv := range inventories {
+		balances[inv.ProductID] = inv
+	}
+
+	var lastID uint
+	if err := tx.Model(&models.InventoryTransaction{}).
+		Select("COALESCE(MAX(id), 0)").Scan(&lastID).Error; err != nil {
+		return fmt.Errorf("查询库存交易失败: %w", err)
+	}
+
+	var items []models.InventoryCheckItem
+	if err := tx.Where("check_id = ?", check.ID).Find(&items).Error; err != nil {
+		return fmt.Errorf("查询盘点明细失败: %w", err)
+	}
+	for i := range items {
+		inv := balances[items[i].ProductID]
+		if items[i].SystemQuantity == inv.Quantity && items[i].QuarantineQty == inv.QuarantineQty {
+			continue
+		}
+		if err := tx.Model(&items[i]).Updates(map[string]interface{}{
+			"system_quantity": inv.Quantity,
+			"quarantine_qty":  inv.QuarantineQty,
+		}).Error; err != nil {
+			return fmt.Errorf("更新盘点明细失败: %w", err)
+		}
+	}
+
+	now := time.Now()
+	check.CutoffAt = &now
+	check.CutoffTransactionID = lastID
+	return nil
+}
+
+// lockStore 锁定店铺记录，调拨和采购入库与盘点截止快照在同一店铺内依次进行
+func lockStore(tx *gorm.DB, storeID uint) error {
+	var store models.Store
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&store, storeID).Error; err != nil {
+		return fmt.Errorf("锁定店铺失败: %w", err)
+	}
+	return nil
+}
+
+// MovementsSinceCutoff 统计盘点截止后商品的可售库存变动，不含只补记交易的台账修正
+func MovementsSinceCutoff(db *gorm.DB, check *models.InventoryCheck, productID uint) (int, error) {
+	if check.CutoffAt == nil {
+		return 0, nil
+	}
+
+	var movement int
+	if err := db.Model(&models.InventoryTransaction{}).
+		Select("COALESCE(SUM(quantity), 0)").
+		Where("store_id = ? AND product_id = ? AND id > ? AND bucket = ? AND transaction_type <> ?",
+			check.StoreID, productID, check.CutoffTransactionID, models.BucketSellable, models.LedgerCorrection).
+		Scan(&movement).Error; err != nil {
+		return 0, fmt.Errorf("统计截止后库存变动失败: %w", err)
+	}
+	return movement, nil
+}
+
+// ApplyCount 将计数作为明细的实盘数量，差异扣除截止后到计数时的库存变动
+func ApplyCount(db *gorm.DB, check *models.InventoryCheck, item *models.InventoryCheckItem, quantity int) error {
+	movement, err := MovementsSinceCutoff(db, check, item.ProductID)
+	if err != nil {
+		return err
+	}
+
+	item.ActualQuantity = quantity
+	item.MovementQty = movement
+	item.DifferenceQty = quantity - item.SystemQuantity - movement
+	item.Status = models.CheckItemChecked
+	return nil
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"hd_psi/backend/models"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrCheckNotCompleted 盘点单未完成
+	ErrCheckNotCompleted = errors.New("只有已完成的盘点单可以调整库存")
+	// ErrAdjustmentNotPending 盘点调整不是待审批状态
+	ErrAdjustmentNotPending = errors.New("只有待审批的盘点调整可以审批")
+	// ErrInvalidApprovalStatus 审批状态无效
+	ErrInvalidApprovalStatus = errors.New("审批状态只能为 approved 或 rejected")
+)
+
+// VarianceLine 盘点差异明细，差异按成本和零售价计价
+type VarianceLine struct {
+	CheckItemID      uint    `json:"check_item_id"`
+	ProductID        uint    `json:"product_id"`
+	SKU              string  `json:"sku"`
+	Name             string  `json:"name"`
+	Category         string  `json:"category"`
+	SystemQuantity   int     `json:"system_quantity"`
+	MovementQty      int     `json:"movement_qty"` // 截止后变动
+	ActualQuantity   int     `json:"actual_quantity"`
+	DifferenceQty    int     `json:"difference_qty"`
+	UnitCost         float64 `json:"unit_cost"` // 店铺平均成本，无成本时取商品成本价
+	RetailPrice      float64 `json:"retail_price"`
+	CostValue        float64 `json:"cost_value"`   // 差异成本金额，盘亏为负数
+	RetailValue      float64 `json:"retail_value"` // 差异零售金额，盘亏为负数
+	AdjustmentID     *uint   `json:"adjustment_id"`
+	AdjustmentStatus string  `json:"adjustment_status"`
+}
+
+// VarianceReport 盘点差异报告
+type VarianceReport struct {
+	CheckID    uint           `json:"check_id"`
+	CheckCode  string         `json:"check_code"`
+	StoreID    uint           `json:"store_id"`
+	Items      int            `json:"items"`       // 盘点商品数
+	GainQty    int            `json:"gain_qty"`    // 盘盈数量
+	LossQty    int            `json:"loss_qty"`    // 盘亏数量（正数）
+	GainCost   float64        `json:"gain_cost"`   // 盘盈成本金额
+	LossCost   float64        `json:"loss_cost"`   // 盘亏成本金额（正数）
+	NetCost    float64        `json:"net_cost"`    // 净差异成本金额
+	GainRetail float64        `json:"gain_retail"` // 盘盈零售金额
+	LossRetail float64        `json:"loss_retail"` // 盘亏零售金额（正数）
+	NetRetail  float64        `json:"net_retail"`  // 净差异零售金额
+	Lines      []VarianceLine `json:"lines"`       // 有差异的明细
+}
+
+// CheckVariance 生成盘点差异报告，只列出有差异的明细
+func CheckVariance(db *gorm.DB, check *models.InventoryCheck) (*VarianceReport, error) {
+	var lines []VarianceLine
+	if err := db.Table("inventory_check_items").
+		Select("inventory_check_items.id AS check_item_id, inventory_check_items.product_id, products.sku, products.name, products.category, "+
+			"inventory_check_items.system_quantity, inventory_check_items.movement_qty, inventory_check_items.actual_quantity, inventory_check_items.difference_qty, "+
+			"CASE WHEN inventories.avg_cost > 0 THEN inventories.avg_cost ELSE products.cost_price END AS unit_cost, products.retail_price").
+		Joins("LEFT JOIN products ON products.id = inventory_check_items.product_id").
+		Joins("LEFT JOIN inventories ON inventories.store_id = ? AND inventories.product_id = inventory_check_items.product_id", check.StoreID).
+		Where("inventory_check_items.check_id = ? AND inventory_check_items.difference_qty <> 0", check.ID).
+		Order("inventory_check_items.id").
+		Scan(&lines).Error; err != nil {
+		return nil, fmt.Errorf("查询盘点差异失败: %w", err)
+	}
+
+	var items int64
+	if err := db.Model(&models.InventoryCheckItem{}).Where("check_id = ?", check.ID).Count(&items).Error; err != nil {
+		return nil, fmt.Errorf("查询盘点明细失败: %w", err)
+	}
+
+	// 每个明细最近一次的调整记录
+	var adjustments []models.InventoryCheckAdjustment
+	if err := db.Where("check_id = ?", check.ID).Order("id").Find(&adjustments).Error; err != nil {
+		return nil, fmt.Errorf("查询盘点调整失败: %w", err)
+	}
+	latest := make(map[uint]models.InventoryCheckAdjustment, len(adjustments))
+	for _, adjustment := range adjustments {
+		latest[adjustment.CheckItemID] = adjustment
+	}
+
+	report := &VarianceReport{
+		CheckID:   check.ID,
+		CheckCode: check.CheckCode,
+		StoreID:   check.StoreID,
+		Items:     int(items),
+		Lines:     lines,
+	}
+	for i := range report.Lines {
+		line := &report.Lines[i]
+		line.CostValue = roundAmount(float64(line.DifferenceQty) * line.UnitCost)
+		line.RetailValue = roundAmount(float64(line.DifferenceQty) * line.RetailPrice)
+		if adjustment, ok := latest[line.CheckItemID]; ok {
+			id := adjustment.ID
+			line.AdjustmentID = &id
+			line.AdjustmentStatus = adjustment.ApprovalStatus
+		}
+
+		if line.DifferenceQty > 0 {
+			report.GainQty += line.DifferenceQty
+			report.GainCost += line.CostValue
+			report.GainRetail += line.RetailValue
+		} else {
+			report.LossQty -= line.DifferenceQty
+			report.LossCost -= line.CostValue
+			report.LossRetail -= line.RetailValue
+		}
+	}
+	report.GainCost = roundAmount(report.GainCost)
+	report.LossCost = roundAmount(report.LossCost)
+	report.NetCost = roundAmount(report.GainCost - report.LossCost)
+	report.GainRetail = roundAmount(report.GainRetail)
+	report.LossRetail = roundAmount(report.LossRetail)
+	report.NetRetail = roundAmount(report.GainRetail - report.LossRetail)
+
+	return report, nil
+}
+
+// AutoApproveRule 盘点差异自动审批阈值，差异数量和成本金额的绝对值都不超过阈值时自动审批
+type AutoApproveRule struct {
+	MaxQty   int
+	MaxValue float64
+}
+
+// allows 判断差异是否在自动审批阈值内，阈值为0表示不自动审批
+func (r AutoApproveRule) allows(line VarianceLine) bool {
+	if r.MaxQty <= 0 {
+		return false
+	}
+	return absInt(line.DifferenceQty) <= r.MaxQty && math.Abs(line.CostValue) <= r.MaxValue
+}
+
+// GenerateAdjustments 在事务tx中为没有调整记录（或调整已被拒绝）的差异明细创建盘点调整
+// 差异在自动审批阈值内的调整直接审批并过账，其余调整待审批
+// 盘点单加行锁后重新读取，并发生成调整时不会为同一差异重复创建
+// 返回：
+//   - []models.InventoryCheckAdjustment: 新建的调整记录
+//   - error: 创建或过账失败时返回错误
+func GenerateAdjustments(tx *gorm.DB, ledger *InventoryLedger, check *models.InventoryCheck, rule AutoApproveRule, operatorID uint) ([]models.InventoryCheckAdjustment, error) {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(check, check.ID).Error; err != nil {
+		return nil, fmt.Errorf("查询盘点单失败: %w", err)
+	}
+	if check.Status != models.Completed {
+		return nil, ErrCheckNotCompleted
+	}
+
+	report, err := CheckVariance(tx, check)
+	if err != nil {
+		return nil, err
+	}
+
+	var created []models.InventoryCheckAdjustment
+	for _, line := range report.Lines {
+		if line.AdjustmentID != nil && line.AdjustmentStatus != models.AdjustmentRejected {
+			continue
+		}
+
+		adjustment := models.InventoryCheckAdjustment{
+			CheckID:        check.ID,
+			CheckItemID:    line.CheckItemID,
+			ProductID:      line.ProductID,
+			AdjustQuantity: line.DifferenceQty,
+			Reason:         "盘点差异",
+			ApprovalStatus: models.AdjustmentPending,
+			AutoApproved:   rule.allows(line),
+		}
+		if err := tx.Create(&adjustment).Error; err != nil {
+			return nil, fmt.Errorf("创建盘点调整失败: %w", err)
+		}
+
+		if adjustment.AutoApproved {
+			note := fmt.Sprintf("差异 %d 件、成本 %.2f 元在自动审批阈值内", line.DifferenceQty, line.CostValue)
+			if err := ReviewAdjustment(tx, ledger, check, &adjustment, operatorID, models.AdjustmentApproved, note); err != nil {
+				return nil, err
+			}
+		}
+		created = append(created, adjustment)
+	}
+	return created, nil
+}
+
+// ReviewAdjustment 在事务tx中审批盘点调整，审批通过时按调整数量过账库存
+// 调整记录加行锁后重新读取并校验状态，并发审批时同一调整只会过账一次
+func ReviewAdjustment(tx *gorm.DB, ledger *InventoryLedger, check *models.InventoryCheck, adjustment *models.InventoryCheckAdjustment, approverID uint, status, note string) error {
+	if status != models.AdjustmentApproved && status != models.AdjustmentRejected {
+		return ErrInvalidApprovalStatus
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(adjustment, adjustment.ID).Error; err != nil {
+		return fmt.Errorf("查询盘点调整失败: %w", err)
+	}
+	if adjustment.ApprovalStatus != models.AdjustmentPending {
+		return ErrAdjustmentNotPending
+	}
+
+	now := time.Now()
+	adjustment.ApproverID = approverID
+	adjustment.ApprovalStatus = status
+	adjustment.ApprovalNote = note
+	adjustment.ApprovalTime = &now
+	if err := tx.Save(adjustment).Error; err != nil {
+		return fmt.Errorf("更新盘点调整失败: %w", err)
+	}
+
+	if status != models.AdjustmentApproved {
+		return nil
+	}
+
+	// 盘点调整库存
+	transaction := models.InventoryTransaction{
+		TransactionType: models.CheckAdjust,
+		ProductID:       adjustment.ProductID,
+		StoreID:         check.StoreID,
+		Quantity:        adjustment.AdjustQuantity,
+		ReferenceID:     &adjustment.ID,
+		ReferenceType:   "inventory_check_adjustment",
+		OperatorID:      approverID,
+		Note:            "盘点调整: " + adjustment.Reason,
+	}
+	if _, err := ledger.Post(tx, &transaction); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ReviewAdjustments 在事务tx中批量审批盘点调整，任一调整审批失败时返回错误，由调用方回滚全部审批
+func ReviewAdjustments(tx *gorm.DB, ledger *InventoryLedger, adjustmentIDs []uint, approverID uint, status, note string) ([]models.InventoryCheckAdjustment, error) {
+	var adjustments []models.InventoryCheckAdjustment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id IN ?", adjustmentIDs).Order("id").
+		Find(&adjustments).Error; err != nil {
+		return nil, fmt.Errorf("查询盘点调整失败: %w", err)
+	}
+	if len(adjustments) != len(uniqueIDs(adjustmentIDs)) {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	checks := make(map[uint]*models.InventoryCheck)
+	for i := range adjustments {
+		adjustment := &adjustments[i]
+		check, ok := checks[adjustment.CheckID]
+		if !ok {
+			check = &models.InventoryCheck{}
+			if err := tx.First(check, adjustment.CheckID).Error; err != nil {
+				return nil, fmt.Errorf("查询盘点单失败: %w", err)
+			}
+			checks[adjustment.CheckID] = check
+		}
+		if err := ReviewAdjustment(tx, ledger, check, adjustment, approverID, status, note); err != nil {
+			return nil, fmt.Errorf("盘点调整 %d: %w", adjustment.ID, err)
+		}
+	}
+	return adjustments, nil
+}
+
+// uniqueIDs 去除重复的ID
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// absInt 返回整数的绝对值
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// roundAmount 金额保留两位小数
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,303 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"hd_psi/backend/models"
+	"hd_psi/backend/utils"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrSessionClosed 扫码会话已结束
+	ErrSessionClosed = errors.New("扫码会话已结束")
+	// ErrNothingToUndo 没有可撤销的扫码
+	ErrNothingToUndo = errors.New("没有可撤销的扫码")
+	// ErrPayloadTooLong 二维码数据超过扫码记录可保存的长度
+	ErrPayloadTooLong = errors.New("二维码数据过长")
+)
+
+// maxPayloadSize 二维码数据的最大长度，与 models.CountScan.Payload 一致
+const maxPayloadSize = 512
+
+// ProductTally 会话中单个商品的有效扫码数量
+type ProductTally struct {
+	ProductID   uint `json:"product_id"`
+	CheckItemID uint `json:"check_item_id"`
+	Quantity    int  `json:"quantity"`
+}
+
+// SessionCountResult 结束会话时单个商品计入盘点明细的结果
+type SessionCountResult struct {
+	ProductTally
+	Status string `json:"status,omitempty"` // 计入后的明细状态
+	Error  string `json:"error,omitempty"`  // 未能计入的原因
+}
+
+// OpenCountSession 在事务tx中为计数人开启扫码盘点会话
+func OpenCountSession(tx *gorm.DB, check *models.InventoryCheck, counterID uint) (*models.CountSession, error) {
+	if check.Status != models.InProcess {
+		return nil, ErrCheckNotInProcess
+	}
+
+	session := models.CountSession{
+		CheckID:   check.ID,
+		CounterID: counterID,
+		Status:    models.SessionOpen,
+	}
+	if err := tx.Create(&session).Error; err != nil {
+		return nil, fmt.Errorf("创建扫码会话失败: %w", err)
+	}
+	return &session, nil
+}
+
+// RecordScans 在事务tx中记录一批扫码，盘点单已不在盘点中时拒绝
+// 二维码校验失败、SKU不存在、商品不在盘点范围内或批次属于其他店铺的扫码会被标记，不计入数量
+func RecordScans(tx *gorm.DB, check *models.InventoryCheck, sessionID uint, payloads []string, secretKey string) ([]models.CountScan, error) {
+	if err := lockCheckInProcess(tx, check); err != nil {
+		return nil, err
+	}
+	session, err := lockSession(tx, check.ID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status != models.SessionOpen {
+		return nil, ErrSessionClosed
+	}
+	if len(payloads) == 0 {
+		return []models.CountScan{}, nil
+	}
+	for _, payload := range payloads {
+		if len(payload) > maxPayloadSize {
+			return nil, ErrPayloadTooLong
+		}
+	}
+
+	var items []models.InventoryCheckItem
+	if err := tx.Select("id, product_id").Where("check_id = ?", check.ID).Find(&items).Error; err != nil {
+		return nil, fmt.Errorf("查询盘点明细失败: %w", err)
+	}
+	itemIDs := make(map[uint]uint, len(items))
+	for _, item := range items {
+		itemIDs[item.ProductID] = item.ID
+	}
+
+	products := make(map[string]*models.Product)
+	foreign := make(map[string]bool)
+	scans := make([]models.CountScan, 0, len(payloads))
+	for _, payload := range payloads {
+		scan := models.CountScan{
+			SessionID: session.ID,
+			CheckID:   check.ID,
+			Payload:   payload,
+			Result:    models.ScanInvalid,
+		}
+		scans = append(scans, scan)
+		current := &scans[len(scans)-1]
+
+		data, valid, err := utils.VerifyQRCode(payload, secretKey)
+		if err != nil || !valid {
+			continue
+		}
+		current.SKU = data.SKU
+		current.BatchNumber = data.BatchNumber
+
+		product, ok := products[data.SKU]
+		if !ok {
+			var p models.Product
+			result := tx.Select("id, sku").Where("sku = ?", data.SKU).Limit(1).Find(&p)
+			if result.Error != nil {
+				return nil, fmt.Errorf("查询商品失败: %w", result.Error)
+			}
+			if result.RowsAffected > 0 {
+				product = &p
+			}
+			products[data.SKU] = product
+		}
+		if product == nil {
+			current.Result = models.ScanUnknown
+			continue
+		}
+		productID := product.ID
+		current.ProductID = &productID
+
+		if data.BatchNumber != "" {
+			key := fmt.Sprintf("%d/%s", product.ID, data.BatchNumber)
+			isForeign, ok := foreign[key]
+			if !ok {
+				isForeign, err = isForeignBatch(tx, check.StoreID, product.ID, data.BatchNumber)
+				if err != nil {
+					return nil, err
+				}
+				foreign[key] = isForeign
+			}
+			if isForeign {
+				current.Result = models.ScanForeignStore
+				continue
+			}
+		}
+
+		itemID, ok := itemIDs[product.ID]
+		if !ok {
+			current.Result = models.ScanNotInCheck
+			continue
+		}
+		current.CheckItemID = &itemID
+		current.Result = models.ScanAccepted
+	}
+
+	if err := tx.CreateInBatches(&scans, 200).Error; err != nil {
+		return nil, fmt.Errorf("保存扫码记录失败: %w", err)
+	}
+	return scans, nil
+}
+
+// UndoLastScan 在事务tx中撤销会话的最后一次扫码
+func UndoLastScan(tx *gorm.DB, check *models.InventoryCheck, sessionID uint) (*models.CountScan, error) {
+	if err := lockCheckInProcess(tx, check); err != nil {
+		return nil, err
+	}
+	session, err := lockSession(tx, check.ID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status != models.SessionOpen {
+		return nil, ErrSessionClosed
+	}
+
+	var scan models.CountScan
+	result := tx.Where("session_id = ? AND undone = ?", session.ID, false).
+		Order("id DESC").Limit(1).Find(&scan)
+	if result.Error != nil {
+		return nil, fmt.Errorf("查询扫码记录失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrNothingToUndo
+	}
+
+	scan.Undone = true
+	if err := tx.Model(&scan).Update("undone", true).Error; err != nil {
+		return nil, fmt.Errorf("撤销扫码失败: %w", err)
+	}
+	return &scan, nil
+}
+
+// SessionTally 汇总会话中各商品的有效扫码数量
+func SessionTally(db *gorm.DB, sessionID uint) ([]ProductTally, error) {
+	var tallies []ProductTally
+	if err := db.Model(&models.CountScan{}).
+		Select("product_id, check_item_id, COUNT(*) AS quantity").
+		Where("session_id = ? AND result = ? AND undone = ?", sessionID, models.ScanAccepted, false).
+		Group("product_id, check_item_id").
+		Order("product_id").
+		Scan(&tallies).Error; err != nil {
+		return nil, fmt.Errorf("汇总扫码数量失败: %w", err)
+	}
+	return tallies, nil
+}
+
+// CloseCountSession 在事务tx中结束扫码会话，并将各商品的扫码数量计入盘点明细
+// 盲盘时会话数量作为该计数人的一次独立计数；普通盘点时累加到实盘数量，多人分区扫码的数量合计为实盘数量
+// 单个商品无法计入时（如同一人重复计数）记录原因，不影响其他商品
+func CloseCountSession(tx *gorm.DB, check *models.InventoryCheck, sessionID uint) ([]SessionCountResult, error) {
+	if err := lockCheckInProcess(tx, check); err != nil {
+		return nil, err
+	}
+
+	session, err := lockSession(tx, check.ID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status != models.SessionOpen {
+		return nil, ErrSessionClosed
+	}
+
+	tallies, err := SessionTally(tx, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SessionCountResult, 0, len(tallies))
+	for _, tally := range tallies {
+		result := SessionCountResult{ProductTally: tally}
+		if check.BlindCount {
+			count, err := SubmitBlindCount(tx, check, tally.CheckItemID, session.CounterID, tally.Quantity, "扫码盘点")
+			switch {
+			case err == nil:
+				result.Status = count.Status
+			case errors.Is(err, ErrSameCounter), errors.Is(err, ErrAwaitingRecount), errors.Is(err, ErrItemChecked):
+				result.Error = err.Error()
+			default:
+				return nil, err
+			}
+		} else {
+			item, err := lockCheckItem(tx, check.ID, tally.CheckItemID)
+			if err != nil {
+				return nil, err
+			}
+			quantity := tally.Quantity
+			if item.Status == models.CheckItemChecked {
+				quantity += item.ActualQuantity
+			}
+			if err := ApplyCount(tx, check, item, quantity); err != nil {
+				return nil, err
+			}
+			if err := tx.Save(item).Error; err != nil {
+				return nil, fmt.Errorf("更新盘点明细失败: %w", err)
+			}
+			result.Status = item.Status
+		}
+		results = append(results, result)
+	}
+
+	now := time.Now()
+	session.Status = models.SessionClosed
+	session.ClosedAt = &now
+	if err := tx.Save(session).Error; err != nil {
+		return nil, fmt.Errorf("更新扫码会话失败: %w", err)
+	}
+
+	return results, nil
+}
+
+// lockCheckInProcess 锁定并重新读取盘点单，盘点单已不在盘点中时返回错误
+// 先锁盘点单再锁会话，避免与完成盘点并发时继续记录扫码
+func lockCheckInProcess(tx *gorm.DB, check *models.InventoryCheck) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(check, check.ID).Error; err != nil {
+		return fmt.Errorf("查询盘点单失败: %w", err)
+	}
+	if check.Status != models.InProcess {
+		return ErrCheckNotInProcess
+	}
+	return nil
+}
+
+// lockSession 锁定扫码会话，保证同一会话的扫码按顺序记录
+func lockSession(tx *gorm.DB, checkID, sessionID uint) (*models.CountSession, error) {
+	var session models.CountSession
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND check_id = ?", sessionID, checkID).
+		First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// isForeignBatch 批次只在其他店铺登记过时视为外店商品，未登记的批次不做判断
+func isForeignBatch(tx *gorm.DB, storeID, productID uint, batchNumber string) (bool, error) {
+	var stores []uint
+	if err := tx.Model(&models.InventoryBatch{}).
+		Where("product_id = ? AND batch_number = ?", productID, batchNumber).
+		Pluck("store_id", &stores).Error; err != nil {
+		return false, fmt.Errorf("查询批次失败: %w", err)
+	}
+	for _, id := range stores {
+		if id == storeID {
+			return false, nil
+		}
+	}
+	return len(stores) > 0, nil
+}
@@ -0,0 +1,359 @@
+package services
+
+import (
+	"fmt"
+	"hd_psi/backend/models"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultABCDays ABC分类的默认销售额统计天数
+const DefaultABCDays = 90
+
+// ABC分类的累计销售额占比上限（%）
+const (
+	classAShare = 80.0
+	classBShare = 95.0
+)
+
+// CycleIntervals 各分类的循环盘点周期（月）：A类每月、B类每两个月、C类每季度盘点一次
+var CycleIntervals = map[models.ABCClass]int{
+	models.ClassA: 1,
+	models.ClassB: 2,
+	models.ClassC: 3,
+}
+
+// classOrder 分类的展示顺序
+var classOrder = []models.ABCClass{models.ClassA, models.ClassB, models.ClassC}
+
+// ClassifyProducts 按统计期内的销售额对店铺商品进行ABC分类，storeID 为0时分类所有店铺
+// 销售额取已支付、已发货和已完成订单的实际售价，有库存但无销售的商品归为C类
+// 返回：
+//   - int: 分类的商品数
+//   - error: 分类失败时返回错误
+func ClassifyProducts(db *gorm.DB, storeID uint, days int) (int, error) {
+	if days <= 0 {
+		days = DefaultABCDays
+	}
+
+	storeIDs := []uint{storeID}
+	if storeID == 0 {
+		if err := db.Model(&models.Store{}).Order("id").Pluck("id", &storeIDs).Error; err != nil {
+			return 0, fmt.Errorf("查询店铺失败: %w", err)
+		}
+	}
+
+	total := 0
+	since := time.Now().AddDate(0, 0, -days)
+	for _, id := range storeIDs {
+		count, err := classifyStore(db, id, since)
+		if err != nil {
+			return total, fmt.Errorf("店铺 %d ABC分类失败: %w", id, err)
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// classifyStore 对单个店铺的商品进行ABC分类，覆盖店铺原有的分类结果
+func classifyStore(db *gorm.DB, storeID uint, since time.Time) (int, error) {
+	var sales []struct {
+		ProductID  uint
+		SalesValue float64
+	}
+	if err := db.Table("sales_order_items").
+		Select("sales_order_items.product_id, SUM(sales_order_items.actual_price * sales_order_items.quantity) AS sales_value").
+		Joins("JOIN sales_orders ON sales_orders.id = sales_order_items.order_id").
+		Where("sales_orders.store_id = ? AND sales_orders.status IN ? AND sales_orders.created_at >= ?",
+			storeID, []models.OrderStatus{models.Paid, models.Shipped, models.OrderCompleted}, since).
+		Group("sales_order_items.product_id").
+		Scan(&sales).Error; err != nil {
+		return 0, fmt.Errorf("统计商品销售额失败: %w", err)
+	}
+
+	values := make(map[uint]float64)
+	totalValue := 0.0
+	for _, s := range sales {
+		if s.SalesValue > 0 {
+			values[s.ProductID] = s.SalesValue
+			totalValue += s.SalesValue
+		}
+	}
+
+	var stocked []uint
+	if err := db.Model(&models.Inventory{}).
+		Where("store_id = ? AND (quantity > 0 OR quarantine_qty > 0)", storeID).
+		Pluck("product_id", &stocked).Error; err != nil {
+		return 0, fmt.Errorf("查询店铺库存失败: %w", err)
+	}
+	for _, productID := range stocked {
+		if _, ok := values[productID]; !ok {
+			values[productID] = 0
+		}
+	}
+
+	productIDs := make([]uint, 0, len(values))
+	for productID := range values {
+		productIDs = append(productIDs, productID)
+	}
+	sort.Slice(productIDs, func(i, j int) bool {
+		vi, vj := values[productIDs[i]], values[productIDs[j]]
+		if vi != vj {
+			return vi > vj
+		}
+		return productIDs[i] < productIDs[j]
+	})
+
+	// 按销售额从高到低累计占比，累计占比达到阈值前的商品归入对应分类
+	now := time.Now()
+	classes := make([]models.ProductClass, 0, len(productIDs))
+	cumulative := 0.0
+	for i, productID := range productIDs {
+		value := values[productID]
+		class := models.ClassC
+		if value > 0 {
+			switch {
+			case cumulative < classAShare:
+				class = models.ClassA
+			case cumulative < classBShare:
+				class = models.ClassB
+			}
+			cumulative += value / totalValue * 100
+		}
+		classes = append(classes, models.ProductClass{
+			StoreID:      storeID,
+			ProductID:    productID,
+			Class:        class,
+			SalesValue:   math.Round(value*100) / 100,
+			ValueShare:   math.Round(cumulative*100) / 100,
+			Rank:         i + 1,
+			CalculatedAt: now,
+		})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("store_id = ?", storeID).Delete(&models.ProductClass{}).Error; err != nil {
+			return err
+		}
+		if len(classes) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(&classes, 500).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("保存ABC分类失败: %w", err)
+	}
+	return len(classes), nil
+}
+
+// CyclePlan 循环盘点计划生成的盘点单
+type CyclePlan struct {
+	StoreID   uint                    `json:"store_id"`
+	CheckID   uint                    `json:"check_id"`
+	CheckCode string                  `json:"check_code"`
+	Items     int                     `json:"items"`
+	Classes   map[models.ABCClass]int `json:"classes"` // 各分类的商品数
+}
+
+// PlanCycleCounts 为period所在月份生成循环盘点抽盘单，storeID 为0时为所有已分类的店铺生成
+// 每个分类的盘点周期按自然月对齐（C类按季度），商品按ID分摊到周期内的各月，
+// 周期内已完成盘点的商品不再重复安排，之前月份漏盘或未完成盘点的商品顺延到本月
+// 同一店铺同一月份只生成一次
+func PlanCycleCounts(db *gorm.DB, period time.Time, storeID, operatorID uint) ([]CyclePlan, error) {
+	month := startOfMonth(period)
+	periodKey := month.Format("2006-01")
+
+	storeIDs := []uint{storeID}
+	if storeID == 0 {
+		if err := db.Model(&models.ProductClass{}).Distinct().Order("store_id").
+			Pluck("store_id", &storeIDs).Error; err != nil {
+			return nil, fmt.Errorf("查询店铺失败: %w", err)
+		}
+	}
+
+	var plans []CyclePlan
+	for _, id := range storeIDs {
+		var existing int64
+		if err := db.Model(&models.InventoryCheck{}).
+			Where("store_id = ? AND cycle_period = ? AND status <> ?", id, periodKey, models.Cancelled).
+			Count(&existing).Error; err != nil {
+			return plans, fmt.Errorf("查询循环盘点单失败: %w", err)
+		}
+		if existing > 0 {
+			continue
+		}
+
+		productIDs, counts, err := dueProducts(db, id, month)
+		if err != nil {
+			return plans, fmt.Errorf("店铺 %d 计算待盘商品失败: %w", id, err)
+		}
+		if len(productIDs) == 0 {
+			continue
+		}
+
+		planDate := month
+		if now := time.Now(); now.After(planDate) {
+			planDate = now
+		}
+		parts := make([]string, 0, len(classOrder))
+		for _, class := range classOrder {
+			parts = append(parts, fmt.Sprintf("%s类%d个", class, counts[class]))
+		}
+		check := models.InventoryCheck{
+			StoreID:     id,
+			CheckType:   models.SpotCheck,
+			PlanDate:    planDate,
+			OperatorID:  operatorID,
+			CyclePeriod: periodKey,
+			Description: fmt.Sprintf("循环盘点 %s：%s", periodKey, strings.Join(parts, "，")),
+		}
+
+		var items int
+		err = db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			items, err = CreateInventoryCheck(tx, &check, productIDs)
+			return err
+		})
+		if err != nil {
+			return plans, fmt.Errorf("店铺 %d 创建循环盘点单失败: %w", id, err)
+		}
+		plans = append(plans, CyclePlan{
+			StoreID:   id,
+			CheckID:   check.ID,
+			CheckCode: check.CheckCode,
+			Items:     items,
+			Classes:   counts,
+		})
+	}
+	return plans, nil
+}
+
+// dueProducts 计算店铺在month需要盘点的商品
+func dueProducts(db *gorm.DB, storeID uint, month time.Time) ([]uint, map[models.ABCClass]int, error) {
+	var classes []models.ProductClass
+	if err := db.Where("store_id = ?", storeID).Order("product_id").Find(&classes).Error; err != nil {
+		return nil, nil, err
+	}
+
+	// 各分类周期内已完成盘点的商品，与覆盖率统计口径一致
+	scheduled := make(map[models.ABCClass]map[uint]bool)
+	for _, class := range classOrder {
+		start, _ := cycleWindow(month, CycleIntervals[class])
+		var productIDs []uint
+		if err := db.Table("inventory_check_items").
+			Joins("JOIN inventory_checks ON inventory_checks.id = inventory_check_items.check_id").
+			Where("inventory_checks.store_id = ? AND inventory_checks.status = ? AND inventory_check_items.status = ? AND inventory_checks.end_time >= ?",
+				storeID, models.Completed, models.CheckItemChecked, start).
+			Distinct().Pluck("inventory_check_items.product_id", &productIDs).Error; err != nil {
+			return nil, nil, err
+		}
+		scheduled[class] = make(map[uint]bool, len(productIDs))
+		for _, productID := range productIDs {
+			scheduled[class][productID] = true
+		}
+	}
+
+	var due []uint
+	counts := map[models.ABCClass]int{models.ClassA: 0, models.ClassB: 0, models.ClassC: 0}
+	for _, pc := range classes {
+		interval := CycleIntervals[pc.Class]
+		// 商品在周期内分摊到的月份，未到该月份的商品暂不盘点
+		if int(pc.ProductID)%interval > monthIndex(month)%interval {
+			continue
+		}
+		if scheduled[pc.Class][pc.ProductID] {
+			continue
+		}
+		due = append(due, pc.ProductID)
+		counts[pc.Class]++
+	}
+	return due, counts, nil
+}
+
+// CycleCoverage 循环盘点覆盖率
+type CycleCoverage struct {
+	StoreID     uint            `json:"store_id"`
+	Class       models.ABCClass `json:"class"`
+	WindowStart string          `json:"window_start"` // 盘点周期开始月份
+	WindowEnd   string          `json:"window_end"`   // 盘点周期结束月份
+	Products    int             `json:"products"`     // 分类商品数
+	Counted     int             `json:"counted"`      // 周期内已完成盘点的商品数
+	Coverage    float64         `json:"coverage"`     // 覆盖率（%）
+}
+
+// CycleCountCoverage 统计period所在盘点周期内各店铺各分类的盘点覆盖率，storeID 为0时不限店铺
+// 已完成盘点指商品在周期内完成的盘点单中已盘点（全盘和抽盘都计入）
+func CycleCountCoverage(db *gorm.DB, period time.Time, storeID uint) ([]CycleCoverage, error) {
+	month := startOfMonth(period)
+
+	var result []CycleCoverage
+	for _, class := range classOrder {
+		start, end := cycleWindow(month, CycleIntervals[class])
+
+		var rows []struct {
+			StoreID  uint
+			Products int
+			Counted  int
+		}
+		query := db.Table("product_classes").
+			Select("product_classes.store_id, COUNT(*) AS products, COUNT(counted.product_id) AS counted").
+			Joins(`LEFT JOIN (
+				SELECT DISTINCT inventory_checks.store_id, inventory_check_items.product_id
+				FROM inventory_check_items
+				JOIN inventory_checks ON inventory_checks.id = inventory_check_items.check_id
+				WHERE inventory_checks.status = ? AND inventory_check_items.status = ?
+				AND inventory_checks.end_time >= ? AND inventory_checks.end_time < ?
+			) counted ON counted.store_id = product_classes.store_id AND counted.product_id = product_classes.product_id`,
+				models.Completed, models.CheckItemChecked, start, end).
+			Where("product_classes.class = ?", class)
+		if storeID != 0 {
+			query = query.Where("product_classes.store_id = ?", storeID)
+		}
+		if err := query.Group("product_classes.store_id").Scan(&rows).Error; err != nil {
+			return nil, fmt.Errorf("统计盘点覆盖率失败: %w", err)
+		}
+
+		for _, row := range rows {
+			coverage := 0.0
+			if row.Products > 0 {
+				coverage = math.Round(float64(row.Counted)/float64(row.Products)*10000) / 100
+			}
+			result = append(result, CycleCoverage{
+				StoreID:     row.StoreID,
+				Class:       class,
+				WindowStart: start.Format("2006-01"),
+				WindowEnd:   end.AddDate(0, -1, 0).Format("2006-01"),
+				Products:    row.Products,
+				Counted:     row.Counted,
+				Coverage:    coverage,
+			})
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].StoreID < result[j].StoreID
+	})
+	return result, nil
+}
+
+// cycleWindow 返回month所在盘点周期的起止时间（不含结束），周期按自然月对齐
+func cycleWindow(month time.Time, interval int) (time.Time, time.Time) {
+	offset := monthIndex(month) % interval
+	start := month.AddDate(0, -offset, 0)
+	return start, start.AddDate(0, interval, 0)
+}
+
+// monthIndex 返回月份序号，用于按周期对齐月份
+func monthIndex(t time.Time) int {
+	return t.Year()*12 + int(t.Month()) - 1
+}
+
+// startOfMonth 返回t所在月份的第一天零点（本地时区）
+func startOfMonth(t time.Time) time.Time {
+	year, month, _ := t.In(time.Local).Date()
+	return time.Date(year, month, 1, 0, 0, 0, 0, time.Local)
+}
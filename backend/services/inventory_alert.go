@@ -0,0 +1,179 @@
+package services
+
+import (
+	"fmt"
+	"hd_psi/backend/models"
+	"hd_psi/backend/notify"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StockLevel 待评估预警的店铺商品库存
+type StockLevel struct {
+	StoreID    uint
+	ProductID  uint
+	CategoryID *uint
+	Size       string
+	Quantity   int
+}
+
+// AlertChanges 预警评估结果统计
+type AlertChanges struct {
+	Created  int `json:"created"`  // 新建预警数
+	Updated  int `json:"updated"`  // 更新当前库存的预警数
+	Resolved int `json:"resolved"` // 自动解决的预警数
+}
+
+func (a *AlertChanges) add(other AlertChanges) {
+	a.Created += other.Created
+	a.Updated += other.Updated
+	a.Resolved += other.Resolved
+}
+
+// EvaluateAlerts 按适用阈值评估店铺商品的库存预警
+// 库存越过阈值时新建预警；预警未解决时同步当前库存；库存回到阈值范围内时自动解决
+func EvaluateAlerts(tx *gorm.DB, thresholds *ThresholdSet, level StockLevel) (AlertChanges, error) {
+	var changes AlertChanges
+	threshold := thresholds.Match(ThresholdTarget{
+		StoreID:    level.StoreID,
+		ProductID:  level.ProductID,
+		CategoryID: categoryIDOf(level.CategoryID),
+		Size:       level.Size,
+	})
+
+	// 当前库存状态
+	var alertType models.AlertType
+	var limit int
+	switch {
+	case level.Quantity <= threshold.LowLevel:
+		alertType, limit = models.LowStock, threshold.LowLevel
+	case level.Quantity >= threshold.HighLevel:
+		alertType, limit = models.Overstock, threshold.HighLevel
+	}
+
+	var alerts []models.InventoryAlert
+	if err := tx.Where("store_id = ? AND product_id = ? AND status = ?", level.StoreID, level.ProductID, models.Active).
+		Find(&alerts).Error; err != nil {
+		return changes, fmt.Errorf("查询库存预警失败: %w", err)
+	}
+
+	exists := false
+	now := time.Now()
+	for i := range alerts {
+		alert := &alerts[i]
+		if alert.AlertType == alertType {
+			exists = true
+			if alert.CurrentQty == level.Quantity && alert.Threshold == limit {
+				continue
+			}
+			alert.CurrentQty = level.Quantity
+			alert.Threshold = limit
+			changes.Updated++
+		} else {
+			alert.CurrentQty = level.Quantity
+			alert.Status = models.Resolved
+			alert.ResolvedAt = &now
+			changes.Resolved++
+		}
+		if err := tx.Save(alert).Error; err != nil {
+			return changes, fmt.Errorf("更新库存预警失败: %w", err)
+		}
+	}
+
+	if alertType == "" || exists {
+		return changes, nil
+	}
+
+	alert := models.InventoryAlert{
+		StoreID:    level.StoreID,
+		ProductID:  level.ProductID,
+		CategoryID: level.CategoryID,
+		AlertType:  alertType,
+		Threshold:  limit,
+		CurrentQty: level.Quantity,
+		Status:     models.Active,
+	}
+	if alertType == models.LowStock {
+		alert.Description = "库存低于最低阈值"
+	} else {
+		alert.Description = "库存超过最高阈值"
+	}
+	if err := tx.Create(&alert).Error; err != nil {
+		return changes, fmt.Errorf("创建库存预警失败: %w", err)
+	}
+	changes.Created++
+
+	// 通知订阅了库存预警的用户
+	if err := notify.Enqueue(tx, models.EventInventoryAlert, &alert.StoreID, map[string]interface{}{
+		"alert_id":    alert.ID,
+		"store_id":    alert.StoreID,
+		"product_id":  alert.ProductID,
+		"alert_type":  alert.AlertType,
+		"threshold":   alert.Threshold,
+		"current_qty": alert.CurrentQty,
+		"description": alert.Description,
+	}); err != nil {
+		return changes, err
+	}
+
+	return changes, nil
+}
+
+// EvaluateInventoryAlerts 评估所有店铺商品的库存预警，storeID 为0时不限店铺
+func EvaluateInventoryAlerts(db *gorm.DB, storeID uint) (AlertChanges, error) {
+	var changes AlertChanges
+
+	thresholds, err := LoadThresholds(db)
+	if err != nil {
+		return changes, fmt.Errorf("查询库存阈值失败: %w", err)
+	}
+
+	var levels []StockLevel
+	query := db.Table("inventories").
+		Select("inventories.store_id, inventories.product_id, products.category_id, products.size, inventories.quantity").
+		Joins("JOIN products ON inventories.product_id = products.id")
+	if storeID != 0 {
+		query = query.Where("inventories.store_id = ?", storeID)
+	}
+	if err := query.Scan(&levels).Error; err != nil {
+		return changes, fmt.Errorf("查询库存失败: %w", err)
+	}
+
+	for _, level := range levels {
+		result, err := EvaluateAlerts(db, thresholds, level)
+		if err != nil {
+			return changes, err
+		}
+		changes.add(result)
+	}
+
+	return changes, nil
+}
+
+// evaluateAlerts 库存变动后在同一事务中评估该店铺商品的预警
+func (l *InventoryLedger) evaluateAlerts(tx *gorm.DB, inventory *models.Inventory) error {
+	var product models.Product
+	if err := tx.Select("id, category_id, size").First(&product, inventory.ProductID).Error; err != nil {
+		return fmt.Errorf("查询商品失败: %w", err)
+	}
+
+	thresholds, err := LoadThresholdsFor(tx, ThresholdTarget{
+		StoreID:    inventory.StoreID,
+		ProductID:  inventory.ProductID,
+		CategoryID: categoryIDOf(product.CategoryID),
+		Size:       product.Size,
+	})
+	if err != nil {
+		return fmt.Errorf("查询库存阈值失败: %w", err)
+	}
+
+	_, err = EvaluateAlerts(tx, thresholds, StockLevel{
+		StoreID:    inventory.StoreID,
+		ProductID:  inventory.ProductID,
+		CategoryID: product.CategoryID,
+		Size:       product.Size,
+		Quantity:   inventory.Quantity,
+	})
+	return err
+}
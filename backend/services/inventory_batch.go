@@ -0,0 +1,191 @@
+package services
+
+import (
+	"fmt"
+	"hd_psi/backend/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// batchAllocation 库存变动在单个批次上的分摊
+type batchAllocation struct {
+	batch    *models.InventoryBatch
+	quantity int
+}
+
+// allocateBatches 将库存变动分摊到批次并更新批次余额
+// 入库记入交易指定的批次；出库优先扣减指定批次，不足部分按先进先出扣减其他批次
+func allocateBatches(tx *gorm.DB, transaction *models.InventoryTransaction) ([]batchAllocation, error) {
+	var allocations []batchAllocation
+
+	take := func(batch *models.InventoryBatch, quantity int) error {
+		batch.Quantity += quantity
+		if err := tx.Save(batch).Error; err != nil {
+			return fmt.Errorf("更新批次库存失败: %w", err)
+		}
+		allocations = append(allocations, batchAllocation{batch: batch, quantity: quantity})
+		return nil
+	}
+
+	if transaction.Quantity > 0 {
+		batch, err := lockBatch(tx, transaction.StoreID, transaction.ProductID, transaction.BatchNumber)
+		if err != nil {
+			return nil, err
+		}
+		if batch.ReceivingID == nil && transaction.ReferenceType == "purchase_receiving" {
+			batch.ReceivingID = transaction.ReferenceID
+		}
+		if err := take(batch, transaction.Quantity); err != nil {
+			return nil, err
+		}
+		return allocations, nil
+	}
+
+	remaining := -transaction.Quantity
+	used := make(map[uint]bool)
+
+	// 扫码指定批次优先出库
+	if transaction.BatchNumber != "" {
+		batch, err := lockBatch(tx, transaction.StoreID, transaction.ProductID, transaction.BatchNumber)
+		if err != nil {
+			return nil, err
+		}
+		used[batch.ID] = true
+		if qty := min(batch.Quantity, remaining); qty > 0 {
+			if err := take(batch, -qty); err != nil {
+				return nil, err
+			}
+			remaining -= qty
+		}
+	}
+
+	// 先进先出扣减其余批次
+	if remaining > 0 {
+		var batches []models.InventoryBatch
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("store_id = ? AND product_id = ? AND quantity > 0", transaction.StoreID, transaction.ProductID).
+			Order("received_at, id").
+			Find(&batches).Error; err != nil {
+			return nil, fmt.Errorf("查询批次库存失败: %w", err)
+		}
+		for i := range batches {
+			if remaining == 0 {
+				break
+			}
+			if used[batches[i].ID] {
+				continue
+			}
+			qty := min(batches[i].Quantity, remaining)
+			if err := take(&batches[i], -qty); err != nil {
+				return nil, err
+			}
+			remaining -= qty
+		}
+	}
+
+	// 批次余额不足（允许负库存时）记入未登记批次
+	if remaining > 0 {
+		batch, err := lockBatch(tx, transaction.StoreID, transaction.ProductID, "")
+		if err != nil {
+			return nil, err
+		}
+		if err := take(batch, -remaining); err != nil {
+			return nil, err
+		}
+	}
+
+	// 只涉及一个批次时交易记录直接登记批次号
+	if len(allocations) == 1 {
+		transaction.BatchNumber = allocations[0].batch.BatchNumber
+	}
+
+	return allocations, nil
+}
+
+// recordBatchMovements 写入库存交易的批次分摊明细
+func recordBatchMovements(tx *gorm.DB, transactionID uint, allocations []batchAllocation) error {
+	movements := make([]models.InventoryBatchMovement, 0, len(allocations))
+	for _, a := range allocations {
+		movements = append(movements, models.InventoryBatchMovement{
+			TransactionID: transactionID,
+			BatchID:       a.batch.ID,
+			BatchNumber:   a.batch.BatchNumber,
+			Quantity:      a.quantity,
+		})
+	}
+	if len(movements) == 0 {
+		return nil
+	}
+	if err := tx.Create(&movements).Error; err != nil {
+		return fmt.Errorf("创建批次分摊明细失败: %w", err)
+	}
+	return nil
+}
+
+// lockBatch 锁定店铺商品的指定批次，不存在时创建零库存批次
+func lockBatch(tx *gorm.DB, storeID, productID uint, batchNumber string) (*models.InventoryBatch, error) {
+	var batch models.InventoryBatch
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("store_id = ? AND product_id = ? AND batch_number = ?", storeID, productID, batchNumber).
+		Limit(1).Find(&batch)
+	if result.Error != nil {
+		return nil, fmt.Errorf("查询批次库存失败: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return &batch, nil
+	}
+
+	batch = models.InventoryBatch{
+		StoreID:     storeID,
+		ProductID:   productID,
+		BatchNumber: batchNumber,
+		ReceivedAt:  time.Now(),
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&batch).Error; err != nil {
+		return nil, fmt.Errorf("创建批次库存失败: %w", err)
+	}
+
+	batch = models.InventoryBatch{}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("store_id = ? AND product_id = ? AND batch_number = ?", storeID, productID, batchNumber).
+		First(&batch).Error; err != nil {
+		return nil, fmt.Errorf("查询批次库存失败: %w", err)
+	}
+	return &batch, nil
+}
+
+// SeedOpeningBatches 为批次余额之和与库存余额不一致的记录补齐未登记批次
+// 启用批次管理前已有的库存会全部记入未登记批次
+func SeedOpeningBatches(db *gorm.DB) error {
+	var rows []struct {
+		StoreID   uint
+		ProductID uint
+		Quantity  int
+		Batched   int
+	}
+
+	if err := db.Table("inventories").
+		Select("inventories.store_id, inventories.product_id, inventories.quantity, COALESCE(SUM(inventory_batches.quantity), 0) AS batched").
+		Joins("LEFT JOIN inventory_batches ON inventory_batches.store_id = inventories.store_id AND inventory_batches.product_id = inventories.product_id").
+		Group("inventories.id, inventories.store_id, inventories.product_id, inventories.quantity").
+		Having("inventories.quantity <> COALESCE(SUM(inventory_batches.quantity), 0)").
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, row := range rows {
+			batch, err := lockBatch(tx, row.StoreID, row.ProductID, "")
+			if err != nil {
+				return err
+			}
+			batch.Quantity += row.Quantity - row.Batched
+			if err := tx.Save(batch).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"fmt"
+	"hd_psi/backend/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CreateInventoryCheck 在事务tx中创建盘点单和盘点明细
+// 全盘包含店铺所有有库存的商品；抽盘只包含 productIDs 中在店铺有库存记录的商品
+// 返回：
+//   - int: 盘点明细数
+//   - error: 创建失败时返回错误
+func CreateInventoryCheck(tx *gorm.DB, check *models.InventoryCheck, productIDs []uint) (int, error) {
+	// 生成盘点单号 (格式: IC + 年月日 + 4位序号)
+	now := time.Now()
+	var count int64
+	tx.Model(&models.InventoryCheck{}).Where("DATE(created_at) = DATE(?)", now).Count(&count)
+	check.CheckCode = fmt.Sprintf("IC%s%04d", now.Format("20060102"), count+1)
+	check.Status = models.Planned
+
+	if err := tx.Create(check).Error; err != nil {
+		return 0, fmt.Errorf("创建盘点单失败: %w", err)
+	}
+
+	// 根据盘点类型获取商品库存
+	query := tx.Model(&models.Inventory{}).Where("store_id = ?", check.StoreID)
+	if check.CheckType == models.FullCheck {
+		query = query.Where("quantity > 0 OR quarantine_qty > 0")
+	} else if len(productIDs) > 0 {
+		query = query.Where("product_id IN ?", productIDs)
+	} else {
+		return 0, nil
+	}
+
+	var inventories []models.Inventory
+	if err := query.Order("product_id").Find(&inventories).Error; err != nil {
+		return 0, fmt.Errorf("查询库存失败: %w", err)
+	}
+	if len(inventories) == 0 {
+		return 0, nil
+	}
+
+	items := make([]models.InventoryCheckItem, 0, len(inventories))
+	for _, inv := range inventories {
+		items = append(items, models.InventoryCheckItem{
+			CheckID:        check.ID,
+			ProductID:      inv.ProductID,
+			SystemQuantity: inv.Quantity,
+			QuarantineQty:  inv.QuarantineQty,
+			Status:         models.CheckItemPending,
+		})
+	}
+	if err := tx.CreateInBatches(&items, 500).Error; err != nil {
+		return 0, fmt.Errorf("创建盘点明细失败: %w", err)
+	}
+
+	return len(items), nil
+}
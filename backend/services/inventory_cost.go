@@ -0,0 +1,102 @@
+package services
+
+import (
+	"fmt"
+	"hd_psi/backend/models"
+	"math"
+
+	"gorm.io/gorm"
+)
+
+// applyCost 按移动加权平均法更新库存单位成本，并在交易上登记单位成本
+// 带成本的入库按数量加权混合；冲减采购入库（数量为负的采购入库）按采购单价冲回；
+// 其余出库忽略交易上填写的成本，与其他交易一样按当前平均成本登记，不改变平均成本
+func applyCost(tx *gorm.DB, inventory *models.Inventory, transaction *models.InventoryTransaction) error {
+	before := float64(inventory.Quantity)
+	after := before + float64(transaction.Quantity)
+	quantity := float64(transaction.Quantity)
+
+	if transaction.Quantity < 0 && transaction.TransactionType != models.PurchaseIn {
+		transaction.UnitCost = 0
+	}
+
+	switch {
+	case transaction.UnitCost > 0 && transaction.Quantity > 0:
+		if before <= 0 || after <= 0 {
+			inventory.AvgCost = transaction.UnitCost
+		} else {
+			inventory.AvgCost = (before*inventory.AvgCost + quantity*transaction.UnitCost) / after
+		}
+	case transaction.UnitCost > 0 && transaction.Quantity < 0:
+		if after > 0 {
+			inventory.AvgCost = math.Max((before*inventory.AvgCost+quantity*transaction.UnitCost)/after, 0)
+		}
+	default:
+		// 尚无成本记录时以商品成本价作为初始成本
+		if inventory.AvgCost == 0 {
+			var product models.Product
+			if err := tx.Select("cost_price").First(&product, transaction.ProductID).Error; err != nil {
+				return fmt.Errorf("查询商品成本价失败: %w", err)
+			}
+			inventory.AvgCost = product.CostPrice
+		}
+		transaction.UnitCost = inventory.AvgCost
+	}
+
+	inventory.AvgCost = roundCost(inventory.AvgCost)
+	transaction.UnitCost = roundCost(transaction.UnitCost)
+	return nil
+}
+
+// SourceDocumentCost 按关联单据确定入库单位成本，用于手工登记的库存交易
+// 采购单取采购单价，销售单和调拨单取原出库成本；没有关联单据或单据中没有该商品时返回0，按当前平均成本登记
+func SourceDocumentCost(db *gorm.DB, transaction *models.InventoryTransaction) (float64, error) {
+	if transaction.ReferenceID == nil {
+		return 0, nil
+	}
+
+	var outType models.TransactionType
+	switch transaction.ReferenceType {
+	case "purchase_order":
+		var prices []float64
+		if err := db.Model(&models.PurchaseOrderItem{}).
+			Where("purchase_order_id = ? AND product_id = ?", *transaction.ReferenceID, transaction.ProductID).
+			Order("id").Limit(1).Pluck("unit_price", &prices).Error; err != nil {
+			return 0, fmt.Errorf("查询采购单价失败: %w", err)
+		}
+		if len(prices) == 0 {
+			return 0, nil
+		}
+		return prices[0], nil
+	case "sales_order":
+		outType = models.SaleOut
+	case "transfer_order":
+		outType = models.TransferOut
+	default:
+		return 0, nil
+	}
+
+	var cost float64
+	if err := db.Model(&models.InventoryTransaction{}).
+		Select("COALESCE(SUM(quantity * unit_cost) / NULLIF(SUM(quantity), 0), 0)").
+		Where("reference_type = ? AND reference_id = ? AND product_id = ? AND transaction_type = ?",
+			transaction.ReferenceType, *transaction.ReferenceID, transaction.ProductID, outType).
+		Scan(&cost).Error; err != nil {
+		return 0, fmt.Errorf("查询出库成本失败: %w", err)
+	}
+	return cost, nil
+}
+
+// roundCost 单位成本保留4位小数
+func roundCost(cost float64) float64 {
+	return math.Round(cost*10000) / 10000
+}
+
+// SeedAverageCosts 为尚无平均成本的库存记录以商品成本价初始化平均成本
+// 启用成本核算前已有的库存按商品成本价计价
+func SeedAverageCosts(db *gorm.DB) error {
+	return db.Exec(`UPDATE inventories
+		JOIN products ON products.id = inventories.product_id
+		SET inventories.avg_cost = products.cost_price
+		WHERE inventories.avg_cost = 0 AND inventories.quantity <> 0`).Error
+}
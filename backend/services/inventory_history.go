@@ -0,0 +1,165 @@
+package services
+
+import (
+	"fmt"
+	"hd_psi/backend/models"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AsOfFilter 历史库存查询条件，零值表示不限
+type AsOfFilter struct {
+	StoreID    uint
+	ProductID  uint
+	CategoryID uint // 包含子类别
+}
+
+// StockAsOf 某一时刻的店铺商品库存
+type StockAsOf struct {
+	StoreID    uint   `json:"store_id"`
+	ProductID  uint   `json:"product_id"`
+	CategoryID *uint  `json:"category_id"`
+	Category   string `json:"category"`
+	Quantity   int    `json:"quantity"`
+}
+
+// InventoryAsOf 回放可售库存交易，计算at时刻（不含）之前的库存数量
+// 优先以at之前最近一天的库存快照为基数，只回放快照之后的交易
+// 返回：
+//   - []StockAsOf: 数量不为0的店铺商品库存
+//   - *time.Time: 作为基数的快照日期，未使用快照时为nil
+//   - error: 查询失败时返回错误
+func InventoryAsOf(db *gorm.DB, at time.Time, filter AsOfFilter) ([]StockAsOf, *time.Time, error) {
+	return inventoryAsOf(db, at, startOfDay(at), filter)
+}
+
+// inventoryAsOf 以 snapshotBefore 之前最近一天的快照为基数，回放交易计算at时刻之前的库存
+func inventoryAsOf(db *gorm.DB, at, snapshotBefore time.Time, filter AsOfFilter) ([]StockAsOf, *time.Time, error) {
+	type key struct{ storeID, productID uint }
+	stocks := make(map[key]*StockAsOf)
+	add := func(rows []StockAsOf) {
+		for _, row := range rows {
+			k := key{row.StoreID, row.ProductID}
+			if s, ok := stocks[k]; ok {
+				s.Quantity += row.Quantity
+			} else {
+				r := row
+				stocks[k] = &r
+			}
+		}
+	}
+
+	// 查找 snapshotBefore 之前已经结束的最近一天快照
+	var snapshotDate *time.Time
+	var latest models.InventorySnapshot
+	result := db.Where("snapshot_date < ?", snapshotBefore).
+		Order("snapshot_date DESC").Limit(1).Find(&latest)
+	if result.Error != nil {
+		return nil, nil, fmt.Errorf("查询库存快照失败: %w", result.Error)
+	}
+
+	replayFrom := time.Time{}
+	if result.RowsAffected > 0 {
+		date := latest.SnapshotDate
+		snapshotDate = &date
+		replayFrom = startOfDay(date).AddDate(0, 0, 1)
+
+		var rows []StockAsOf
+		query := applyAsOfFilter(db.Table("inventory_snapshots").
+			Select("inventory_snapshots.store_id, inventory_snapshots.product_id, products.category_id, products.category, inventory_snapshots.quantity").
+			Joins("LEFT JOIN products ON products.id = inventory_snapshots.product_id").
+			Where("inventory_snapshots.snapshot_date = ?", date), "inventory_snapshots", filter)
+		if err := query.Scan(&rows).Error; err != nil {
+			return nil, nil, fmt.Errorf("查询库存快照失败: %w", err)
+		}
+		add(rows)
+	}
+
+	// 回放快照之后的库存交易
+	var rows []StockAsOf
+	query := applyAsOfFilter(db.Table("inventory_transactions").
+		Select("inventory_transactions.store_id, inventory_transactions.product_id, products.category_id, products.category, SUM(inventory_transactions.quantity) AS quantity").
+		Joins("LEFT JOIN products ON products.id = inventory_transactions.product_id").
+		Where("inventory_transactions.created_at >= ? AND inventory_transactions.created_at < ?", replayFrom, at).
+		Where("inventory_transactions.bucket = ?", models.BucketSellable), "inventory_transactions", filter)
+	if err := query.Group("inventory_transactions.store_id, inventory_transactions.product_id, products.category_id, products.category").
+		Scan(&rows).Error; err != nil {
+		return nil, nil, fmt.Errorf("回放库存交易失败: %w", err)
+	}
+	add(rows)
+
+	items := make([]StockAsOf, 0, len(stocks))
+	for _, s := range stocks {
+		if s.Quantity != 0 {
+			items = append(items, *s)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].StoreID != items[j].StoreID {
+			return items[i].StoreID < items[j].StoreID
+		}
+		return items[i].ProductID < items[j].ProductID
+	})
+
+	return items, snapshotDate, nil
+}
+
+// BuildDailySnapshot 生成指定日期结束时的库存快照，已存在的同日快照会被覆盖
+// 以该日期之前最近一天的快照为基数重新回放，不沿用同日的旧快照，错误的快照可以重建修正
+// 返回：
+//   - int: 快照记录数
+//   - error: 生成失败时返回错误
+func BuildDailySnapshot(db *gorm.DB, day time.Time) (int, error) {
+	date := startOfDay(day)
+	items, _, err := inventoryAsOf(db, date.AddDate(0, 0, 1), date, AsOfFilter{})
+	if err != nil {
+		return 0, err
+	}
+
+	snapshots := make([]models.InventorySnapshot, 0, len(items))
+	for _, item := range items {
+		snapshots = append(snapshots, models.InventorySnapshot{
+			SnapshotDate: date,
+			StoreID:      item.StoreID,
+			ProductID:    item.ProductID,
+			Quantity:     item.Quantity,
+		})
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("snapshot_date = ?", date).Delete(&models.InventorySnapshot{}).Error; err != nil {
+			return err
+		}
+		if len(snapshots) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(&snapshots, 500).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("保存库存快照失败: %w", err)
+	}
+
+	return len(snapshots), nil
+}
+
+// applyAsOfFilter 为历史库存查询添加店铺、商品和品类条件
+func applyAsOfFilter(query *gorm.DB, table string, filter AsOfFilter) *gorm.DB {
+	if filter.StoreID != 0 {
+		query = query.Where(table+".store_id = ?", filter.StoreID)
+	}
+	if filter.ProductID != 0 {
+		query = query.Where(table+".product_id = ?", filter.ProductID)
+	}
+	if filter.CategoryID != 0 {
+		query = ApplyCategoryFilter(query, "products.category_id", filter.CategoryID)
+	}
+	return query
+}
+
+// startOfDay 返回t所在日期的零点（本地时区）
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.In(time.Local).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"hd_psi/backend/config"
+	"hd_psi/backend/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrInvalidQuantity 库存变动数量无效
+	ErrInvalidQuantity = errors.New("库存变动数量不能为0")
+	// ErrReservationInactive 库存预留已释放或已转出库
+	ErrReservationInactive = errors.New("库存预留已失效")
+	// ErrInvalidTransactionType 交易类型不能通过库存台账登记
+	ErrInvalidTransactionType = errors.New("库存交易类型无效")
+	// ErrQuantityDirection 库存变动数量的正负与交易类型不符
+	ErrQuantityDirection = errors.New("库存变动数量与交易类型的出入库方向不符")
+)
+
+// transactionDirections 可通过台账登记的交易类型及其数量方向：1 只能入库，-1 只能出库，0 两个方向都可以
+// 采购入库可为负数冲减（取消收货），台账修正只补记交易，不通过台账登记
+var transactionDirections = map[models.TransactionType]int{
+	models.PurchaseIn:        0,
+	models.ReturnIn:          1,
+	models.TransferIn:        1,
+	models.SaleOut:           -1,
+	models.ExchangeOut:       -1,
+	models.DamageOut:         -1,
+	models.TransferOut:       -1,
+	models.CheckAdjust:       0,
+	models.QuarantineRelease: 0,
+	models.SupplierReturn:    -1,
+}
+
+// InsufficientStockError 库存不足错误
+type InsufficientStockError struct {
+	StoreID   uint
+	ProductID uint
+	Available int // 当前可用数量
+	Requested int // 请求出库数量
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("店铺 %d 商品 %d 库存不足: 可用 %d, 需要 %d", e.StoreID, e.ProductID, e.Available, e.Requested)
+}
+
+// InventoryLedger 库存台账服务
+// 库存余额(Inventory)和库存交易(InventoryTransaction)只能通过台账服务同时变更
+type InventoryLedger struct {
+	db            *gorm.DB
+	allowNegative bool // 是否允许负库存
+}
+
+// NewInventoryLedger 创建库存台账服务，负库存策略从配置读取
+func NewInventoryLedger(db *gorm.DB) *InventoryLedger {
+	return &InventoryLedger{db: db, allowNegative: config.AllowNegativeStock()}
+}
+
+// Post 在事务tx中记录一笔库存变动
+// 校验盘点冻结，锁定店铺商品的库存行，校验负库存策略，更新余额、平均成本和批次余额并写入库存交易记录，
+// 最后评估该店铺商品的库存预警
+// 参数：
+//   - tx: 调用方开启的数据库事务
+//   - transaction: 待写入的库存交易，Quantity 正数入库、负数出库；
+//     UnitCost 为0时按当前平均成本登记，出库除冲减采购入库外忽略 UnitCost；Bucket 为隔离区时只变更隔离库存
+//
+// 返回：
+//   - *models.Inventory: 变动后的库存余额
+//   - error: 库存不足时返回 *InsufficientStockError，交易类型无效或数量方向不符时返回对应错误
+func (l *InventoryLedger) Post(tx *gorm.DB, transaction *models.InventoryTransaction) (*models.Inventory, error) {
+	if transaction.Quantity == 0 {
+		return nil, ErrInvalidQuantity
+	}
+	direction, ok := transactionDirections[transaction.TransactionType]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTransactionType, transaction.TransactionType)
+	}
+	if direction*transaction.Quantity < 0 {
+		return nil, fmt.Errorf("%w: %s 数量 %d", ErrQuantityDirection, transaction.TransactionType, transaction.Quantity)
+	}
+
+	// 全盘冻结期间禁止调拨和采购入库，这些交易锁定店铺
+	if err := l.checkFreeze(tx, transaction); err != nil {
+		return nil, err
+	}
+
+	if transaction.Bucket == models.BucketQuarantine {
+		return l.postQuarantine(tx, transaction)
+	}
+
+	inventory, err := l.lockInventory(tx, transaction.StoreID, transaction.ProductID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 校验负库存策略，已预留的库存不能再出库
+	available := inventory.Quantity - inventory.Reserved
+	if transaction.Quantity < 0 && !l.allowNegative && available+transaction.Quantity < 0 {
+		return nil, &InsufficientStockError{
+			StoreID:   transaction.StoreID,
+			ProductID: transaction.ProductID,
+			Available: available,
+			Requested: -transaction.Quantity,
+		}
+	}
+
+	// 更新移动加权平均成本并登记交易成本
+	if err := applyCost(tx, inventory, transaction); err != nil {
+		return nil, err
+	}
+
+	inventory.Quantity += transaction.Quantity
+	if err := tx.Save(inventory).Error; err != nil {
+		return nil, fmt.Errorf("更新库存失败: %w", err)
+	}
+
+	// 分摊到批次库存
+	allocations, err := allocateBatches(tx, transaction)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Create(transaction).Error; err != nil {
+		return nil, fmt.Errorf("创建库存交易记录失败: %w", err)
+	}
+
+	if err := recordBatchMovements(tx, transaction.ID, allocations); err != nil {
+		return nil, err
+	}
+
+	// 评估变动后的库存预警
+	if err := l.evaluateAlerts(tx, inventory); err != nil {
+		return nil, err
+	}
+
+	return inventory, nil
+}
+
+// Reserve 在事务tx中为线上订单预留库存，预留数量不能超过可售数量
+func (l *InventoryLedger) Reserve(tx *gorm.DB, reservation *models.StockReservation) (*models.Inventory, error) {
+	if reservation.Quantity <= 0 {
+		return nil, ErrInvalidQuantity
+	}
+
+	inventory, err := l.lockInventory(tx, reservation.StoreID, reservation.ProductID)
+	if err != nil {
+		return nil, err
+	}
+
+	available := inventory.Quantity - inventory.Reserved
+	if !l.allowNegative && available < reservation.Quantity {
+		return nil, &InsufficientStockError{
+			StoreID:   reservation.StoreID,
+			ProductID: reservation.ProductID,
+			Available: available,
+			Requested: reservation.Quantity,
+		}
+	}
+
+	inventory.Reserved += reservation.Quantity
+	if err := tx.Save(inventory).Error; err != nil {
+		return nil, fmt.Errorf("更新库存失败: %w", err)
+	}
+
+	reservation.Status = models.ReservationActive
+	if err := tx.Create(reservation).Error; err != nil {
+		return nil, fmt.Errorf("创建库存预留失败: %w", err)
+	}
+
+	return inventory, nil
+}
+
+// Release 在事务tx中释放预留库存，status 为释放后的预留状态
+func (l *InventoryLedger) Release(tx *gorm.DB, reservation *models.StockReservation, status models.ReservationStatus) (*models.Inventory, error) {
+	if reservation.Status != models.ReservationActive {
+		return nil, ErrReservationInactive
+	}
+
+	inventory, err := l.lockInventory(tx, reservation.StoreID, reservation.ProductID)
+	if err != nil {
+		return nil, err
+	}
+
+	inventory.Reserved -= reservation.Quantity
+	if inventory.Reserved < 0 {
+		inventory.Reserved = 0
+	}
+	if err := tx.Save(inventory).Error; err != nil {
+		return nil, fmt.Errorf("更新库存失败: %w", err)
+	}
+
+	now := time.Now()
+	reservation.Status = status
+	reservation.ReleasedAt = &now
+	if err := tx.Save(reservation).Error; err != nil {
+		return nil, fmt.Errorf("更新库存预留失败: %w", err)
+	}
+
+	return inventory, nil
+}
+
+// Convert 在事务tx中将预留转为出库：先释放预留再记录出库交易
+func (l *InventoryLedger) Convert(tx *gorm.DB, reservation *models.StockReservation, transaction *models.InventoryTransaction) (*models.Inventory, error) {
+	if _, err := l.Release(tx, reservation, models.ReservationConverted); err != nil {
+		return nil, err
+	}
+	return l.Post(tx, transaction)
+}
+
+// lockInventory 以 SELECT ... FOR UPDATE 锁定库存行，不存在时先创建零库存记录
+func (l *InventoryLedger) lockInventory(tx *gorm.DB, storeID, productID uint) (*models.Inventory, error) {
+	var inventory models.Inventory
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("store_id = ? AND product_id = ?", storeID, productID).
+		Limit(1).Find(&inventory)
+	if result.Error != nil {
+		return nil, fmt.Errorf("查询库存记录失败: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return &inventory, nil
+	}
+
+	// 并发创建时依赖唯一索引，冲突的一方忽略插入后重新加锁读取
+	inventory = models.Inventory{StoreID: storeID, ProductID: productID}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&inventory).Error; err != nil {
+		return nil, fmt.Errorf("创建库存记录失败: %w", err)
+	}
+
+	inventory = models.Inventory{}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("store_id = ? AND product_id = ?", storeID, productID).
+		First(&inventory).Error; err != nil {
+		return nil, fmt.Errorf("查询库存记录失败: %w", err)
+	}
+	return &inventory, nil
+}
+
+// MergeDuplicateInventories 合并同一店铺商品的重复库存记录，保留ID最小的记录
+// 需在 AutoMigrate 创建店铺商品唯一索引前执行：数量累加，平均成本按数量加权，
+// 唯一索引已存在或库存表尚未创建时不做处理
+// 返回：
+//   - int: 删除的重复记录数
+//   - error: 合并失败时返回错误
+func MergeDuplicateInventories(db *gorm.DB) (int, error) {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&models.Inventory{}) || migrator.HasIndex(&models.Inventory{}, "idx_inventory_store_product") {
+		return 0, nil
+	}
+
+	// 旧表可能还没有后续增加的字段，只合并已存在的字段
+	selects := []string{"SUM(quantity) AS quantity"}
+	sets := []string{"inventories.quantity = merged.quantity"}
+	for _, column := range []string{"reserved", "quarantine_qty"} {
+		if migrator.HasColumn(&models.Inventory{}, column) {
+			selects = append(selects, fmt.Sprintf("SUM(%s) AS %s", column, column))
+			sets = append(sets, fmt.Sprintf("inventories.%s = merged.%s", column, column))
+		}
+	}
+	if migrator.HasColumn(&models.Inventory{}, "avg_cost") {
+		selects = append(selects, "SUM(quantity * avg_cost) / NULLIF(SUM(quantity), 0) AS avg_cost")
+		sets = append(sets, "inventories.avg_cost = CASE WHEN merged.avg_cost > 0 THEN merged.avg_cost ELSE inventories.avg_cost END")
+	}
+
+	var deleted int
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`UPDATE inventories
+			JOIN (SELECT MIN(id) AS keep_id, ` + strings.Join(selects, ", ") + `
+				FROM inventories GROUP BY store_id, product_id HAVING COUNT(*) > 1) merged
+			ON inventories.id = merged.keep_id
+			SET ` + strings.Join(sets, ", ")).Error; err != nil {
+			return fmt.Errorf("合并重复库存记录失败: %w", err)
+		}
+
+		result := tx.Exec(`DELETE inventories FROM inventories
+			JOIN (SELECT store_id, product_id, MIN(id) AS keep_id
+				FROM inventories GROUP BY store_id, product_id HAVING COUNT(*) > 1) dup
+			ON inventories.store_id = dup.store_id AND inventories.product_id = dup.product_id AND inventories.id <> dup.keep_id`)
+		if result.Error != nil {
+			return fmt.Errorf("删除重复库存记录失败: %w", result.Error)
+		}
+		deleted = int(result.RowsAffected)
+		return nil
+	})
+	return deleted, err
+}
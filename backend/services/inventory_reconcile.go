@@ -0,0 +1,150 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"hd_psi/backend/models"
+	"sort"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrReasonRequired 修正库存台账时未填写原因
+var ErrReasonRequired = errors.New("请填写台账修正原因")
+
+// InventoryDrift 库存余额与台账合计不一致的记录
+type InventoryDrift struct {
+	StoreID    uint               `json:"store_id"`
+	ProductID  uint               `json:"product_id"`
+	Bucket     models.StockBucket `json:"bucket"`     // 库存分区，空表示可售库存
+	Balance    int                `json:"balance"`    // 库存余额
+	Ledger     int                `json:"ledger"`     // 库存交易合计
+	Difference int                `json:"difference"` // 余额减台账合计
+}
+
+// FindInventoryDrift 按店铺商品和库存分区汇总库存交易，与可售库存和隔离库存余额比对后返回不一致的记录
+// storeID、productID 为0时不限
+func FindInventoryDrift(db *gorm.DB, storeID, productID uint) ([]InventoryDrift, error) {
+	type key struct {
+		storeID, productID uint
+		bucket             models.StockBucket
+	}
+	drifts := make(map[key]*InventoryDrift)
+
+	balances := db.Model(&models.Inventory{}).Select("store_id, product_id, quantity, quarantine_qty")
+	ledger := db.Model(&models.InventoryTransaction{}).
+		Select("store_id, product_id, bucket, SUM(quantity) AS ledger").
+		Group("store_id, product_id, bucket")
+	if storeID != 0 {
+		balances = balances.Where("store_id = ?", storeID)
+		ledger = ledger.Where("store_id = ?", storeID)
+	}
+	if productID != 0 {
+		balances = balances.Where("product_id = ?", productID)
+		ledger = ledger.Where("product_id = ?", productID)
+	}
+
+	var balanceRows []struct {
+		StoreID       uint
+		ProductID     uint
+		Quantity      int
+		QuarantineQty int
+	}
+	if err := balances.Scan(&balanceRows).Error; err != nil {
+		return nil, fmt.Errorf("查询库存余额失败: %w", err)
+	}
+	for _, row := range balanceRows {
+		drifts[key{row.StoreID, row.ProductID, models.BucketSellable}] = &InventoryDrift{
+			StoreID: row.StoreID, ProductID: row.ProductID, Bucket: models.BucketSellable, Balance: row.Quantity,
+		}
+		drifts[key{row.StoreID, row.ProductID, models.BucketQuarantine}] = &InventoryDrift{
+			StoreID: row.StoreID, ProductID: row.ProductID, Bucket: models.BucketQuarantine, Balance: row.QuarantineQty,
+		}
+	}
+
+	var ledgerRows []InventoryDrift
+	if err := ledger.Scan(&ledgerRows).Error; err != nil {
+		return nil, fmt.Errorf("汇总库存交易失败: %w", err)
+	}
+	for _, row := range ledgerRows {
+		k := key{row.StoreID, row.ProductID, row.Bucket}
+		if d, ok := drifts[k]; ok {
+			d.Ledger = row.Ledger
+		} else {
+			r := row
+			drifts[k] = &r
+		}
+	}
+
+	var result []InventoryDrift
+	for _, d := range drifts {
+		d.Difference = d.Balance - d.Ledger
+		if d.Difference != 0 {
+			result = append(result, *d)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].StoreID != result[j].StoreID {
+			return result[i].StoreID < result[j].StoreID
+		}
+		if result[i].ProductID != result[j].ProductID {
+			return result[i].ProductID < result[j].ProductID
+		}
+		return result[i].Bucket < result[j].Bucket
+	})
+
+	return result, nil
+}
+
+// RepairDrift 在事务tx中为店铺商品指定分区写入台账修正交易，使台账合计与库存余额一致
+// 以库存余额为准，只补记交易记录，不变更库存余额和批次余额
+// 返回：
+//   - *models.InventoryTransaction: 写入的修正交易，台账已一致时为nil
+//   - error: 修正失败时返回错误
+func RepairDrift(tx *gorm.DB, storeID, productID uint, bucket models.StockBucket, operatorID uint, reason string) (*models.InventoryTransaction, error) {
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+
+	// 锁定库存行后重新比对，避免与并发的库存变动交错
+	var inventory models.Inventory
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("store_id = ? AND product_id = ?", storeID, productID).
+		Limit(1).Find(&inventory)
+	if result.Error != nil {
+		return nil, fmt.Errorf("查询库存记录失败: %w", result.Error)
+	}
+
+	var ledger int
+	if err := tx.Model(&models.InventoryTransaction{}).
+		Where("store_id = ? AND product_id = ? AND bucket = ?", storeID, productID, bucket).
+		Select("COALESCE(SUM(quantity), 0)").Scan(&ledger).Error; err != nil {
+		return nil, fmt.Errorf("汇总库存交易失败: %w", err)
+	}
+
+	balance := inventory.Quantity
+	if bucket == models.BucketQuarantine {
+		balance = inventory.QuarantineQty
+	}
+	difference := balance - ledger
+	if difference == 0 {
+		return nil, nil
+	}
+
+	transaction := &models.InventoryTransaction{
+		TransactionType: models.LedgerCorrection,
+		ProductID:       productID,
+		StoreID:         storeID,
+		Quantity:        difference,
+		Bucket:          bucket,
+		ReferenceType:   "reconciliation",
+		OperatorID:      operatorID,
+		Note:            reason,
+	}
+	if err := tx.Create(transaction).Error; err != nil {
+		return nil, fmt.Errorf("创建台账修正记录失败: %w", err)
+	}
+
+	return transaction, nil
+}
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"hd_psi/backend/models"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidPrice 价格表条目无效
+	ErrInvalidPrice = errors.New("价格无效")
+	// ErrPriceEnded 价格已结束，不能再修改
+	ErrPriceEnded = errors.New("价格已结束")
+	// ErrInvalidSalePrice 成交价不在允许的范围内
+	ErrInvalidSalePrice = errors.New("成交价无效")
+)
+
+// PriceMismatchError 客户端提交的价格与服务端计算的当前价格不一致
+type PriceMismatchError struct {
+	ProductID uint
+	Expected  float64
+	Got       float64
+}
+
+func (e *PriceMismatchError) Error() string {
+	return fmt.Sprintf("商品 %d 当前售价为 %.2f，提交的价格为 %.2f", e.ProductID, e.Expected, e.Got)
+}
+
+// ResolvedPrice 某一时刻店铺商品的价格
+type ResolvedPrice struct {
+	ProductID    uint     `json:"product_id"`
+	StoreID      uint     `json:"store_id"`
+	RegularPrice float64  `json:"regular_price"`         // 常规价：门店价，没有门店价时为吊牌价
+	RegularFrom  string   `json:"regular_from"`          // 常规价来源：store、tag 或 product（商品档案零售价）
+	RegularID    uint     `json:"regular_id,omitempty"`  // 常规价对应的价格表条目
+	PromoPrice   *float64 `json:"promo_price,omitempty"` // 促销价，没有生效中的促销时为空
+	PromoID      uint     `json:"promo_id,omitempty"`
+	Price        float64  `json:"price"` // 实际售价：促销价低于常规价时取促销价
+}
+
+// ResolvePrice 计算 at 时刻店铺商品的售价
+// 常规价按 门店价 → 吊牌价 → 商品档案零售价 的顺序取第一个生效的价格
+// 促销价优先取限定该店铺的促销，其次是所有店铺通用的促销；促销价不低于常规价时不生效
+func ResolvePrice(db *gorm.DB, productID, storeID uint, at time.Time) (*ResolvedPrice, error) {
+	var product models.Product
+	if err := db.Select("id, retail_price").First(&product, productID).Error; err != nil {
+		return nil, fmt.Errorf("商品 %d 不存在", productID)
+	}
+
+	var prices []models.ProductPrice
+	if err := db.Where("product_id = ? AND start_at <= ? AND (end_at IS NULL OR end_at > ?)", productID, at, at).
+		Where("store_id IS NULL OR store_id = ?", storeID).
+		Order("start_at DESC, id DESC").
+		Find(&prices).Error; err != nil {
+		return nil, fmt.Errorf("查询价格表失败: %w", err)
+	}
+
+	resolved := &ResolvedPrice{
+		ProductID:    productID,
+		StoreID:      storeID,
+		RegularPrice: product.RetailPrice,
+		RegularFrom:  "product",
+	}
+
+	// 价格已按开始时间倒序排列，每类取第一个匹配的条目
+	var store, tag, storePromo, promo *models.ProductPrice
+	for i := range prices {
+		p := &prices[i]
+		switch {
+		case p.Type == models.PriceStore && p.StoreID != nil && store == nil:
+			store = p
+		case p.Type == models.PriceTag && tag == nil:
+			tag = p
+		case p.Type == models.PricePromo && p.StoreID != nil && storePromo == nil:
+			storePromo = p
+		case p.Type == models.PricePromo && p.StoreID == nil && promo == nil:
+			promo = p
+		}
+	}
+
+	if store != nil {
+		resolved.RegularPrice, resolved.RegularFrom, resolved.RegularID = store.Price, string(models.PriceStore), store.ID
+	} else if tag != nil {
+		resolved.RegularPrice, resolved.RegularFrom, resolved.RegularID = tag.Price, string(models.PriceTag), tag.ID
+	}
+	resolved.Price = resolved.RegularPrice
+
+	if storePromo != nil {
+		promo = storePromo
+	}
+	if promo != nil && promo.Price < resolved.RegularPrice {
+		price := promo.Price
+		resolved.PromoPrice = &price
+		resolved.PromoID = promo.ID
+		resolved.Price = price
+	}
+
+	return resolved, nil
+}
+
+// CheckSalePrice 校验客户端提交的零售价与 at 时刻的售价一致，允许1分以内的误差
+func CheckSalePrice(db *gorm.DB, productID, storeID uint, at time.Time, price float64) (*ResolvedPrice, error) {
+	resolved, err := ResolvePrice(db, productID, storeID, at)
+	if err != nil {
+		return nil, err
+	}
+	if math.Abs(resolved.Price-price) >= 0.01 {
+		return resolved, &PriceMismatchError{ProductID: productID, Expected: resolved.Price, Got: price}
+	}
+	return resolved, nil
+}
+
+// CheckActualPrice 校验成交价大于0且不高于售价，minRate 大于0时成交价不能低于售价的 minRate 倍
+func CheckActualPrice(resolved *ResolvedPrice, actual, minRate float64) error {
+	if actual <= 0 {
+		return fmt.Errorf("%w: 商品 %d 成交价必须大于0", ErrInvalidSalePrice, resolved.ProductID)
+	}
+	if actual-resolved.Price >= 0.01 {
+		return fmt.Errorf("%w: 商品 %d 成交价 %.2f 高于售价 %.2f", ErrInvalidSalePrice, resolved.ProductID, actual, resolved.Price)
+	}
+	if minPrice := math.Round(resolved.Price*minRate*100) / 100; minRate > 0 && minPrice-actual >= 0.01 {
+		return fmt.Errorf("%w: 商品 %d 成交价 %.2f 低于最低成交价 %.2f", ErrInvalidSalePrice, resolved.ProductID, actual, minPrice)
+	}
+	return nil
+}
+
+// CreatePrice 新建价格表条目并记录历史
+func CreatePrice(tx *gorm.DB, price *models.ProductPrice, operatorID uint) error {
+	if err := validatePrice(price); err != nil {
+		return err
+	}
+	var count int64
+	if err := tx.Model(&models.Product{}).Where("id = ?", price.ProductID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: 商品 %d 不存在", ErrInvalidPrice, price.ProductID)
+	}
+
+	price.Price = roundAmount(price.Price)
+	price.CreatedBy = operatorID
+	if err := tx.Create(price).Error; err != nil {
+		return fmt.Errorf("保存价格失败: %w", err)
+	}
+	return recordPriceHistory(tx, nil, price, models.PriceCreated, operatorID)
+}
+
+// UpdatePrice 修改价格表条目的价格、生效区间和备注并记录历史，已结束的条目不能修改
+func UpdatePrice(tx *gorm.DB, price *models.ProductPrice, amount float64, startAt time.Time, endAt *time.Time, note string, operatorID uint) error {
+	if price.EndAt != nil && !price.EndAt.After(time.Now()) {
+		return ErrPriceEnded
+	}
+
+	old := *price
+	price.Price = roundAmount(amount)
+	price.StartAt = startAt
+	price.EndAt = endAt
+	price.Note = note
+	if err := validatePrice(price); err != nil {
+		return err
+	}
+
+	if err := tx.Save(price).Error; err != nil {
+		return fmt.Errorf("保存价格失败: %w", err)
+	}
+	return recordPriceHistory(tx, &old, price, models.PriceUpdated, operatorID)
+}
+
+// EndPrice 在 at 时刻提前结束价格表条目并记录历史
+func EndPrice(tx *gorm.DB, price *models.ProductPrice, at time.Time, operatorID uint) error {
+	if price.EndAt != nil && !price.EndAt.After(at) {
+		return ErrPriceEnded
+	}
+
+	old := *price
+	if at.Before(price.StartAt) {
+		// 尚未生效的价格直接以开始时间结束，不再生效
+		at = price.StartAt
+	}
+	price.EndAt = &at
+	if err := tx.Model(price).Update("end_at", at).Error; err != nil {
+		return fmt.Errorf("结束价格失败: %w", err)
+	}
+	return recordPriceHistory(tx, &old, price, models.PriceEnded, operatorID)
+}
+
+// validatePrice 校验价格类型、金额、适用店铺和生效区间
+func validatePrice(price *models.ProductPrice) error {
+	if !price.Type.Valid() {
+		return fmt.Errorf("%w: 未知价格类型 %q", ErrInvalidPrice, price.Type)
+	}
+	if price.Price <= 0 {
+		return fmt.Errorf("%w: 价格必须大于0", ErrInvalidPrice)
+	}
+	switch price.Type {
+	case models.PriceStore:
+		if price.StoreID == nil {
+			return fmt.Errorf("%w: 门店价必须指定店铺", ErrInvalidPrice)
+		}
+	case models.PriceTag:
+		if price.StoreID != nil {
+			return fmt.Errorf("%w: 吊牌价适用于所有店铺，店铺单独定价请使用门店价", ErrInvalidPrice)
+		}
+	}
+	if price.StartAt.IsZero() {
+		return fmt.Errorf("%w: 生效时间不能为空", ErrInvalidPrice)
+	}
+	if price.EndAt != nil && !price.EndAt.After(price.StartAt) {
+		return fmt.Errorf("%w: 失效时间必须晚于生效时间", ErrInvalidPrice)
+	}
+	return nil
+}
+
+// recordPriceHistory 记录价格变更，old 为空表示新建
+func recordPriceHistory(tx *gorm.DB, old, price *models.ProductPrice, action models.PriceAction, operatorID uint) error {
+	history := models.ProductPriceHistory{
+		PriceID:    price.ID,
+		ProductID:  price.ProductID,
+		Type:       price.Type,
+		StoreID:    price.StoreID,
+		Action:     action,
+		NewPrice:   price.Price,
+		NewStartAt: price.StartAt,
+		NewEndAt:   price.EndAt,
+		OperatorID: operatorID,
+		Note:       price.Note,
+	}
+	if old != nil {
+		startAt := old.StartAt
+		history.OldPrice = old.Price
+		history.OldStartAt = &startAt
+		history.OldEndAt = old.EndAt
+	}
+	if err := tx.Create(&history).Error; err != nil {
+		return fmt.Errorf("记录价格历史失败: %w", err)
+	}
+	return nil
+}
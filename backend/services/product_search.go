@@ -0,0 +1,346 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hd_psi/backend/models"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidSearch 搜索条件无效
+	ErrInvalidSearch = errors.New("搜索条件无效")
+	// ErrInvalidCursor 分页游标无效或与排序方式不匹配
+	ErrInvalidCursor = fmt.Errorf("%w: 分页游标无效", ErrInvalidSearch)
+)
+
+// 商品搜索排序方式
+const (
+	SortRelevance = "relevance"  // 相关度，有关键词时的默认排序
+	SortSKU       = "sku"        // SKU升序，无关键词时的默认排序
+	SortName      = "name"       // 名称升序
+	SortPriceAsc  = "price_asc"  // 零售价升序
+	SortPriceDesc = "price_desc" // 零售价降序
+	SortNewest    = "newest"     // 最新创建
+)
+
+// ProductSearch 商品搜索条件，零值表示不限
+type ProductSearch struct {
+	Keyword     string // 匹配SKU、名称、颜色、尺码
+	CategoryIDs []uint // 类别树节点，包含子类别；多个取值之间为“或”
+	Colors      []string
+	Sizes       []string
+	Seasons     []string
+	StyleID     uint
+	StoreID     uint     // 指定店铺时返回该店铺的可用库存
+	InStock     bool     // 只返回指定店铺有可用库存的商品，需指定店铺
+	MinPrice    *float64 // 零售价下限（含）
+	MaxPrice    *float64 // 零售价上限（含）
+	Sort        string
+	Cursor      string // 上一页返回的 NextCursor，为空表示第一页
+	Limit       int
+	Facets      bool // 是否统计类别、颜色、尺码、季节分面
+}
+
+// ProductHit 搜索结果中的商品
+type ProductHit struct {
+	models.Product
+	Available *int `json:"available,omitempty"` // 指定店铺的可用库存（在库-预留）
+	Relevance int  `json:"-"`
+}
+
+// FacetCount 分面取值及商品数
+type FacetCount struct {
+	Value string `json:"value"`           // 类别分面为类别ID
+	Label string `json:"label,omitempty"` // 类别分面为类别名称路径，如“女装 > 外套 > 羽绒服”
+	Count int    `json:"count"`
+}
+
+// ProductSearchResult 商品搜索结果
+type ProductSearchResult struct {
+	Total      int64                   `json:"total"`
+	Items      []ProductHit            `json:"items"`
+	NextCursor string                  `json:"next_cursor"` // 为空表示没有下一页
+	Facets     map[string][]FacetCount `json:"facets,omitempty"`
+}
+
+// searchCursor 游标记录上一页最后一条的排序值和ID
+type searchCursor struct {
+	Sort  string      `json:"s"`
+	Value interface{} `json:"v"`
+	ID    uint        `json:"id"`
+}
+
+// sortSpec 排序字段
+type sortSpec struct {
+	expr string // 排序表达式
+	desc bool
+}
+
+// facetFields 分面字段，类别按 category_id 统计
+var facetFields = []string{"category_id", "color", "size", "season"}
+
+// SearchProducts 按条件搜索商品，使用游标分页
+// 关键词匹配规则：SKU前缀、名称前缀、名称包含，以及按字顺序的模糊匹配（如“男衬”匹配“男士休闲衬衫”）
+// 分面统计时每个分面不应用自身的筛选条件，便于多选
+func SearchProducts(db *gorm.DB, search ProductSearch) (*ProductSearchResult, error) {
+	search.Keyword = strings.TrimSpace(search.Keyword)
+	if search.Limit <= 0 || search.Limit > 200 {
+		search.Limit = 50
+	}
+	if search.Sort == "" {
+		search.Sort = SortSKU
+		if search.Keyword != "" {
+			search.Sort = SortRelevance
+		}
+	}
+	if search.MinPrice != nil && search.MaxPrice != nil && *search.MinPrice > *search.MaxPrice {
+		return nil, fmt.Errorf("%w: 最低价不能高于最高价", ErrInvalidSearch)
+	}
+	if search.InStock && search.StoreID == 0 {
+		return nil, fmt.Errorf("%w: 按库存筛选时必须指定店铺", ErrInvalidSearch)
+	}
+
+	spec, err := productSort(search)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ProductSearchResult{Items: []ProductHit{}}
+	if err := filterProducts(db.Model(&models.Product{}), search, "").Count(&result.Total).Error; err != nil {
+		return nil, fmt.Errorf("统计商品数量失败: %w", err)
+	}
+
+	columns, args := "products.*", []interface{}{}
+	if search.Keyword != "" {
+		columns += ", " + relevanceExpr + " AS relevance"
+		args = relevanceArgs(search.Keyword)
+	}
+	query := filterProducts(db.Table("products"), search, "")
+	if search.StoreID != 0 {
+		columns += ", COALESCE(inventories.quantity - inventories.reserved, 0) AS available"
+		query = query.Joins("LEFT JOIN inventories ON inventories.product_id = products.id AND inventories.store_id = ?", search.StoreID)
+	}
+	query = query.Select(columns, args...)
+
+	if search.Cursor != "" {
+		cursor, err := decodeCursor(search.Cursor, search.Sort)
+		if err != nil {
+			return nil, err
+		}
+		query = applyCursor(query, spec, cursor, search)
+	}
+
+	direction := "ASC"
+	if spec.desc {
+		direction = "DESC"
+	}
+	var hits []ProductHit
+	if err := query.Order(spec.expr + " " + direction).Order("products.id " + direction).
+		Limit(search.Limit + 1).Scan(&hits).Error; err != nil {
+		return nil, fmt.Errorf("搜索商品失败: %w", err)
+	}
+
+	if len(hits) > search.Limit {
+		hits = hits[:search.Limit]
+		last := hits[len(hits)-1]
+		result.NextCursor = encodeCursor(searchCursor{Sort: search.Sort, Value: cursorValue(search.Sort, &last), ID: last.ID})
+	}
+	result.Items = hits
+
+	if search.Facets {
+		if result.Facets, err = productFacets(db, search); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// relevanceExpr 关键词相关度：SKU完全匹配 > SKU前缀 > 名称前缀 > 名称包含 > 模糊匹配
+const relevanceExpr = "CASE WHEN products.sku = ? THEN 5 WHEN products.sku LIKE ? THEN 4 " +
+	"WHEN products.name LIKE ? THEN 3 WHEN products.name LIKE ? THEN 2 ELSE 1 END"
+
+// relevanceArgs 返回相关度表达式的参数
+func relevanceArgs(keyword string) []interface{} {
+	escaped := escapeLike(keyword)
+	return []interface{}{keyword, escaped + "%", escaped + "%", "%" + escaped + "%"}
+}
+
+// productSort 返回排序方式对应的排序字段
+func productSort(search ProductSearch) (sortSpec, error) {
+	switch search.Sort {
+	case SortRelevance:
+		if search.Keyword == "" {
+			return sortSpec{}, fmt.Errorf("%w: 按相关度排序时必须输入关键词", ErrInvalidSearch)
+		}
+		return sortSpec{expr: "relevance", desc: true}, nil
+	case SortSKU:
+		return sortSpec{expr: "products.sku"}, nil
+	case SortName:
+		return sortSpec{expr: "products.name"}, nil
+	case SortPriceAsc:
+		return sortSpec{expr: "products.retail_price"}, nil
+	case SortPriceDesc:
+		return sortSpec{expr: "products.retail_price", desc: true}, nil
+	case SortNewest:
+		return sortSpec{expr: "products.id", desc: true}, nil
+	}
+	return sortSpec{}, fmt.Errorf("%w: 不支持的排序方式 %s", ErrInvalidSearch, search.Sort)
+}
+
+// filterProducts 应用搜索条件，skip 为统计分面时跳过的字段
+func filterProducts(query *gorm.DB, search ProductSearch, skip string) *gorm.DB {
+	if search.Keyword != "" {
+		keyword := escapeLike(search.Keyword)
+		query = query.Where("products.sku LIKE ? OR products.name LIKE ? OR products.color = ? OR products.size = ?",
+			keyword+"%", fuzzyPattern(search.Keyword), search.Keyword, search.Keyword)
+	}
+
+	in := map[string][]string{
+		"color":  search.Colors,
+		"size":   search.Sizes,
+		"season": search.Seasons,
+	}
+	for _, field := range facetFields {
+		if values := in[field]; len(values) > 0 && field != skip {
+			query = query.Where("products."+field+" IN ?", values)
+		}
+	}
+
+	if len(search.CategoryIDs) > 0 && skip != "category_id" {
+		query = ApplyCategoryFilter(query, "products.category_id", search.CategoryIDs...)
+	}
+	if search.StyleID != 0 {
+		query = query.Where("products.style_id = ?", search.StyleID)
+	}
+	if search.MinPrice != nil {
+		query = query.Where("products.retail_price >= ?", *search.MinPrice)
+	}
+	if search.MaxPrice != nil {
+		query = query.Where("products.retail_price <= ?", *search.MaxPrice)
+	}
+	if search.InStock {
+		query = query.Where("EXISTS (SELECT 1 FROM inventories stock WHERE stock.product_id = products.id "+
+			"AND stock.store_id = ? AND stock.quantity - stock.reserved > 0)", search.StoreID)
+	}
+	return query
+}
+
+// productFacets 统计类别、颜色、尺码、季节分面，类别分面附带类别名称路径
+func productFacets(db *gorm.DB, search ProductSearch) (map[string][]FacetCount, error) {
+	facets := make(map[string][]FacetCount, len(facetFields))
+	for _, field := range facetFields {
+		query := filterProducts(db.Model(&models.Product{}), search, field).
+			Select("products." + field + " AS value, COUNT(*) AS count").
+			Group("products." + field).
+			Order("count DESC, value")
+		if field == "category_id" {
+			query = query.Where("products.category_id IS NOT NULL")
+		} else {
+			query = query.Where("products." + field + " <> ''")
+		}
+		counts := []FacetCount{}
+		if err := query.Scan(&counts).Error; err != nil {
+			return nil, fmt.Errorf("统计商品分面失败: %w", err)
+		}
+		facets[field] = counts
+	}
+
+	categories := facets["category_id"]
+	ids := make([]uint, 0, len(categories))
+	for _, count := range categories {
+		if id, err := strconv.ParseUint(count.Value, 10, 32); err == nil {
+			ids = append(ids, uint(id))
+		}
+	}
+	paths, err := CategoryNamePaths(db, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range categories {
+		if id, err := strconv.ParseUint(categories[i].Value, 10, 32); err == nil {
+			categories[i].Label = paths[uint(id)]
+		}
+	}
+	return facets, nil
+}
+
+// applyCursor 只返回排在游标之后的商品
+func applyCursor(query *gorm.DB, spec sortSpec, cursor *searchCursor, search ProductSearch) *gorm.DB {
+	op := ">"
+	if spec.desc {
+		op = "<"
+	}
+	if spec.expr == "products.id" {
+		return query.Where("products.id "+op+" ?", cursor.ID)
+	}
+
+	expr, args := spec.expr, []interface{}{}
+	if spec.expr == "relevance" {
+		// WHERE 中不能引用列别名，重复相关度表达式
+		expr = "(" + relevanceExpr + ")"
+		args = relevanceArgs(search.Keyword)
+	}
+	where := fmt.Sprintf("(%s %s ? OR (%s = ? AND products.id %s ?))", expr, op, expr, op)
+	values := append(append([]interface{}{}, args...), cursor.Value)
+	values = append(append(values, args...), cursor.Value, cursor.ID)
+	return query.Where(where, values...)
+}
+
+// cursorValue 取商品在当前排序方式下的排序值
+func cursorValue(sort string, hit *ProductHit) interface{} {
+	switch sort {
+	case SortRelevance:
+		return hit.Relevance
+	case SortSKU:
+		return hit.SKU
+	case SortName:
+		return hit.Name
+	case SortPriceAsc, SortPriceDesc:
+		return hit.RetailPrice
+	}
+	return hit.ID
+}
+
+// encodeCursor 将游标编码为URL安全的字符串
+func encodeCursor(cursor searchCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor 解析游标，并校验游标与当前排序方式一致
+func decodeCursor(s, sort string) (*searchCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor searchCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Sort != sort {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// escapeLike 转义LIKE通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// fuzzyPattern 生成按字顺序模糊匹配的LIKE模式，如“男衬”生成“%男%衬%”
+func fuzzyPattern(keyword string) string {
+	var b strings.Builder
+	b.WriteString("%")
+	for _, r := range keyword {
+		if r == ' ' {
+			continue
+		}
+		b.WriteString(escapeLike(string(r)))
+		b.WriteString("%")
+	}
+	return b.String()
+}
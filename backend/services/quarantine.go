@@ -0,0 +1,90 @@
+package services
+
+import (
+	"fmt"
+	"hd_psi/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// postQuarantine 记录隔离库存变动，隔离库存不参与批次和平均成本，也不允许为负数
+func (l *InventoryLedger) postQuarantine(tx *gorm.DB, transaction *models.InventoryTransaction) (*models.Inventory, error) {
+	inventory, err := l.lockInventory(tx, transaction.StoreID, transaction.ProductID)
+	if err != nil {
+		return nil, err
+	}
+
+	if inventory.QuarantineQty+transaction.Quantity < 0 {
+		return nil, &InsufficientStockError{
+			StoreID:   transaction.StoreID,
+			ProductID: transaction.ProductID,
+			Available: inventory.QuarantineQty,
+			Requested: -transaction.Quantity,
+		}
+	}
+
+	// 出隔离区按入隔离区时的加权成本登记
+	if transaction.Quantity < 0 && transaction.UnitCost == 0 {
+		cost, err := QuarantineUnitCost(tx, transaction.StoreID, transaction.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		transaction.UnitCost = cost
+	}
+
+	inventory.QuarantineQty += transaction.Quantity
+	if err := tx.Save(inventory).Error; err != nil {
+		return nil, fmt.Errorf("更新库存失败: %w", err)
+	}
+
+	if err := tx.Create(transaction).Error; err != nil {
+		return nil, fmt.Errorf("创建库存交易记录失败: %w", err)
+	}
+
+	return inventory, nil
+}
+
+// ReleaseQuarantine 在事务tx中将隔离库存放行为可售库存
+// 隔离区记一笔出、可售区按隔离成本记一笔入，两笔交易类型均为放行
+func (l *InventoryLedger) ReleaseQuarantine(tx *gorm.DB, storeID, productID uint, quantity int, operatorID uint, note string) (*models.Inventory, error) {
+	if quantity <= 0 {
+		return nil, ErrInvalidQuantity
+	}
+
+	out := models.InventoryTransaction{
+		TransactionType: models.QuarantineRelease,
+		ProductID:       productID,
+		StoreID:         storeID,
+		Quantity:        -quantity,
+		Bucket:          models.BucketQuarantine,
+		OperatorID:      operatorID,
+		Note:            note,
+	}
+	if _, err := l.Post(tx, &out); err != nil {
+		return nil, err
+	}
+
+	in := models.InventoryTransaction{
+		TransactionType: models.QuarantineRelease,
+		ProductID:       productID,
+		StoreID:         storeID,
+		Quantity:        quantity,
+		UnitCost:        out.UnitCost,
+		OperatorID:      operatorID,
+		Note:            note,
+	}
+	return l.Post(tx, &in)
+}
+
+// QuarantineUnitCost 按隔离区入库交易计算店铺商品隔离库存的加权单位成本，不含不带成本的台账修正
+func QuarantineUnitCost(db *gorm.DB, storeID, productID uint) (float64, error) {
+	var cost float64
+	if err := db.Model(&models.InventoryTransaction{}).
+		Select("COALESCE(SUM(quantity * unit_cost) / NULLIF(SUM(quantity), 0), 0)").
+		Where("store_id = ? AND product_id = ? AND bucket = ? AND quantity > 0 AND transaction_type <> ?",
+			storeID, productID, models.BucketQuarantine, models.LedgerCorrection).
+		Scan(&cost).Error; err != nil {
+		return 0, fmt.Errorf("查询隔离库存成本失败: %w", err)
+	}
+	return roundCost(cost), nil
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"fmt"
+	"hd_psi/backend/models"
+	"math"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultVelocityDays 计算日均销量的默认统计天数
+const DefaultVelocityDays = 28
+
+// openPurchaseStatuses 仍会到货的采购单状态
+var openPurchaseStatuses = []models.PurchaseOrderStatus{
+	models.PurchaseDraft,
+	models.PurchasePending,
+	models.PurchaseApproved,
+	models.PurchaseOrdered,
+	models.PurchaseInReceiving,
+}
+
+// ReplenishmentFilter 补货建议计算条件，零值表示不限
+type ReplenishmentFilter struct {
+	StoreID      uint
+	SupplierID   uint
+	VelocityDays int // 日均销量统计天数，为0时使用默认值
+}
+
+// ReplenishmentLine 单个店铺商品的补货建议
+type ReplenishmentLine struct {
+	StoreID       uint    `json:"store_id"`
+	ProductID     uint    `json:"product_id"`
+	StyleID       *uint   `json:"style_id"`
+	SKU           string  `json:"sku"`
+	ProductName   string  `json:"product_name"`
+	CategoryID    *uint   `json:"category_id"`
+	Category      string  `json:"category"`
+	Size          string  `json:"size"`
+	OnHand        int     `json:"on_hand"`        // 在库数量
+	Reserved      int     `json:"reserved"`       // 预留数量
+	OpenOrderQty  int     `json:"open_order_qty"` // 未到货采购数量
+	DailyVelocity float64 `json:"daily_velocity"` // 日均销量
+	LeadTimeDays  int     `json:"lead_time_days"` // 供货周期
+	ReorderPoint  int     `json:"reorder_point"`  // 再订货点 = 低库存阈值 + 供货周期内销量
+	TargetLevel   int     `json:"target_level"`   // 补货目标 = 高库存阈值 + 供货周期内销量
+	ProposedQty   int     `json:"proposed_qty"`   // 建议采购数量
+	UnitPrice     float64 `json:"unit_price"`     // 参考采购单价（最近一次采购价）
+}
+
+// ReplenishmentProposal 按供应商和店铺汇总的补货建议，对应一张采购单
+type ReplenishmentProposal struct {
+	SupplierID   uint                `json:"supplier_id"` // 为0表示商品没有采购记录，无法确定供应商
+	SupplierName string              `json:"supplier_name"`
+	StoreID      uint                `json:"store_id"`
+	TotalAmount  float64             `json:"total_amount"`
+	Lines        []ReplenishmentLine `json:"lines"`
+}
+
+// lastPurchase 商品最近一次采购的供应商和单价
+type lastPurchase struct {
+	ProductID    uint
+	SupplierID   uint
+	SupplierName string
+	LeadTimeDays int
+	UnitPrice    float64
+}
+
+// ProposeReplenishment 结合库存阈值、近期销量、未到货采购和供货周期计算补货建议
+// 只计算有库存记录、近期有销量或设置了商品阈值的店铺商品，没有适用阈值设置时阈值按0计算
+// 可用库存（在库-预留+未到货）低于再订货点时，建议补货到目标库存
+func ProposeReplenishment(db *gorm.DB, filter ReplenishmentFilter) ([]ReplenishmentProposal, error) {
+	days := filter.VelocityDays
+	if days <= 0 {
+		days = DefaultVelocityDays
+	}
+
+	thresholds, err := LoadThresholds(db)
+	if err != nil {
+		return nil, fmt.Errorf("查询库存阈值失败: %w", err)
+	}
+
+	// 库存
+	var stocks []ReplenishmentLine
+	query := db.Table("inventories").
+		Select("inventories.store_id, inventories.product_id, products.style_id, products.sku, products.name AS product_name, products.category_id, products.category, products.size, " +
+			"inventories.quantity AS on_hand, inventories.reserved").
+		Joins("JOIN products ON products.id = inventories.product_id")
+	if filter.StoreID != 0 {
+		query = query.Where("inventories.store_id = ?", filter.StoreID)
+	}
+	if err := query.Scan(&stocks).Error; err != nil {
+		return nil, fmt.Errorf("查询库存失败: %w", err)
+	}
+
+	type key struct{ storeID, productID uint }
+
+	// 近期销量
+	var sales []struct {
+		StoreID   uint
+		ProductID uint
+		Sold      int
+	}
+	salesQuery := db.Model(&models.InventoryTransaction{}).
+		Select("store_id, product_id, -SUM(quantity) AS sold").
+		Where("transaction_type = ? AND created_at >= ?", models.SaleOut, time.Now().AddDate(0, 0, -days)).
+		Group("store_id, product_id")
+	if filter.StoreID != 0 {
+		salesQuery = salesQuery.Where("store_id = ?", filter.StoreID)
+	}
+	if err := salesQuery.Scan(&sales).Error; err != nil {
+		return nil, fmt.Errorf("统计销量失败: %w", err)
+	}
+	sold := make(map[key]int)
+	for _, s := range sales {
+		sold[key{s.StoreID, s.ProductID}] = s.Sold
+	}
+
+	// 未到货采购
+	var openOrders []struct {
+		StoreID   uint
+		ProductID uint
+		OpenQty   int
+	}
+	openQuery := db.Table("purchase_order_items").
+		Select("purchase_orders.store_id, purchase_order_items.product_id, SUM(GREATEST(purchase_order_items.quantity - purchase_order_items.received_qty, 0)) AS open_qty").
+		Joins("JOIN purchase_orders ON purchase_orders.id = purchase_order_items.purchase_order_id").
+		Where("purchase_orders.status IN ?", openPurchaseStatuses).
+		Group("purchase_orders.store_id, purchase_order_items.product_id")
+	if filter.StoreID != 0 {
+		openQuery = openQuery.Where("purchase_orders.store_id = ?", filter.StoreID)
+	}
+	if err := openQuery.Scan(&openOrders).Error; err != nil {
+		return nil, fmt.Errorf("统计未到货采购失败: %w", err)
+	}
+	open := make(map[key]int)
+	for _, o := range openOrders {
+		open[key{o.StoreID, o.ProductID}] = o.OpenQty
+	}
+
+	// 没有库存记录但近期有销量或设置了商品阈值的店铺商品按在库为0计算
+	stocked := make(map[key]bool, len(stocks))
+	for _, line := range stocks {
+		stocked[key{line.StoreID, line.ProductID}] = true
+	}
+	missing := make(map[key]bool)
+	for k := range sold {
+		if !stocked[k] {
+			missing[k] = true
+		}
+	}
+	var storeIDs []uint
+	for _, t := range thresholds.ProductThresholds() {
+		if t.StoreID != 0 {
+			if (filter.StoreID == 0 || t.StoreID == filter.StoreID) && !stocked[key{t.StoreID, t.ProductID}] {
+				missing[key{t.StoreID, t.ProductID}] = true
+			}
+			continue
+		}
+		if storeIDs == nil {
+			storeIDs = []uint{}
+			stores := db.Model(&models.Store{})
+			if filter.StoreID != 0 {
+				stores = stores.Where("id = ?", filter.StoreID)
+			}
+			if err := stores.Pluck("id", &storeIDs).Error; err != nil {
+				return nil, fmt.Errorf("查询店铺失败: %w", err)
+			}
+		}
+		for _, storeID := range storeIDs {
+			if !stocked[key{storeID, t.ProductID}] {
+				missing[key{storeID, t.ProductID}] = true
+			}
+		}
+	}
+	if len(missing) > 0 {
+		productIDs := make([]uint, 0, len(missing))
+		for k := range missing {
+			productIDs = append(productIDs, k.productID)
+		}
+		var products []models.Product
+		if err := db.Select("id, style_id, sku, name, category_id, category, size").
+			Where("id IN ?", productIDs).Find(&products).Error; err != nil {
+			return nil, fmt.Errorf("查询商品失败: %w", err)
+		}
+		byID := make(map[uint]models.Product, len(products))
+		for _, p := range products {
+			byID[p.ID] = p
+		}
+		for k := range missing {
+			p, ok := byID[k.productID]
+			if !ok {
+				continue
+			}
+			stocks = append(stocks, ReplenishmentLine{
+				StoreID:     k.storeID,
+				ProductID:   p.ID,
+				StyleID:     p.StyleID,
+				SKU:         p.SKU,
+				ProductName: p.Name,
+				CategoryID:  p.CategoryID,
+				Category:    p.Category,
+				Size:        p.Size,
+			})
+		}
+	}
+
+	// 商品最近一次采购的供应商
+	var purchases []lastPurchase
+	if err := db.Table("purchase_order_items").
+		Select("purchase_order_items.product_id, purchase_orders.supplier_id, suppliers.name AS supplier_name, suppliers.lead_time_days, purchase_order_items.unit_price").
+		Joins("JOIN purchase_orders ON purchase_orders.id = purchase_order_items.purchase_order_id").
+		Joins("JOIN suppliers ON suppliers.id = purchase_orders.supplier_id").
+		Where("purchase_orders.status <> ? AND purchase_orders.status <> ?", models.PurchaseRejected, models.PurchaseCancelled).
+		Where("suppliers.status = ?", true).
+		Order("purchase_order_items.id DESC").
+		Scan(&purchases).Error; err != nil {
+		return nil, fmt.Errorf("查询采购记录失败: %w", err)
+	}
+	suppliers := make(map[uint]lastPurchase)
+	for _, p := range purchases {
+		if _, ok := suppliers[p.ProductID]; !ok {
+			suppliers[p.ProductID] = p
+		}
+	}
+
+	type groupKey struct{ supplierID, storeID uint }
+	groups := make(map[groupKey]*ReplenishmentProposal)
+	for _, line := range stocks {
+		k := key{line.StoreID, line.ProductID}
+		supplier := suppliers[line.ProductID]
+		if filter.SupplierID != 0 && supplier.SupplierID != filter.SupplierID {
+			continue
+		}
+
+		// 没有适用的阈值设置时不套用预警默认阈值，只按供货周期内销量补货
+		threshold, _ := thresholds.Lookup(ThresholdTarget{
+			StoreID:    line.StoreID,
+			ProductID:  line.ProductID,
+			CategoryID: categoryIDOf(line.CategoryID),
+			Size:       line.Size,
+		})
+		line.OpenOrderQty = open[k]
+		line.DailyVelocity = math.Round(float64(sold[k])/float64(days)*100) / 100
+		line.LeadTimeDays = supplier.LeadTimeDays
+		line.UnitPrice = supplier.UnitPrice
+
+		leadDemand := int(math.Ceil(float64(sold[k]) / float64(days) * float64(line.LeadTimeDays)))
+		line.ReorderPoint = threshold.LowLevel + leadDemand
+		line.TargetLevel = threshold.HighLevel + leadDemand
+
+		position := line.OnHand - line.Reserved + line.OpenOrderQty
+		if position > line.ReorderPoint {
+			continue
+		}
+		line.ProposedQty = line.TargetLevel - position
+		if line.ProposedQty <= 0 {
+			continue
+		}
+
+		gk := groupKey{supplier.SupplierID, line.StoreID}
+		proposal, ok := groups[gk]
+		if !ok {
+			proposal = &ReplenishmentProposal{
+				SupplierID:   supplier.SupplierID,
+				SupplierName: supplier.SupplierName,
+				StoreID:      line.StoreID,
+			}
+			groups[gk] = proposal
+		}
+		proposal.Lines = append(proposal.Lines, line)
+		proposal.TotalAmount += line.UnitPrice * float64(line.ProposedQty)
+	}
+
+	proposals := make([]ReplenishmentProposal, 0, len(groups))
+	for _, p := range groups {
+		sort.Slice(p.Lines, func(i, j int) bool { return p.Lines[i].ProductID < p.Lines[j].ProductID })
+		proposals = append(proposals, *p)
+	}
+	sort.Slice(proposals, func(i, j int) bool {
+		if proposals[i].SupplierID != proposals[j].SupplierID {
+			return proposals[i].SupplierID < proposals[j].SupplierID
+		}
+		return proposals[i].StoreID < proposals[j].StoreID
+	})
+
+	return proposals, nil
+}
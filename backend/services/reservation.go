@@ -0,0 +1,61 @@
+package services
+
+import (
+	"hd_psi/backend/models"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ExpireReservations 释放已过期的库存预留，并取消对应的待支付订单
+// 返回：
+//   - int: 被取消的订单数量
+//   - error: 查询过期预留失败时返回错误
+func ExpireReservations(db *gorm.DB, now time.Time) (int, error) {
+	var orderIDs []uint
+	if err := db.Model(&models.StockReservation{}).
+		Where("status = ? AND expires_at <= ?", models.ReservationActive, now).
+		Distinct().Pluck("order_id", &orderIDs).Error; err != nil {
+		return 0, err
+	}
+
+	ledger := NewInventoryLedger(db)
+	expired := 0
+	for _, orderID := range orderIDs {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// 锁定订单，避免与支付并发
+			var order models.SalesOrder
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, orderID).Error; err != nil {
+				return err
+			}
+			if order.Status != models.Created {
+				return nil
+			}
+
+			var reservations []models.StockReservation
+			if err := tx.Where("order_id = ? AND status = ?", orderID, models.ReservationActive).
+				Find(&reservations).Error; err != nil {
+				return err
+			}
+			for i := range reservations {
+				if _, err := ledger.Release(tx, &reservations[i], models.ReservationExpired); err != nil {
+					return err
+				}
+			}
+
+			order.Status = models.OrderCancelled
+			if err := tx.Save(&order).Error; err != nil {
+				return err
+			}
+			expired++
+			return nil
+		})
+		if err != nil {
+			log.Printf("释放订单 %d 的过期库存预留失败: %v", orderID, err)
+		}
+	}
+
+	return expired, nil
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"fmt"
+	"hd_psi/backend/models"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StoreSalesSummary 店铺单日销售汇总
+type StoreSalesSummary struct {
+	StoreID      uint    `json:"store_id"`
+	OrderCount   int     `json:"order_count"`   // 有效订单数，不含已取消和已退货订单
+	SalesAmount  float64 `json:"sales_amount"`  // 实付金额合计
+	SoldQuantity int     `json:"sold_quantity"` // 销售和换货出库数量，已扣除退货
+	COGS         float64 `json:"cogs"`          // 销售成本，已扣除退货成本
+	GrossProfit  float64 `json:"gross_profit"`  // 毛利
+}
+
+// DailySalesSummary 按店铺汇总指定日期的销售订单和销售成本
+// 订单按创建时间统计，销售成本按出库时登记的成本统计，退货入库冲减销售成本
+func DailySalesSummary(db *gorm.DB, day time.Time) ([]StoreSalesSummary, error) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	end := start.AddDate(0, 0, 1)
+
+	var orders []StoreSalesSummary
+	if err := db.Model(&models.SalesOrder{}).
+		Select("store_id, COUNT(*) AS order_count, SUM(actual_amount) AS sales_amount").
+		Where("status NOT IN ?", []models.OrderStatus{models.Created, models.OrderCancelled, models.Returned}).
+		Where("created_at >= ? AND created_at < ?", start, end).
+		Group("store_id").
+		Scan(&orders).Error; err != nil {
+		return nil, fmt.Errorf("汇总销售订单失败: %w", err)
+	}
+
+	var costs []StoreSalesSummary
+	if err := db.Model(&models.InventoryTransaction{}).
+		Select("store_id, -SUM(quantity) AS sold_quantity, -SUM(quantity * unit_cost) AS cogs").
+		Where("transaction_type IN ?", []models.TransactionType{models.SaleOut, models.ExchangeOut, models.ReturnIn}).
+		Where("created_at >= ? AND created_at < ?", start, end).
+		Group("store_id").
+		Scan(&costs).Error; err != nil {
+		return nil, fmt.Errorf("汇总销售成本失败: %w", err)
+	}
+
+	index := make(map[uint]int, len(orders))
+	summaries := orders
+	for i, o := range summaries {
+		index[o.StoreID] = i
+	}
+	for _, c := range costs {
+		if i, ok := index[c.StoreID]; ok {
+			summaries[i].SoldQuantity, summaries[i].COGS = c.SoldQuantity, c.COGS
+			continue
+		}
+		index[c.StoreID] = len(summaries)
+		summaries = append(summaries, c)
+	}
+	for i := range summaries {
+		summaries[i].GrossProfit = summaries[i].SalesAmount - summaries[i].COGS
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].StoreID < summaries[j].StoreID })
+	return summaries, nil
+}
@@ -0,0 +1,563 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"hd_psi/backend/models"
+	"hd_psi/backend/skucode"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrEmptyMatrix 生成款式商品时颜色或尺码为空
+	ErrEmptyMatrix = errors.New("颜色和尺码不能为空")
+	// ErrSKUExists 生成的SKU已被其他商品占用
+	ErrSKUExists = errors.New("SKU已存在")
+)
+
+// StyleColor 款式颜色，颜色须已在SKU编码字典中定义
+type StyleColor struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// GenerateVariants 按颜色×尺码矩阵为款式生成商品，款式下已有的颜色尺码组合跳过
+// 新商品继承款式的名称、季节、品类、图片和价格，SKU按编码规则生成，年份取款式创建年份
+// 返回：
+//   - []models.Product: 新建的商品
+//   - error: 颜色尺码为空、SKU被其他商品占用或保存失败时返回错误
+func GenerateVariants(tx *gorm.DB, style *models.Style, colors []StyleColor, sizes []string) ([]models.Product, error) {
+	if len(colors) == 0 || len(sizes) == 0 {
+		return nil, ErrEmptyMatrix
+	}
+
+	var existing []models.Product
+	if err := tx.Where("style_id = ?", style.ID).Find(&existing).Error; err != nil {
+		return nil, fmt.Errorf("查询款式商品失败: %w", err)
+	}
+	type combo struct{ color, size string }
+	seen := make(map[combo]bool, len(existing))
+	for _, p := range existing {
+		seen[combo{p.Color, p.Size}] = true
+	}
+
+	codec, err := skucode.Load(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	year := style.CreatedAt.Year()
+	if style.CreatedAt.IsZero() {
+		year = time.Now().Year()
+	}
+
+	var variants []models.Product
+	for _, color := range colors {
+		color.Name = strings.TrimSpace(color.Name)
+		for _, size := range sizes {
+			size = strings.TrimSpace(size)
+			if color.Name == "" || size == "" || seen[combo{color.Name, size}] {
+				continue
+			}
+			seen[combo{color.Name, size}] = true
+
+			sku, err := codec.Encode(skucode.Attributes{
+				Category: style.Category,
+				Year:     year,
+				Season:   style.Season,
+				Color:    color.Name,
+				Size:     size,
+			})
+			if err != nil {
+				return nil, err
+			}
+			var count int64
+			if err := tx.Model(&models.Product{}).Where("sku = ?", sku).Count(&count).Error; err != nil {
+				return nil, fmt.Errorf("检查SKU失败: %w", err)
+			}
+			if count > 0 {
+				return nil, fmt.Errorf("%w: %s", ErrSKUExists, sku)
+			}
+
+			variants = append(variants, models.Product{
+				StyleID:     &style.ID,
+				SKU:         sku,
+				Name:        style.Name,
+				Color:       color.Name,
+				Size:        size,
+				Season:      style.Season,
+				Category:    style.Category,
+				Image:       style.Image,
+				CostPrice:   style.CostPrice,
+				RetailPrice: style.RetailPrice,
+			})
+		}
+	}
+
+	if len(variants) == 0 {
+		return variants, nil
+	}
+	if err := tx.Create(&variants).Error; err != nil {
+		return nil, fmt.Errorf("创建款式商品失败: %w", err)
+	}
+	return variants, nil
+}
+
+// SyncVariants 将款式修改同步到款式下的商品
+// 名称、季节、品类和图片总是同步；价格只同步仍沿用款式原价格的商品，单独调价的商品保持不变
+func SyncVariants(tx *gorm.DB, old, style *models.Style) error {
+	if err := tx.Model(&models.Product{}).Where("style_id = ?", style.ID).
+		Updates(map[string]interface{}{
+			"name":     style.Name,
+			"season":   style.Season,
+			"category": style.Category,
+			"image":    style.Image,
+		}).Error; err != nil {
+		return fmt.Errorf("同步款式商品失败: %w", err)
+	}
+
+	if old.CostPrice != style.CostPrice {
+		if err := tx.Model(&models.Product{}).
+			Where("style_id = ? AND cost_price = ?", style.ID, old.CostPrice).
+			Update("cost_price", style.CostPrice).Error; err != nil {
+			return fmt.Errorf("同步款式成本价失败: %w", err)
+		}
+	}
+	if old.RetailPrice != style.RetailPrice {
+		if err := tx.Model(&models.Product{}).
+			Where("style_id = ? AND retail_price = ?", style.ID, old.RetailPrice).
+			Update("retail_price", style.RetailPrice).Error; err != nil {
+			return fmt.Errorf("同步款式零售价失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// MigrateProductsToStyles 将尚未归属款式的商品按 名称+季节+品类+类别+SKU款式前缀 归并为款式
+// 同组商品SKU有公共前缀时以前缀为款号，否则单个商品以其SKU为款号，多个商品以 ST+首个商品ID 为款号
+// 已存在款号、名称、季节和品类都相同的款式时直接归入，可重复执行
+// 返回：
+//   - int: 归入款式的商品数量
+//   - error: 迁移失败时返回错误
+func MigrateProductsToStyles(db *gorm.DB) (int, error) {
+	var products []models.Product
+	if err := db.Where("style_id IS NULL").Order("id").Find(&products).Error; err != nil {
+		return 0, fmt.Errorf("查询商品失败: %w", err)
+	}
+
+	type groupKey struct {
+		name, season, category string
+		categoryID             uint
+		prefix                 string
+	}
+	var keys []groupKey
+	groups := make(map[groupKey][]models.Product)
+	for _, p := range products {
+		k := groupKey{name: p.Name, season: p.Season, category: p.Category, prefix: styleSKUPrefix(p.SKU)}
+		if p.CategoryID != nil {
+			k.categoryID = *p.CategoryID
+		}
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], p)
+	}
+
+	migrated := 0
+	for _, k := range keys {
+		members := groups[k]
+		base := migratedStyleCode(members)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var style models.Style
+			result := tx.Where("code = ? AND name = ? AND season = ? AND category = ?", base, k.name, k.season, k.category).
+				Limit(1).Find(&style)
+			if result.Error != nil {
+				return result.Error
+			}
+
+			if result.RowsAffected == 0 {
+				code, err := uniqueStyleCode(tx, base)
+				if err != nil {
+					return err
+				}
+				first := members[0]
+				style = models.Style{
+					Code:        code,
+					Name:        first.Name,
+					Season:      first.Season,
+					Category:    first.Category,
+					Image:       first.Image,
+					CostPrice:   first.CostPrice,
+					RetailPrice: first.RetailPrice,
+				}
+				if err := tx.Create(&style).Error; err != nil {
+					return err
+				}
+			}
+
+			ids := make([]uint, 0, len(members))
+			for _, p := range members {
+				ids = append(ids, p.ID)
+			}
+			return tx.Model(&models.Product{}).Where("id IN ? AND style_id IS NULL", ids).
+				Update("style_id", style.ID).Error
+		})
+		if err != nil {
+			return migrated, fmt.Errorf("迁移商品 %s 到款式失败: %w", k.name, err)
+		}
+		migrated += len(members)
+	}
+
+	return migrated, nil
+}
+
+// styleCodeSize 款号的最大长度，与 models.Style.Code 一致
+const styleCodeSize = 50
+
+// styleSKUPrefix 取SKU中同一款式共用的部分
+// 符合编码规则的SKU去掉颜色和尺码编码，其他SKU取第一个“-”之前的部分
+func styleSKUPrefix(sku string) string {
+	sku = strings.ToUpper(strings.TrimSpace(sku))
+	if len(sku) == skucode.Length && !strings.Contains(sku, "-") {
+		return sku[:skucode.Length-models.SKUColor.CodeLength()-models.SKUSize.CodeLength()]
+	}
+	if i := strings.Index(sku, "-"); i > 0 {
+		return sku[:i]
+	}
+	return sku
+}
+
+// migratedStyleCode 推断迁移商品所属款式的款号，超长时截断
+func migratedStyleCode(products []models.Product) string {
+	if len(products) == 1 {
+		return truncateCode(products[0].SKU, styleCodeSize)
+	}
+
+	prefix := products[0].SKU
+	for _, p := range products[1:] {
+		for !strings.HasPrefix(p.SKU, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	prefix = strings.TrimRight(prefix, "-_ ")
+	if len(prefix) >= 3 && utf8.ValidString(prefix) {
+		return truncateCode(prefix, styleCodeSize)
+	}
+	return fmt.Sprintf("ST%06d", products[0].ID)
+}
+
+// uniqueStyleCode 款号已被占用时追加序号，追加后仍不超过款号长度
+func uniqueStyleCode(tx *gorm.DB, code string) (string, error) {
+	candidate := code
+	for i := 2; ; i++ {
+		var count int64
+		if err := tx.Model(&models.Style{}).Where("code = ?", candidate).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+		suffix := fmt.Sprintf("-%d", i)
+		candidate = truncateCode(code, styleCodeSize-len(suffix)) + suffix
+	}
+}
+
+// truncateCode 将编码截断到不超过n字节，不截断多字节字符
+func truncateCode(code string, n int) string {
+	if len(code) <= n {
+		return code
+	}
+	for n > 0 && !utf8.RuneStart(code[n]) {
+		n--
+	}
+	return code[:n]
+}
+
+// StyleStockCell 款式库存矩阵中的单个颜色尺码
+type StyleStockCell struct {
+	ProductID uint   `json:"product_id"`
+	SKU       string `json:"sku"`
+	Color     string `json:"color"`
+	Size      string `json:"size"`
+	Quantity  int    `json:"quantity"`
+	Reserved  int    `json:"reserved"`
+	Available int    `json:"available"`
+}
+
+// StyleStockMatrix 款式颜色×尺码库存矩阵
+type StyleStockMatrix struct {
+	StyleID   uint             `json:"style_id"`
+	Colors    []string         `json:"colors"`
+	Sizes     []string         `json:"sizes"`
+	Cells     []StyleStockCell `json:"cells"`
+	Quantity  int              `json:"quantity"`
+	Reserved  int              `json:"reserved"`
+	Available int              `json:"available"`
+}
+
+// StyleStock 汇总款式下各颜色尺码的库存，storeID为0时汇总所有店铺
+// 颜色和尺码按商品创建顺序排列，没有库存记录的组合数量为0
+func StyleStock(db *gorm.DB, styleID, storeID uint) (*StyleStockMatrix, error) {
+	var variants []models.Product
+	if err := db.Where("style_id = ?", styleID).Order("id").Find(&variants).Error; err != nil {
+		return nil, fmt.Errorf("查询款式商品失败: %w", err)
+	}
+
+	var stocks []struct {
+		ProductID uint
+		Quantity  int
+		Reserved  int
+	}
+	query := db.Table("inventories").
+		Select("inventories.product_id, SUM(inventories.quantity) AS quantity, SUM(inventories.reserved) AS reserved").
+		Joins("JOIN products ON products.id = inventories.product_id").
+		Where("products.style_id = ?", styleID).
+		Group("inventories.product_id")
+	if storeID != 0 {
+		query = query.Where("inventories.store_id = ?", storeID)
+	}
+	if err := query.Scan(&stocks).Error; err != nil {
+		return nil, fmt.Errorf("查询款式库存失败: %w", err)
+	}
+	type stock struct{ quantity, reserved int }
+	byProduct := make(map[uint]stock, len(stocks))
+	for _, s := range stocks {
+		byProduct[s.ProductID] = stock{s.Quantity, s.Reserved}
+	}
+
+	matrix := &StyleStockMatrix{StyleID: styleID, Colors: []string{}, Sizes: []string{}, Cells: []StyleStockCell{}}
+	colors, sizes := make(map[string]bool), make(map[string]bool)
+	for _, v := range variants {
+		if !colors[v.Color] {
+			colors[v.Color] = true
+			matrix.Colors = append(matrix.Colors, v.Color)
+		}
+		if !sizes[v.Size] {
+			sizes[v.Size] = true
+			matrix.Sizes = append(matrix.Sizes, v.Size)
+		}
+
+		s := byProduct[v.ID]
+		matrix.Cells = append(matrix.Cells, StyleStockCell{
+			ProductID: v.ID,
+			SKU:       v.SKU,
+			Color:     v.Color,
+			Size:      v.Size,
+			Quantity:  s.quantity,
+			Reserved:  s.reserved,
+			Available: s.quantity - s.reserved,
+		})
+		matrix.Quantity += s.quantity
+		matrix.Reserved += s.reserved
+	}
+	matrix.Available = matrix.Quantity - matrix.Reserved
+
+	return matrix, nil
+}
+
+// StyleFilter 款式汇总查询条件，零值表示不限
+type StyleFilter struct {
+	StoreID   uint
+	Category  string
+	Season    string
+	StartDate string // 销量统计起始日期，仅用于销量汇总
+	EndDate   string // 销量统计截止日期，仅用于销量汇总
+}
+
+// StyleStockSummary 款式库存汇总
+type StyleStockSummary struct {
+	StyleID   uint   `json:"style_id"`
+	Code      string `json:"code"`
+	Name      string `json:"name"`
+	Category  string `json:"category"`
+	Season    string `json:"season"`
+	Variants  int    `json:"variants"` // 有库存记录的商品数
+	Quantity  int    `json:"quantity"`
+	Reserved  int    `json:"reserved"`
+	Available int    `json:"available"`
+}
+
+// StyleStockSummaries 按款式汇总库存
+func StyleStockSummaries(db *gorm.DB, filter StyleFilter) ([]StyleStockSummary, error) {
+	query := applyStyleFilter(db.Table("inventories").
+		Select("styles.id AS style_id, styles.code, styles.name, styles.category, styles.season, "+
+			"COUNT(DISTINCT inventories.product_id) AS variants, "+
+			"SUM(inventories.quantity) AS quantity, SUM(inventories.reserved) AS reserved").
+		Joins("JOIN products ON products.id = inventories.product_id").
+		Joins("JOIN styles ON styles.id = products.style_id"), filter)
+	if filter.StoreID != 0 {
+		query = query.Where("inventories.store_id = ?", filter.StoreID)
+	}
+
+	var items []StyleStockSummary
+	if err := query.Group("styles.id, styles.code, styles.name, styles.category, styles.season").
+		Order("styles.code").Scan(&items).Error; err != nil {
+		return nil, fmt.Errorf("汇总款式库存失败: %w", err)
+	}
+	for i := range items {
+		items[i].Available = items[i].Quantity - items[i].Reserved
+	}
+	return items, nil
+}
+
+// StyleSalesSummary 款式销量汇总
+type StyleSalesSummary struct {
+	StyleID  uint           `json:"style_id"`
+	Code     string         `json:"code"`
+	Name     string         `json:"name"`
+	Category string         `json:"category"`
+	Season   string         `json:"season"`
+	Quantity int            `json:"quantity"`
+	Amount   float64        `json:"amount"`
+	BySize   map[string]int `json:"by_size"` // 各尺码销量，用于确定尺码配比
+}
+
+// StyleSales 按款式汇总已支付、已发货和已完成订单的销量和销售额
+func StyleSales(db *gorm.DB, filter StyleFilter) ([]StyleSalesSummary, error) {
+	var rows []struct {
+		StyleID  uint
+		Code     string
+		Name     string
+		Category string
+		Season   string
+		Size     string
+		Quantity int
+		Amount   float64
+	}
+	query := applyStyleFilter(db.Table("sales_order_items").
+		Select("styles.id AS style_id, styles.code, styles.name, styles.category, styles.season, products.size, "+
+			"SUM(sales_order_items.quantity) AS quantity, SUM(sales_order_items.actual_price * sales_order_items.quantity) AS amount").
+		Joins("JOIN sales_orders ON sales_orders.id = sales_order_items.order_id").
+		Joins("JOIN products ON products.id = sales_order_items.product_id").
+		Joins("JOIN styles ON styles.id = products.style_id").
+		Where("sales_orders.status IN ?", []models.OrderStatus{models.Paid, models.Shipped, models.OrderCompleted}), filter)
+	if filter.StoreID != 0 {
+		query = query.Where("sales_orders.store_id = ?", filter.StoreID)
+	}
+	if filter.StartDate != "" {
+		query = query.Where("sales_orders.created_at >= ?", filter.StartDate)
+	}
+	if filter.EndDate != "" {
+		query = query.Where("sales_orders.created_at <= ?", filter.EndDate+" 23:59:59")
+	}
+	if err := query.Group("styles.id, styles.code, styles.name, styles.category, styles.season, products.size").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("汇总款式销量失败: %w", err)
+	}
+
+	byStyle := make(map[uint]*StyleSalesSummary)
+	for _, row := range rows {
+		s, ok := byStyle[row.StyleID]
+		if !ok {
+			s = &StyleSalesSummary{
+				StyleID:  row.StyleID,
+				Code:     row.Code,
+				Name:     row.Name,
+				Category: row.Category,
+				Season:   row.Season,
+				BySize:   make(map[string]int),
+			}
+			byStyle[row.StyleID] = s
+		}
+		s.Quantity += row.Quantity
+		s.Amount += row.Amount
+		s.BySize[row.Size] += row.Quantity
+	}
+
+	items := make([]StyleSalesSummary, 0, len(byStyle))
+	for _, s := range byStyle {
+		s.Amount = roundAmount(s.Amount)
+		items = append(items, *s)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Quantity != items[j].Quantity {
+			return items[i].Quantity > items[j].Quantity
+		}
+		return items[i].Code < items[j].Code
+	})
+	return items, nil
+}
+
+// StyleReplenishment 按款式和店铺汇总的补货建议
+type StyleReplenishment struct {
+	StyleID     uint                `json:"style_id"` // 为0表示商品尚未归属款式
+	Code        string              `json:"code"`
+	Name        string              `json:"name"`
+	StoreID     uint                `json:"store_id"`
+	ProposedQty int                 `json:"proposed_qty"`
+	TotalAmount float64             `json:"total_amount"`
+	Lines       []ReplenishmentLine `json:"lines"`
+}
+
+// ProposeStyleReplenishment 将补货建议按款式和店铺汇总，便于按尺码配比整款下单
+func ProposeStyleReplenishment(db *gorm.DB, filter ReplenishmentFilter) ([]StyleReplenishment, error) {
+	proposals, err := ProposeReplenishment(db, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	type key struct{ styleID, storeID uint }
+	groups := make(map[key]*StyleReplenishment)
+	var styleIDs []uint
+	for _, proposal := range proposals {
+		for _, line := range proposal.Lines {
+			var styleID uint
+			if line.StyleID != nil {
+				styleID = *line.StyleID
+			}
+			k := key{styleID, line.StoreID}
+			group, ok := groups[k]
+			if !ok {
+				group = &StyleReplenishment{StyleID: styleID, StoreID: line.StoreID}
+				groups[k] = group
+				styleIDs = append(styleIDs, styleID)
+			}
+			group.ProposedQty += line.ProposedQty
+			group.TotalAmount += float64(line.ProposedQty) * line.UnitPrice
+			group.Lines = append(group.Lines, line)
+		}
+	}
+
+	var styles []models.Style
+	if len(styleIDs) > 0 {
+		if err := db.Where("id IN ?", styleIDs).Find(&styles).Error; err != nil {
+			return nil, fmt.Errorf("查询款式失败: %w", err)
+		}
+	}
+	styleByID := make(map[uint]models.Style, len(styles))
+	for _, s := range styles {
+		styleByID[s.ID] = s
+	}
+
+	result := make([]StyleReplenishment, 0, len(groups))
+	for _, group := range groups {
+		style := styleByID[group.StyleID]
+		group.Code = style.Code
+		group.Name = style.Name
+		group.TotalAmount = roundAmount(group.TotalAmount)
+		sort.Slice(group.Lines, func(i, j int) bool { return group.Lines[i].SKU < group.Lines[j].SKU })
+		result = append(result, *group)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].StoreID != result[j].StoreID {
+			return result[i].StoreID < result[j].StoreID
+		}
+		return result[i].Code < result[j].Code
+	})
+	return result, nil
+}
+
+// applyStyleFilter 为款式汇总查询添加品类和季节条件
+func applyStyleFilter(query *gorm.DB, filter StyleFilter) *gorm.DB {
+	if filter.Category != "" {
+		query = query.Where("styles.category = ?", filter.Category)
+	}
+	if filter.Season != "" {
+		query = query.Where("styles.season = ?", filter.Season)
+	}
+	return query
+}
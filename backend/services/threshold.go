@@ -0,0 +1,236 @@
+package services
+
+import (
+	"fmt"
+	"hd_psi/backend/models"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+)
+
+// 未设置任何阈值时使用的默认库存阈值
+const (
+	DefaultLowLevel  = 10
+	DefaultHighLevel = 100
+)
+
+// 阈值条件的特异性权重，每个条件占用独立的位，得分高的设置优先
+// 优先级：商品+店铺 > 商品 > 类别+店铺 > 类别 > 店铺 > 通用
+// 同级中子类别的设置优先于上级类别的设置，尺码再细分
+const (
+	scoreProduct       = 1 << 11
+	scoreCategory      = 1 << 10
+	scoreStore         = 1 << 9
+	scoreCategoryDepth = 1 << 1 // 类别层级占用第1~8位
+	scoreSize          = 1
+	maxCategoryDepth   = 255
+)
+
+// ThresholdTarget 待匹配阈值的店铺商品
+type ThresholdTarget struct {
+	StoreID    uint
+	ProductID  uint
+	CategoryID uint // 商品所属类别，0表示未分类
+	Size       string
+}
+
+// ThresholdCandidate 阈值设置的匹配情况
+type ThresholdCandidate struct {
+	Threshold models.InventoryThreshold `json:"threshold"`
+	Matched   bool                      `json:"matched"`
+	Score     int                       `json:"score"`  // 特异性得分，仅匹配时有效
+	Reason    string                    `json:"reason"` // 不匹配的原因
+}
+
+// ThresholdSet 已加载的库存阈值设置
+type ThresholdSet struct {
+	thresholds []models.InventoryThreshold
+	categories map[uint]models.Category
+}
+
+// LoadThresholds 加载全部库存阈值设置及类别树
+func LoadThresholds(db *gorm.DB) (*ThresholdSet, error) {
+	var thresholds []models.InventoryThreshold
+	if err := excludeUnmigratedThresholds(db).Order("id").Find(&thresholds).Error; err != nil {
+		return nil, err
+	}
+	var categories []models.Category
+	if err := db.Find(&categories).Error; err != nil {
+		return nil, err
+	}
+
+	set := &ThresholdSet{thresholds: thresholds, categories: make(map[uint]models.Category, len(categories))}
+	for _, category := range categories {
+		set.categories[category.ID] = category
+	}
+	return set, nil
+}
+
+// LoadThresholdsFor 只加载可能适用于店铺商品的阈值设置及商品所属类别的上级类别
+// 用于库存变动时评估单个店铺商品，避免每次加载全部阈值和类别树
+func LoadThresholdsFor(db *gorm.DB, target ThresholdTarget) (*ThresholdSet, error) {
+	var categories []models.Category
+	categoryIDs := []uint{0}
+	if target.CategoryID != 0 {
+		var category models.Category
+		result := db.Limit(1).Find(&category, target.CategoryID)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected > 0 {
+			ancestors := CategoryAncestorIDs(&category)
+			if err := db.Where("id IN ?", ancestors).Find(&categories).Error; err != nil {
+				return nil, err
+			}
+			categoryIDs = append(categoryIDs, ancestors...)
+		}
+	}
+
+	var thresholds []models.InventoryThreshold
+	if err := excludeUnmigratedThresholds(db).Where("store_id IN ? AND product_id IN ? AND size IN ? AND category_id IN ?",
+		[]uint{0, target.StoreID}, []uint{0, target.ProductID}, []string{"", target.Size}, categoryIDs).
+		Order("id").Find(&thresholds).Error; err != nil {
+		return nil, err
+	}
+
+	set := &ThresholdSet{thresholds: thresholds, categories: make(map[uint]models.Category, len(categories))}
+	for _, category := range categories {
+		set.categories[category.ID] = category
+	}
+	return set, nil
+}
+
+// legacyCategoryDropped 原类别文本字段已删除，删除后不会恢复，不再重复检查
+var legacyCategoryDropped atomic.Bool
+
+// excludeUnmigratedThresholds 原类别文本字段仍存在时排除类别尚未迁移的阈值设置，
+// 避免只设置了原类别的阈值在迁移前被当作适用于所有类别
+func excludeUnmigratedThresholds(db *gorm.DB) *gorm.DB {
+	if legacyCategoryDropped.Load() {
+		return db
+	}
+	if !db.Migrator().HasColumn(&models.InventoryThreshold{}, "category") {
+		legacyCategoryDropped.Store(true)
+		return db
+	}
+	return db.Where("NOT (category <> '' AND category_id = 0)")
+}
+
+// Match 查找店铺商品适用的阈值，得分最高的设置优先，同分时ID小的优先
+// 没有适用的设置时返回默认阈值
+func (s *ThresholdSet) Match(target ThresholdTarget) models.InventoryThreshold {
+	if t, ok := s.Lookup(target); ok {
+		return t
+	}
+	return models.InventoryThreshold{LowLevel: DefaultLowLevel, HighLevel: DefaultHighLevel}
+}
+
+// Lookup 查找店铺商品适用的阈值设置，没有适用的设置时返回false
+func (s *ThresholdSet) Lookup(target ThresholdTarget) (models.InventoryThreshold, bool) {
+	best := -1
+	bestScore := -1
+	for i, t := range s.thresholds {
+		score, ok := s.matchThreshold(t, target)
+		if ok && score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+
+	if best < 0 {
+		return models.InventoryThreshold{}, false
+	}
+	return s.thresholds[best], true
+}
+
+// ProductThresholds 返回指定了商品的阈值设置
+func (s *ThresholdSet) ProductThresholds() []models.InventoryThreshold {
+	var result []models.InventoryThreshold
+	for _, t := range s.thresholds {
+		if t.ProductID != 0 {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// Explain 列出每条阈值设置对店铺商品的匹配情况，匹配的设置按优先级排在前面
+func (s *ThresholdSet) Explain(target ThresholdTarget) []ThresholdCandidate {
+	candidates := make([]ThresholdCandidate, 0, len(s.thresholds))
+	for _, t := range s.thresholds {
+		candidate := ThresholdCandidate{Threshold: t}
+		candidate.Score, candidate.Matched = s.matchThreshold(t, target)
+		if !candidate.Matched {
+			candidate.Score = 0
+			candidate.Reason = s.mismatchReason(t, target)
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Matched != candidates[j].Matched {
+			return candidates[i].Matched
+		}
+		return candidates[i].Score > candidates[j].Score
+	})
+	return candidates
+}
+
+// matchThreshold 判断阈值设置是否适用并计算特异性得分
+func (s *ThresholdSet) matchThreshold(t models.InventoryThreshold, target ThresholdTarget) (int, bool) {
+	if s.mismatchReason(t, target) != "" {
+		return 0, false
+	}
+
+	score := 0
+	if t.ProductID != 0 {
+		score += scoreProduct
+	}
+	if t.CategoryID != 0 {
+		depth := s.categories[t.CategoryID].Depth
+		if depth > maxCategoryDepth {
+			depth = maxCategoryDepth
+		}
+		score += scoreCategory + scoreCategoryDepth*depth
+	}
+	if t.StoreID != 0 {
+		score += scoreStore
+	}
+	if t.Size != "" {
+		score += scoreSize
+	}
+	return score, true
+}
+
+// mismatchReason 返回阈值设置不适用的原因，适用时返回空字符串
+func (s *ThresholdSet) mismatchReason(t models.InventoryThreshold, target ThresholdTarget) string {
+	switch {
+	case t.StoreID != 0 && t.StoreID != target.StoreID:
+		return "店铺不匹配"
+	case t.ProductID != 0 && t.ProductID != target.ProductID:
+		return "商品不匹配"
+	case t.CategoryID != 0 && !s.inCategory(target.CategoryID, t.CategoryID):
+		if _, ok := s.categories[t.CategoryID]; !ok {
+			return "类别已删除"
+		}
+		return "类别不匹配"
+	case t.Size != "" && t.Size != target.Size:
+		return "尺码不匹配"
+	}
+	return ""
+}
+
+// inCategory 判断类别是否为 ancestorID 或其子类别
+func (s *ThresholdSet) inCategory(categoryID, ancestorID uint) bool {
+	category, ok := s.categories[categoryID]
+	return ok && strings.Contains(category.Path, fmt.Sprintf("/%d/", ancestorID))
+}
+
+// categoryIDOf 返回可为空的类别ID，未分类时返回0
+func categoryIDOf(id *uint) uint {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
@@ -0,0 +1,219 @@
+// Package skucode 实现商品SKU编码规则：品类(2)+年份(2)+季节(1)+颜色(3)+尺码(2)
+// 品类、季节、颜色和尺码的编码来自数据库中的SKU编码字典
+package skucode
+
+import (
+	"errors"
+	"fmt"
+	"hd_psi/backend/models"
+	"strconv"
+	"strings"
+)
+
+// Length SKU总长度
+const Length = 10
+
+// segments 编码段顺序，年份位于品类和季节之间
+var segments = []models.SKUCodeKind{models.SKUCategory, models.SKUSeason, models.SKUColor, models.SKUSize}
+
+var (
+	// ErrInvalidFormat SKU长度或字符不符合编码规则
+	ErrInvalidFormat = errors.New("SKU格式不符合编码规则")
+	// ErrUnknownCode SKU中的编码或商品属性未在编码字典中定义
+	ErrUnknownCode = errors.New("编码字典中没有对应的编码")
+	// ErrMismatch SKU解析出的属性与商品属性不一致
+	ErrMismatch = errors.New("SKU与商品属性不一致")
+)
+
+// Attributes SKU编码对应的商品属性
+type Attributes struct {
+	Category string `json:"category"`
+	Year     int    `json:"year"` // 4位年份，为0表示不限
+	Season   string `json:"season"`
+	Color    string `json:"color"`
+	Size     string `json:"size"`
+}
+
+// FromProduct 取商品的编码属性，商品没有年份，由调用方指定
+func FromProduct(product *models.Product, year int) Attributes {
+	return Attributes{
+		Category: product.Category,
+		Year:     year,
+		Season:   product.Season,
+		Color:    product.Color,
+		Size:     product.Size,
+	}
+}
+
+// value 返回指定编码段的属性值
+func (a Attributes) value(kind models.SKUCodeKind) string {
+	switch kind {
+	case models.SKUCategory:
+		return a.Category
+	case models.SKUSeason:
+		return a.Season
+	case models.SKUColor:
+		return a.Color
+	case models.SKUSize:
+		return a.Size
+	}
+	return ""
+}
+
+// set 设置指定编码段的属性值
+func (a *Attributes) set(kind models.SKUCodeKind, value string) {
+	switch kind {
+	case models.SKUCategory:
+		a.Category = value
+	case models.SKUSeason:
+		a.Season = value
+	case models.SKUColor:
+		a.Color = value
+	case models.SKUSize:
+		a.Size = value
+	}
+}
+
+// Codec 基于编码字典的SKU编解码器
+type Codec struct {
+	codes map[models.SKUCodeKind]map[string]string // 属性值 -> 编码
+	names map[models.SKUCodeKind]map[string]string // 编码 -> 属性值
+}
+
+// NewCodec 由编码字典创建编解码器
+func NewCodec(entries []models.SKUCode) *Codec {
+	c := &Codec{
+		codes: make(map[models.SKUCodeKind]map[string]string),
+		names: make(map[models.SKUCodeKind]map[string]string),
+	}
+	for _, kind := range segments {
+		c.codes[kind] = make(map[string]string)
+		c.names[kind] = make(map[string]string)
+	}
+	for _, e := range entries {
+		if _, ok := c.codes[e.Kind]; !ok {
+			continue
+		}
+		c.codes[e.Kind][e.Name] = e.Code
+		c.names[e.Kind][e.Code] = e.Name
+	}
+	return c
+}
+
+// Code 返回属性值对应的编码
+func (c *Codec) Code(kind models.SKUCodeKind, name string) (string, bool) {
+	code, ok := c.codes[kind][strings.TrimSpace(name)]
+	return code, ok
+}
+
+// Encode 由商品属性生成SKU，年份取后两位
+func (c *Codec) Encode(attr Attributes) (string, error) {
+	if attr.Year <= 0 {
+		return "", fmt.Errorf("%w: 年份无效", ErrInvalidFormat)
+	}
+
+	var b strings.Builder
+	for _, kind := range segments {
+		code, ok := c.Code(kind, attr.value(kind))
+		if !ok {
+			return "", fmt.Errorf("%w: %s %q", ErrUnknownCode, kindLabel(kind), attr.value(kind))
+		}
+		b.WriteString(code)
+		if kind == models.SKUCategory {
+			fmt.Fprintf(&b, "%02d", attr.Year%100)
+		}
+	}
+	return b.String(), nil
+}
+
+// Decode 将SKU解析为商品属性，年份按21世纪解释
+func (c *Codec) Decode(sku string) (Attributes, error) {
+	var attr Attributes
+	sku = strings.TrimSpace(sku)
+	if len(sku) != Length || !isCode(sku) {
+		return attr, fmt.Errorf("%w: %q 应为%d位大写字母或数字", ErrInvalidFormat, sku, Length)
+	}
+
+	pos := 0
+	for _, kind := range segments {
+		n := kind.CodeLength()
+		code := sku[pos : pos+n]
+		pos += n
+
+		name, ok := c.names[kind][code]
+		if !ok {
+			return attr, fmt.Errorf("%w: %s编码 %q", ErrUnknownCode, kindLabel(kind), code)
+		}
+		attr.set(kind, name)
+
+		if kind == models.SKUCategory {
+			year, err := strconv.Atoi(sku[pos : pos+2])
+			if err != nil {
+				return attr, fmt.Errorf("%w: 年份 %q 不是数字", ErrInvalidFormat, sku[pos:pos+2])
+			}
+			attr.Year = 2000 + year
+			pos += 2
+		}
+	}
+	return attr, nil
+}
+
+// Check 校验SKU符合编码规则，且与商品已填写的属性一致
+// 返回SKU解析出的属性，可用于补全商品未填写的属性
+func (c *Codec) Check(sku string, attr Attributes) (Attributes, error) {
+	decoded, err := c.Decode(sku)
+	if err != nil {
+		return decoded, err
+	}
+
+	for _, kind := range segments {
+		if v := strings.TrimSpace(attr.value(kind)); v != "" && v != decoded.value(kind) {
+			return decoded, fmt.Errorf("%w: SKU中%s为 %q，商品为 %q", ErrMismatch, kindLabel(kind), decoded.value(kind), v)
+		}
+	}
+	if attr.Year != 0 && attr.Year%100 != decoded.Year%100 {
+		return decoded, fmt.Errorf("%w: SKU中年份为 %d，商品为 %d", ErrMismatch, decoded.Year, attr.Year)
+	}
+	return decoded, nil
+}
+
+// Fill 用解析出的属性补全商品未填写的品类、季节、颜色和尺码
+func Fill(product *models.Product, attr Attributes) {
+	if product.Category == "" {
+		product.Category = attr.Category
+	}
+	if product.Season == "" {
+		product.Season = attr.Season
+	}
+	if product.Color == "" {
+		product.Color = attr.Color
+	}
+	if product.Size == "" {
+		product.Size = attr.Size
+	}
+}
+
+// isCode 判断字符串只包含大写字母和数字
+func isCode(s string) bool {
+	for _, r := range s {
+		if !(r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// kindLabel 返回编码类型的中文名称
+func kindLabel(kind models.SKUCodeKind) string {
+	switch kind {
+	case models.SKUCategory:
+		return "品类"
+	case models.SKUSeason:
+		return "季节"
+	case models.SKUColor:
+		return "颜色"
+	case models.SKUSize:
+		return "尺码"
+	}
+	return string(kind)
+}
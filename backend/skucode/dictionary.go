@@ -0,0 +1,65 @@
+package skucode
+
+import (
+	"errors"
+	"fmt"
+	"hd_psi/backend/models"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidEntry 编码字典条目无效
+var ErrInvalidEntry = errors.New("编码字典条目无效")
+
+// defaultSeasons 默认季节编码，字典中没有季节编码时写入
+var defaultSeasons = []models.SKUCode{
+	{Kind: models.SKUSeason, Code: "0", Name: "四季"},
+	{Kind: models.SKUSeason, Code: "1", Name: "春季"},
+	{Kind: models.SKUSeason, Code: "2", Name: "夏季"},
+	{Kind: models.SKUSeason, Code: "3", Name: "秋季"},
+	{Kind: models.SKUSeason, Code: "4", Name: "冬季"},
+	{Kind: models.SKUSeason, Code: "5", Name: "春夏"},
+	{Kind: models.SKUSeason, Code: "6", Name: "秋冬"},
+}
+
+// Load 从数据库加载编码字典
+func Load(db *gorm.DB) (*Codec, error) {
+	var entries []models.SKUCode
+	if err := db.Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("加载SKU编码字典失败: %w", err)
+	}
+	return NewCodec(entries), nil
+}
+
+// Normalize 规范化字典条目：编码转为大写并去除首尾空白，校验类型和编码长度
+func Normalize(entry *models.SKUCode) error {
+	entry.Code = strings.ToUpper(strings.TrimSpace(entry.Code))
+	entry.Name = strings.TrimSpace(entry.Name)
+
+	n := entry.Kind.CodeLength()
+	if n == 0 {
+		return fmt.Errorf("%w: 未知类型 %q", ErrInvalidEntry, entry.Kind)
+	}
+	if entry.Name == "" {
+		return fmt.Errorf("%w: 属性值不能为空", ErrInvalidEntry)
+	}
+	if len(entry.Code) != n || !isCode(entry.Code) {
+		return fmt.Errorf("%w: %s编码应为%d位大写字母或数字", ErrInvalidEntry, kindLabel(entry.Kind), n)
+	}
+	return nil
+}
+
+// SeedSeasons 编码字典中没有季节编码时写入默认季节编码
+func SeedSeasons(db *gorm.DB) error {
+	var count int64
+	if err := db.Model(&models.SKUCode{}).Where("kind = ?", models.SKUSeason).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	seasons := make([]models.SKUCode, len(defaultSeasons))
+	copy(seasons, defaultSeasons)
+	return db.Create(&seasons).Error
+}
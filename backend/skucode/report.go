@@ -0,0 +1,68 @@
+package skucode
+
+import (
+	"errors"
+	"hd_psi/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// Violation 不符合编码规则的商品SKU
+type Violation struct {
+	ProductID uint   `json:"product_id"`
+	SKU       string `json:"sku"`
+	Name      string `json:"name"`
+	Category  string `json:"category"`
+	Season    string `json:"season"`
+	Color     string `json:"color"`
+	Size      string `json:"size"`
+	Problem   string `json:"problem"` // format、unknown_code 或 mismatch
+	Reason    string `json:"reason"`
+	// Encodable 商品属性均已在字典中定义，补充年份即可按规则重新生成SKU
+	Encodable bool `json:"encodable"`
+}
+
+// NonconformingProducts 列出SKU不符合编码规则或与商品属性不一致的商品，用于迁移到新规则前的核对
+func NonconformingProducts(db *gorm.DB) ([]Violation, error) {
+	codec, err := Load(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var products []models.Product
+	if err := db.Order("id").Find(&products).Error; err != nil {
+		return nil, err
+	}
+
+	violations := []Violation{}
+	for i := range products {
+		p := &products[i]
+		_, err := codec.Check(p.SKU, FromProduct(p, 0))
+		if err == nil {
+			continue
+		}
+
+		v := Violation{
+			ProductID: p.ID,
+			SKU:       p.SKU,
+			Name:      p.Name,
+			Category:  p.Category,
+			Season:    p.Season,
+			Color:     p.Color,
+			Size:      p.Size,
+			Reason:    err.Error(),
+		}
+		switch {
+		case errors.Is(err, ErrInvalidFormat):
+			v.Problem = "format"
+		case errors.Is(err, ErrUnknownCode):
+			v.Problem = "unknown_code"
+		default:
+			v.Problem = "mismatch"
+		}
+		_, encodeErr := codec.Encode(FromProduct(p, 2000))
+		v.Encodable = encodeErr == nil
+		violations = append(violations, v)
+	}
+	return violations, nil
+}